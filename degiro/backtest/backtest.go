@@ -0,0 +1,469 @@
+// Package backtest replays historical candles through a simulated broker,
+// so that strategies written against degiro.Broker can be run offline.
+package backtest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro"
+	"github.com/llehouerou/go-degiro/degiro/internal/fill"
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+)
+
+// Strategy is called at the close of each candle. The orders it places are
+// matched from the next candle of their product.
+type Strategy func(broker degiro.Broker, candle streaming.Candle)
+
+type EquityPoint struct {
+	Time           time.Time
+	Cash           decimal.Decimal
+	PositionsValue decimal.Decimal
+	Equity         decimal.Decimal
+}
+
+type Result struct {
+	Equity       []EquityPoint
+	Transactions []degiro.Transaction
+	Positions    []degiro.HistoricalPosition
+	// Return is the relative change of equity over the run.
+	Return decimal.Decimal
+	// MaxDrawdown is the largest relative decrease of equity from a peak.
+	MaxDrawdown decimal.Decimal
+}
+
+type event struct {
+	productId string
+	candle    streaming.Candle
+}
+
+type order struct {
+	order degiro.Order
+	input degiro.PlaceOrderInput
+	// day is the day of the first candle the order is matched against
+	day string
+	// triggered stop limit orders are matched as limit orders
+	triggered bool
+	// extreme is the best price seen by a trailing stop
+	extreme decimal.Decimal
+}
+
+// Engine is a simulated broker matching orders against candles: market
+// orders fill at the open, limit orders at the open or the limit once the
+// candle reaches it, stop orders at the open or the stop price once
+// triggered. Day orders expire at the end of the day, in Location, of the
+// first candle they are matched against. Orders whose fill needs more cash
+// or position than available are removed without a transaction. An Engine is
+// not safe for concurrent use.
+type Engine struct {
+	Fees degiro.FeeModel
+	// Slippage is the fraction of the price lost by market and stop fills.
+	Slippage decimal.Decimal
+	Location *time.Location
+
+	initialCash  decimal.Decimal
+	cash         decimal.Decimal
+	products     map[string]degiro.Product
+	events       []event
+	last         map[string]decimal.Decimal
+	positions    map[string]int
+	orders       []*order
+	transactions []degiro.Transaction
+	now          time.Time
+	lastOrderId  int
+	handlers     []func(degiro.OrderUpdate)
+}
+
+var _ degiro.Broker = (*Engine)(nil)
+
+func NewEngine(cash decimal.Decimal) *Engine {
+	return &Engine{
+		Location:    time.UTC,
+		initialCash: cash,
+		cash:        cash,
+		products:    make(map[string]degiro.Product),
+		last:        make(map[string]decimal.Decimal),
+		positions:   make(map[string]int),
+	}
+}
+
+// AddSeries adds the candles of a product to replay.
+func (e *Engine) AddSeries(product degiro.Product, candles []streaming.Candle) {
+	e.products[product.Id] = product
+	for _, candle := range candles {
+		e.events = append(e.events, event{productId: product.Id, candle: candle})
+	}
+}
+
+// Run replays all the candles in time order, calling strategy at the close of
+// each of them.
+func (e *Engine) Run(strategy Strategy) (*Result, error) {
+	if len(e.events) == 0 {
+		return nil, fmt.Errorf("no candles to replay")
+	}
+	sort.SliceStable(e.events, func(i, j int) bool {
+		return e.events[i].candle.End().Before(e.events[j].candle.End())
+	})
+	result := &Result{}
+	for _, ev := range e.events {
+		e.expire(ev.candle.Start)
+		e.now = ev.candle.Start
+		e.match(ev.productId, ev.candle)
+		e.now = ev.candle.End()
+		e.last[ev.productId] = ev.candle.Close
+		strategy(e, ev.candle)
+
+		point := e.equity()
+		if n := len(result.Equity); n > 0 && result.Equity[n-1].Time.Equal(point.Time) {
+			result.Equity[n-1] = point
+		} else {
+			result.Equity = append(result.Equity, point)
+		}
+	}
+
+	result.Transactions = append([]degiro.Transaction(nil), e.transactions...)
+	result.Positions = degiro.HistoricalPositionsFromTransactions(e.transactions)
+	final := result.Equity[len(result.Equity)-1].Equity
+	if e.initialCash.IsPositive() {
+		result.Return = final.Sub(e.initialCash).Div(e.initialCash)
+	}
+	peak := e.initialCash
+	for _, point := range result.Equity {
+		if point.Equity.GreaterThan(peak) {
+			peak = point.Equity
+		}
+		if peak.IsPositive() {
+			drawdown := peak.Sub(point.Equity).Div(peak)
+			if drawdown.GreaterThan(result.MaxDrawdown) {
+				result.MaxDrawdown = drawdown
+			}
+		}
+	}
+	return result, nil
+}
+
+func (e *Engine) equity() EquityPoint {
+	value := decimal.Zero
+	for productId, size := range e.positions {
+		value = value.Add(e.last[productId].Mul(decimal.New(int64(size), 0)))
+	}
+	return EquityPoint{
+		Time:           e.now,
+		Cash:           e.cash,
+		PositionsValue: value,
+		Equity:         e.cash.Add(value),
+	}
+}
+
+func (e *Engine) day(t time.Time) string {
+	return t.In(e.Location).Format("2006-01-02")
+}
+
+// expire removes the day orders of the previous days
+func (e *Engine) expire(now time.Time) {
+	var expired []string
+	for _, o := range append([]*order(nil), e.orders...) {
+		if o.input.TimeType == degiro.Day && o.day != "" && o.day != e.day(now) {
+			e.removeOrder(o.order.Id)
+			expired = append(expired, o.order.Id)
+		}
+	}
+	if len(expired) > 0 {
+		e.notify(degiro.OrderUpdate{Removed: expired})
+	}
+}
+
+func (e *Engine) match(productId string, candle streaming.Candle) {
+	var removed []string
+	for _, o := range append([]*order(nil), e.orders...) {
+		if o.input.ProductId != productId {
+			continue
+		}
+		if o.day == "" {
+			o.day = e.day(candle.Start)
+		}
+		price, quantity, ok := e.fillPrice(o, candle)
+		if !ok {
+			continue
+		}
+		e.removeOrder(o.order.Id)
+		removed = append(removed, o.order.Id)
+		err := fill.Check(o.input.BuySell == degiro.Sell, quantity, price, e.fee(o, price, quantity), e.cash, e.positions[productId])
+		if err != nil {
+			log.Warnf("rejecting backtest order %s: %v", o.order.Id, err)
+			continue
+		}
+		e.fill(o, price, quantity)
+	}
+	if len(removed) > 0 {
+		e.notify(degiro.OrderUpdate{Removed: removed})
+	}
+}
+
+func (e *Engine) slipped(price decimal.Decimal, buy bool) decimal.Decimal {
+	slippage := price.Mul(e.Slippage)
+	if buy {
+		return price.Add(slippage)
+	}
+	return price.Sub(slippage)
+}
+
+// fillPrice returns the price and quantity o is filled at during candle
+func (e *Engine) fillPrice(o *order, candle streaming.Candle) (decimal.Decimal, int, bool) {
+	input := o.input
+	buy := input.BuySell == degiro.Buy
+	quantity := input.Quantity
+
+	// stop returns the trigger price of a stop at level, if reached
+	stop := func(level decimal.Decimal) (decimal.Decimal, bool) {
+		switch {
+		case buy && candle.Open.GreaterThanOrEqual(level), !buy && candle.Open.LessThanOrEqual(level):
+			return candle.Open, true
+		case buy && candle.High.GreaterThanOrEqual(level), !buy && candle.Low.LessThanOrEqual(level):
+			return level, true
+		}
+		return decimal.Zero, false
+	}
+	limit := func(level decimal.Decimal) (decimal.Decimal, bool) {
+		switch {
+		case buy && candle.Open.LessThanOrEqual(level), !buy && candle.Open.GreaterThanOrEqual(level):
+			return candle.Open, true
+		case buy && candle.Low.LessThanOrEqual(level), !buy && candle.High.GreaterThanOrEqual(level):
+			return level, true
+		}
+		return decimal.Zero, false
+	}
+
+	switch input.OrderType {
+	case degiro.Limited:
+		price, ok := limit(input.Price)
+		return price, quantity, ok
+	case degiro.StopLoss:
+		price, ok := stop(input.StopPrice)
+		return e.slipped(price, buy), quantity, ok
+	case degiro.StopLimited:
+		if !o.triggered {
+			price, ok := stop(input.StopPrice)
+			if !ok {
+				return decimal.Zero, 0, false
+			}
+			o.triggered = true
+			// the order of the prices after the trigger is unknown: on the
+			// trigger candle, the limit order can only be filled at the
+			// trigger price, and is matched against the whole range of the
+			// next candles only
+			if (buy && price.LessThanOrEqual(input.Price)) || (!buy && price.GreaterThanOrEqual(input.Price)) {
+				return price, quantity, true
+			}
+			return decimal.Zero, 0, false
+		}
+		price, ok := limit(input.Price)
+		return price, quantity, ok
+	case degiro.TrailingStop:
+		trail := input.Trail
+		if input.TrailPercentage {
+			trail = o.extreme.Mul(input.Trail).Div(decimal.New(100, 0))
+		}
+		level := o.extreme.Sub(trail)
+		if buy {
+			level = o.extreme.Add(trail)
+		}
+		if price, ok := stop(level); ok && o.extreme.IsPositive() {
+			return e.slipped(price, buy), quantity, true
+		}
+		if buy && (o.extreme.IsZero() || candle.Low.LessThan(o.extreme)) {
+			o.extreme = candle.Low
+		}
+		if !buy && candle.High.GreaterThan(o.extreme) {
+			o.extreme = candle.High
+		}
+		return decimal.Zero, 0, false
+	case degiro.StandardAmount:
+		price := e.slipped(candle.Open, buy)
+		if !price.IsPositive() {
+			return decimal.Zero, 0, false
+		}
+		quantity = int(input.Amount.Div(price).IntPart())
+		return price, quantity, quantity > 0
+	default:
+		return e.slipped(candle.Open, buy), quantity, true
+	}
+}
+
+func (e *Engine) fee(o *order, price decimal.Decimal, quantity int) decimal.Decimal {
+	if e.Fees == nil {
+		return decimal.Zero
+	}
+	input := o.input
+	input.Quantity = quantity
+	return e.Fees.Fee(e.products[input.ProductId], input, price)
+}
+
+func (e *Engine) fill(o *order, price decimal.Decimal, quantity int) {
+	f := fill.New(o.input.BuySell == degiro.Sell, quantity, price, e.fee(o, price, quantity))
+	transaction := degiro.Transaction{
+		Id:                         len(e.transactions) + 1,
+		BuySell:                    f.BuySell,
+		Quantity:                   f.Quantity,
+		OrderType:                  o.input.OrderType,
+		ProductId:                  o.order.ProductId,
+		Price:                      price,
+		Date:                       e.now,
+		Total:                      f.Total,
+		TotalInBaseCurrency:        f.Total,
+		FeeInBaseCurrency:          f.Fee,
+		TotalPlusFeeInBaseCurrency: f.TotalPlusFee,
+	}
+	e.cash = e.cash.Add(transaction.TotalPlusFeeInBaseCurrency)
+	e.positions[o.input.ProductId] += transaction.Quantity
+	if e.positions[o.input.ProductId] == 0 {
+		delete(e.positions, o.input.ProductId)
+	}
+	e.transactions = append(e.transactions, transaction)
+}
+
+func (e *Engine) removeOrder(orderId string) bool {
+	for i, o := range e.orders {
+		if o.order.Id == orderId {
+			e.orders = append(e.orders[:i], e.orders[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Engine) notify(update degiro.OrderUpdate) {
+	for _, handler := range e.handlers {
+		handler(update)
+	}
+}
+
+func (e *Engine) PlaceOrder(input degiro.PlaceOrderInput) (string, error) {
+	product, found := e.products[input.ProductId]
+	if !found {
+		return "", fmt.Errorf("product %s not found", input.ProductId)
+	}
+	if input.OrderType != degiro.StandardAmount && input.Quantity <= 0 {
+		return "", fmt.Errorf("size must be positive, got %d", input.Quantity)
+	}
+	productId, err := strconv.Atoi(input.ProductId)
+	if err != nil {
+		return "", fmt.Errorf("parsing product id %s: %v", input.ProductId, err)
+	}
+	if input.BuySell == degiro.Buy {
+		cost := input.Amount
+		if input.OrderType != degiro.StandardAmount {
+			price := input.Price
+			if price.IsZero() {
+				price = e.last[input.ProductId]
+			}
+			cost = price.Mul(decimal.New(int64(input.Quantity), 0))
+		}
+		if cost.GreaterThan(e.cash) {
+			return "", fmt.Errorf("insufficient cash: %s needed, %s available", cost, e.cash)
+		}
+	}
+	e.lastOrderId++
+	o := &order{
+		order: degiro.Order{
+			Id:           fmt.Sprintf("backtest-%d", e.lastOrderId),
+			Date:         e.now,
+			ProductId:    productId,
+			ProductName:  product.Name,
+			Currency:     product.Currency,
+			BuySell:      input.BuySell,
+			Size:         input.Quantity,
+			Quantity:     input.Quantity,
+			Price:        input.Price,
+			StopPrice:    input.StopPrice,
+			OrderType:    input.OrderType,
+			TimeType:     input.TimeType,
+			IsModifiable: true,
+			IsDeletable:  true,
+		},
+		input:   input,
+		extreme: e.last[input.ProductId],
+	}
+	e.orders = append(e.orders, o)
+	e.notify(degiro.OrderUpdate{Added: []degiro.Order{o.order}})
+	return o.order.Id, nil
+}
+
+func (e *Engine) DeleteOrder(orderId string) error {
+	if !e.removeOrder(orderId) {
+		return fmt.Errorf("order %s not found", orderId)
+	}
+	e.notify(degiro.OrderUpdate{Removed: []string{orderId}})
+	return nil
+}
+
+func (e *Engine) GetPendingOrders(productId int) []degiro.Order {
+	var res []degiro.Order
+	for _, o := range e.orders {
+		if o.order.ProductId == productId {
+			res = append(res, o.order)
+		}
+	}
+	return res
+}
+
+func (e *Engine) GetAllPendingOrders() []degiro.Order {
+	var res []degiro.Order
+	for _, o := range e.orders {
+		res = append(res, o.order)
+	}
+	return res
+}
+
+func (e *Engine) GetBalance() degiro.Balance {
+	point := e.equity()
+	return degiro.Balance{
+		Cash:                point.Cash,
+		FreeSpaceNewInEuros: point.Cash,
+		ReportPortfValue:    point.PositionsValue,
+		ReportNetliq:        point.Equity,
+	}
+}
+
+func (e *Engine) GetOpenedPositionForProduct(productId string) (degiro.Position, bool) {
+	size := e.positions[productId]
+	if size == 0 {
+		return degiro.Position{}, false
+	}
+	return degiro.Position{ProductId: productId, Size: size}, true
+}
+
+func (e *Engine) GetTransactions(fromDate time.Time, toDate time.Time) ([]degiro.Transaction, error) {
+	var res []degiro.Transaction
+	for _, t := range e.transactions {
+		if !t.Date.Before(fromDate) && !t.Date.After(toDate) {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+func (e *Engine) GetAllHistoricalPositions() []degiro.HistoricalPosition {
+	return degiro.HistoricalPositionsFromTransactions(e.transactions)
+}
+
+func (e *Engine) GetOpenedHistoricalPositionForProduct(productId string) (degiro.HistoricalPosition, bool) {
+	id, err := strconv.Atoi(productId)
+	if err != nil {
+		return degiro.HistoricalPosition{}, false
+	}
+	for _, position := range e.GetAllHistoricalPositions() {
+		if position.ProductId == id && position.GetSize() != 0 {
+			return position, true
+		}
+	}
+	return degiro.HistoricalPosition{}, false
+}
+
+func (e *Engine) OnOrderUpdate(handler func(degiro.OrderUpdate)) {
+	e.handlers = append(e.handlers, handler)
+}
@@ -0,0 +1,154 @@
+package backtest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro"
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCandles = `Date,Open,High,Low,Close,Volume
+2019-10-14,10,10.5,9.8,10.2,1000
+2019-10-15,10.2,10.4,9.9,10,1200
+2019-10-16,10.1,11,10,10.9,900
+2019-10-17,10.9,11.5,10.8,11.4,1500
+2019-10-18,11.2,11.3,10.1,10.3,2000
+`
+
+func TestLoadCandlesCSV(t *testing.T) {
+	assert := assert.New(t)
+	candles, err := LoadCandlesCSV(strings.NewReader(testCandles), "vwd1", 24*time.Hour, time.UTC)
+	assert.Nil(err)
+	if assert.Equal(5, len(candles)) {
+		assert.Equal(time.Date(2019, 10, 16, 0, 0, 0, 0, time.UTC), candles[2].Start)
+		assert.Equal("10.9", candles[2].Close.String())
+		assert.Equal("900", candles[2].Volume.String())
+	}
+
+	_, err = LoadCandlesCSV(strings.NewReader("Date,Open,Close\n"), "vwd1", time.Hour, nil)
+	assert.NotNil(err)
+}
+
+func TestEngine_Run(t *testing.T) {
+	assert := assert.New(t)
+	candles, err := LoadCandlesCSV(strings.NewReader(testCandles), "vwd1", 24*time.Hour, time.UTC)
+	assert.Nil(err)
+	engine := NewEngine(decimal.New(1000, 0))
+	engine.Fees = degiro.FlatFee{Fixed: decimal.New(1, 0)}
+	engine.AddSeries(degiro.Product{Id: "1", VwdId: "vwd1"}, candles)
+
+	var closes []string
+	result, err := engine.Run(func(broker degiro.Broker, candle streaming.Candle) {
+		closes = append(closes, candle.Close.String())
+		switch len(closes) {
+		case 1:
+			// filled at the open of the next day
+			_, err := broker.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Buy, OrderType: degiro.MarketOrder, ProductId: "1", Quantity: 50, TimeType: degiro.Day})
+			assert.Nil(err)
+			// never reached, expires at the end of the 15th
+			_, err = broker.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Buy, OrderType: degiro.Limited, ProductId: "1", Quantity: 10, TimeType: degiro.Day, Price: decimal.New(9, 0)})
+			assert.Nil(err)
+		case 2:
+			assert.Equal(1, len(broker.GetAllPendingOrders()))
+			position, found := broker.GetOpenedPositionForProduct("1")
+			assert.True(found)
+			assert.Equal(50, position.Size)
+			_, err := broker.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Sell, OrderType: degiro.Limited, ProductId: "1", Quantity: 20, TimeType: degiro.Permanent, Price: decimal.New(11, 0)})
+			assert.Nil(err)
+		case 3:
+			assert.Equal(0, len(broker.GetAllPendingOrders()))
+		case 4:
+			_, err := broker.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Sell, OrderType: degiro.StopLoss, ProductId: "1", Quantity: 30, TimeType: degiro.Permanent, StopPrice: decimal.RequireFromString("10.8")})
+			assert.Nil(err)
+		}
+	})
+	assert.Nil(err)
+	assert.Equal(5, len(closes))
+	if assert.Equal(3, len(result.Transactions)) {
+		assert.Equal("10.2", result.Transactions[0].Price.String())
+		assert.Equal("11", result.Transactions[1].Price.String())
+		// the stop loss is filled at its price on the 18th, when the low
+		// reaches it
+		assert.Equal("10.8", result.Transactions[2].Price.String())
+		assert.Equal(18, result.Transactions[2].Date.Day())
+	}
+	if assert.Equal(1, len(result.Positions)) {
+		assert.Equal(0, result.Positions[0].GetSize())
+	}
+	if assert.Equal(5, len(result.Equity)) {
+		// 1000 - 510 - 1 + 50 * 10
+		assert.Equal("989", result.Equity[1].Equity.String())
+		// 489 + 220 - 1 + 324 - 1
+		assert.Equal("1031", result.Equity[4].Equity.String())
+	}
+	assert.Equal("0.031", result.Return.String())
+	// from 1050 on the 17th
+	assert.Equal("0.0181", result.MaxDrawdown.StringFixed(4))
+}
+
+func TestEngine_RejectAtFill(t *testing.T) {
+	assert := assert.New(t)
+	candles, err := LoadCandlesCSV(strings.NewReader(testCandles), "vwd1", 24*time.Hour, time.UTC)
+	assert.Nil(err)
+	engine := NewEngine(decimal.New(1000, 0))
+	engine.Fees = degiro.FlatFee{Fixed: decimal.New(1, 0)}
+	engine.AddSeries(degiro.Product{Id: "1", VwdId: "vwd1"}, candles)
+	var removed []string
+	engine.OnOrderUpdate(func(update degiro.OrderUpdate) {
+		removed = append(removed, update.Removed...)
+	})
+
+	var days int
+	result, err := engine.Run(func(broker degiro.Broker, candle streaming.Candle) {
+		days++
+		switch days {
+		case 1:
+			// 98 * 10.2 is affordable, but not with the fee
+			_, err := broker.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Buy, OrderType: degiro.MarketOrder, ProductId: "1", Quantity: 98, TimeType: degiro.Day})
+			assert.Nil(err)
+			_, err = broker.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Sell, OrderType: degiro.MarketOrder, ProductId: "1", Quantity: 5, TimeType: degiro.Day})
+			assert.Nil(err)
+		case 2:
+			assert.Equal(0, len(broker.GetAllPendingOrders()))
+			assert.Equal(2, len(removed))
+			assert.Equal("1000", broker.GetBalance().Cash.String())
+		}
+	})
+	assert.Nil(err)
+	assert.Equal(0, len(result.Transactions))
+}
+
+func TestEngine_StopLimitTriggerCandle(t *testing.T) {
+	assert := assert.New(t)
+	candles, err := LoadCandlesCSV(strings.NewReader(testCandles), "vwd1", 24*time.Hour, time.UTC)
+	assert.Nil(err)
+	engine := NewEngine(decimal.New(1000, 0))
+	engine.AddSeries(degiro.Product{Id: "1", VwdId: "vwd1"}, candles)
+
+	var days int
+	result, err := engine.Run(func(broker degiro.Broker, candle streaming.Candle) {
+		days++
+		if days != 2 {
+			return
+		}
+		// triggered at 10.5 on the 16th, whose low of 10 reaches the limit
+		// but maybe before the trigger: filled on the 18th only
+		_, err := broker.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Buy, OrderType: degiro.StopLimited, ProductId: "1", Quantity: 10, TimeType: degiro.Permanent, StopPrice: decimal.RequireFromString("10.5"), Price: decimal.RequireFromString("10.3")})
+		assert.Nil(err)
+		// triggered at 10.5 on the 16th and filled at that price, not at
+		// the low of the candle
+		_, err = broker.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Buy, OrderType: degiro.StopLimited, ProductId: "1", Quantity: 10, TimeType: degiro.Permanent, StopPrice: decimal.RequireFromString("10.5"), Price: decimal.RequireFromString("10.6")})
+		assert.Nil(err)
+	})
+	assert.Nil(err)
+	if assert.Equal(2, len(result.Transactions)) {
+		assert.Equal("10.5", result.Transactions[0].Price.String())
+		assert.Equal(16, result.Transactions[0].Date.Day())
+		assert.Equal("10.3", result.Transactions[1].Price.String())
+		assert.Equal(18, result.Transactions[1].Date.Day())
+	}
+}
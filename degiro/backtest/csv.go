@@ -0,0 +1,100 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+)
+
+var csvTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseCsvTime(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", value)
+}
+
+// LoadCandlesCSV reads candles from CSV data with a header naming the date,
+// open, high, low, close and optional volume columns, case-insensitively.
+// Dates without a time zone are read in loc.
+func LoadCandlesCSV(r io.Reader, issueId string, interval time.Duration, loc *time.Location) ([]streaming.Candle, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, found := columns["date"]; !found {
+		if i, found := columns["time"]; found {
+			columns["date"] = i
+		}
+	}
+	for _, name := range []string{"date", "open", "high", "low", "close"} {
+		if _, found := columns[name]; !found {
+			return nil, fmt.Errorf("no %s column", name)
+		}
+	}
+
+	var res []streaming.Candle
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading line %d: %v", line, err)
+		}
+		start, err := parseCsvTime(record[columns["date"]], loc)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		candle := streaming.Candle{
+			IssueId:  issueId,
+			Start:    start,
+			Interval: interval,
+		}
+		fields := []struct {
+			name  string
+			value *decimal.Decimal
+		}{
+			{"open", &candle.Open},
+			{"high", &candle.High},
+			{"low", &candle.Low},
+			{"close", &candle.Close},
+			{"volume", &candle.Volume},
+		}
+		for _, field := range fields {
+			i, found := columns[field.name]
+			if !found {
+				continue
+			}
+			value, err := decimal.NewFromString(record[i])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: parsing %s: %v", line, field.name, err)
+			}
+			*field.value = value
+		}
+		res = append(res, candle)
+	}
+	return res, nil
+}
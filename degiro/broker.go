@@ -0,0 +1,87 @@
+package degiro
+
+import (
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+)
+
+// Trader places and cancels orders and follows the pending orders.
+type Trader interface {
+	PlaceOrder(input PlaceOrderInput) (string, error)
+	DeleteOrder(orderId string) error
+	GetPendingOrders(productId int) []Order
+	GetAllPendingOrders() []Order
+	OnOrderUpdate(handler func(OrderUpdate))
+}
+
+// PortfolioReader reads the balance, positions and transactions of the
+// account.
+type PortfolioReader interface {
+	GetBalance() Balance
+	GetOpenedPositionForProduct(productId string) (Position, bool)
+	GetTransactions(fromDate time.Time, toDate time.Time) ([]Transaction, error)
+	GetAllHistoricalPositions() []HistoricalPosition
+	GetOpenedHistoricalPositionForProduct(productId string) (HistoricalPosition, bool)
+}
+
+// Broker is the trading interface shared by Client and PaperClient.
+type Broker interface {
+	Trader
+	PortfolioReader
+}
+
+// QuoteSource provides the streaming quotes of products, by vwd id.
+type QuoteSource interface {
+	GetQuote(productvwid string) streaming.ProductQuote
+	SubscribeQuotes(idlist []string) error
+	SubscribeQuotesWithFields(idlist []string, fields []streaming.Field) error
+	UnSubscribeQuotes(idlist []string) error
+	OnQuoteUpdate(handler func(streaming.ProductQuote)) error
+}
+
+// ProductCatalog looks products and exchanges up.
+type ProductCatalog interface {
+	GetProduct(productId string) (Product, bool)
+	GetProducts(productIds []string) []Product
+	SearchProduct(searchtext string) (*Product, bool, error)
+	SearchProducts(options SearchProductsOptions) ([]Product, error)
+	GetExchange(exchangeId string) (Exchange, bool, error)
+}
+
+var (
+	_ Broker         = (*Client)(nil)
+	_ QuoteSource    = (*Client)(nil)
+	_ ProductCatalog = (*Client)(nil)
+	_ Broker         = (*PaperClient)(nil)
+)
+
+// FeeModel computes the fee of an order filled at price, in the base
+// currency, as a positive amount.
+type FeeModel interface {
+	Fee(product Product, input PlaceOrderInput, price decimal.Decimal) decimal.Decimal
+}
+
+// FlatFee is a fixed fee plus a rate of the order value, capped by Max if
+// positive.
+type FlatFee struct {
+	Fixed decimal.Decimal
+	Rate  decimal.Decimal
+	Max   decimal.Decimal
+}
+
+func (f FlatFee) Fee(product Product, input PlaceOrderInput, price decimal.Decimal) decimal.Decimal {
+	fee := f.Fixed.Add(price.Mul(decimal.New(int64(input.Quantity), 0)).Mul(f.Rate))
+	if f.Max.IsPositive() && fee.GreaterThan(f.Max) {
+		return f.Max
+	}
+	return fee
+}
+
+// PaperMarket provides the products and quotes a PaperClient trades on,
+// Client implementing it.
+type PaperMarket interface {
+	GetProduct(productId string) (Product, bool)
+	GetQuote(productvwid string) streaming.ProductQuote
+}
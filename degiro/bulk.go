@@ -0,0 +1,173 @@
+package degiro
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// OrderFilter selects pending orders, the zero value matching all of them.
+type OrderFilter struct {
+	ProductIds []int
+	BuySell    ActionType
+	OrderTypes []OrderType
+}
+
+func (f OrderFilter) Match(order Order) bool {
+	if len(f.ProductIds) > 0 {
+		found := false
+		for _, id := range f.ProductIds {
+			if id == order.ProductId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.BuySell != "" && f.BuySell != order.BuySell {
+		return false
+	}
+	if len(f.OrderTypes) > 0 {
+		found := false
+		for _, orderType := range f.OrderTypes {
+			if orderType == order.OrderType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+type CancelResult struct {
+	OrderId string
+	Err     error
+}
+
+type PlaceResult struct {
+	Input   PlaceOrderInput
+	OrderId string
+	Err     error
+}
+
+// BulkError is returned by the bulk operations when some of the orders
+// failed, the results telling which ones.
+type BulkError struct {
+	Operation string
+	Failed    int
+	Total     int
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%s: %d of %d orders failed", e.Operation, e.Failed, e.Total)
+}
+
+// runConcurrently calls f for 0 to n-1 with at most concurrency calls at a
+// time
+func runConcurrently(n int, concurrency int, f func(i int)) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// CancelAllOrders deletes all the pending orders matching filter, at most
+// BulkConcurrency at a time. It returns a result per order and a *BulkError
+// if some of them could not be deleted.
+func (c *Client) CancelAllOrders(filter OrderFilter) ([]CancelResult, error) {
+	var orders []Order
+	for _, order := range c.GetAllPendingOrders() {
+		if filter.Match(order) {
+			orders = append(orders, order)
+		}
+	}
+	results := make([]CancelResult, len(orders))
+	runConcurrently(len(orders), c.BulkConcurrency, func(i int) {
+		results[i] = CancelResult{
+			OrderId: orders[i].Id,
+			Err:     c.DeleteOrder(orders[i].Id),
+		}
+	})
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, &BulkError{Operation: "cancelling orders", Failed: failed, Total: len(results)}
+	}
+	return results, nil
+}
+
+// PlaceOrders places all the orders of batch, at most BulkConcurrency at a
+// time. It returns a result per order, in batch order, and a *BulkError if
+// some of them could not be placed.
+func (c *Client) PlaceOrders(batch []PlaceOrderInput) ([]PlaceResult, error) {
+	results := make([]PlaceResult, len(batch))
+	runConcurrently(len(batch), c.BulkConcurrency, func(i int) {
+		orderId, err := c.PlaceOrder(batch[i])
+		results[i] = PlaceResult{Input: batch[i], OrderId: orderId, Err: err}
+	})
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, &BulkError{Operation: "placing orders", Failed: failed, Total: len(results)}
+	}
+	return results, nil
+}
+
+// ClosePosition cancels the pending orders of a product and sends a market
+// order for the opened size, a sell for a long position and a buy for a
+// short one. Nothing is sent if an order could not be cancelled.
+func (c *Client) ClosePosition(productId string) (string, error) {
+	position, found := c.GetOpenedPositionForProduct(productId)
+	if !found {
+		return "", fmt.Errorf("no opened position for product %s", productId)
+	}
+	id, err := strconv.Atoi(productId)
+	if err != nil {
+		return "", fmt.Errorf("parsing product id %s: %v", productId, err)
+	}
+	if _, err := c.CancelAllOrders(OrderFilter{ProductIds: []int{id}}); err != nil {
+		return "", err
+	}
+	input := PlaceOrderInput{
+		BuySell:   Sell,
+		OrderType: MarketOrder,
+		ProductId: productId,
+		Quantity:  position.Size,
+		TimeType:  Day,
+	}
+	if position.Size < 0 {
+		input.BuySell = Buy
+		input.Quantity = -position.Size
+	}
+	orderId, err := c.PlaceOrder(input)
+	if err != nil {
+		return "", fmt.Errorf("closing position: %v", err)
+	}
+	return orderId, nil
+}
@@ -0,0 +1,170 @@
+package degiro
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_CancelAllOrders(t *testing.T) {
+	assert := assert.New(t)
+	var mu sync.Mutex
+	var deleted []string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal("DELETE", req.Method)
+		mu.Lock()
+		deleted = append(deleted, req.URL.Path)
+		mu.Unlock()
+		status := 200
+		if strings.Contains(req.URL.Path, "order/c;") {
+			status = 400
+		}
+		return &http.Response{
+			StatusCode: status,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.orders.Add([]Order{
+		{Id: "a", ProductId: 1, BuySell: Buy},
+		{Id: "b", ProductId: 1, BuySell: Sell},
+		{Id: "c", ProductId: 1, BuySell: Sell},
+		{Id: "d", ProductId: 2, BuySell: Sell},
+	})
+
+	results, err := degiro.CancelAllOrders(OrderFilter{ProductIds: []int{1}, BuySell: Sell})
+	if assert.NotNil(err) {
+		assert.Equal("cancelling orders: 1 of 2 orders failed", err.Error())
+	}
+	if assert.Equal(2, len(results)) {
+		assert.Equal("b", results[0].OrderId)
+		assert.Nil(results[0].Err)
+		assert.Equal("c", results[1].OrderId)
+		assert.NotNil(results[1].Err)
+	}
+	assert.Equal(2, len(deleted))
+}
+
+func TestClient_CancelAllOrders_Concurrent(t *testing.T) {
+	assert := assert.New(t)
+	// each request waits for the others, so that they only succeed if they
+	// are in flight at the same time
+	var wg sync.WaitGroup
+	wg.Add(3)
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		wg.Done()
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		status := 200
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			status = 504
+		}
+		return &http.Response{
+			StatusCode: status,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.BulkConcurrency = 3
+	degiro.orders.Add([]Order{
+		{Id: "a", ProductId: 1, BuySell: Sell},
+		{Id: "b", ProductId: 1, BuySell: Sell},
+		{Id: "c", ProductId: 1, BuySell: Sell},
+	})
+
+	_, err := degiro.CancelAllOrders(OrderFilter{})
+	assert.Nil(err)
+}
+
+func TestClient_CancelAllOrders_Relogin(t *testing.T) {
+	assert := assert.New(t)
+	// the old session is rejected: the deletions are sent again with the
+	// session of the relogin, while other deletions are in flight
+	var mu sync.Mutex
+	logins := 0
+	var deleted []string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		status := 200
+		body := `{}`
+		switch {
+		case strings.HasSuffix(req.URL.Path, "login/secure/login"):
+			mu.Lock()
+			logins++
+			mu.Unlock()
+			body = `{"sessionId":"new","status":0}`
+		case strings.Contains(req.URL.Path, "jsessionid=old"):
+			status = 401
+		default:
+			mu.Lock()
+			deleted = append(deleted, req.URL.Path)
+			mu.Unlock()
+		}
+		return &http.Response{
+			StatusCode: status,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.sessionId = "old"
+	degiro.BulkConcurrency = 3
+	degiro.orders.Add([]Order{
+		{Id: "a", ProductId: 1, BuySell: Sell},
+		{Id: "b", ProductId: 1, BuySell: Sell},
+		{Id: "c", ProductId: 1, BuySell: Sell},
+	})
+
+	_, err := degiro.CancelAllOrders(OrderFilter{})
+	assert.Nil(err)
+	assert.Equal(1, logins)
+	assert.Equal(3, len(deleted))
+	for _, path := range deleted {
+		assert.True(strings.HasSuffix(path, ";jsessionid=new"), path)
+	}
+}
+
+func TestClient_ClosePosition(t *testing.T) {
+	assert := assert.New(t)
+	var requests []string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		body := `{}`
+		switch {
+		case strings.Contains(req.URL.Path, "checkOrder"):
+			buf, _ := ioutil.ReadAll(req.Body)
+			assert.Equal(`{"buySell":"BUY","orderType":2,"productId":"1","size":5,"timeType":1}`, strings.TrimSpace(string(buf)))
+			body = `{"data":{"confirmationId":"conf"}}`
+		case req.Method == "POST":
+			body = `{"data":{"orderId":"closing"}}`
+		}
+		requests = append(requests, req.Method)
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.orders.Add([]Order{{Id: "a", ProductId: 1}, {Id: "b", ProductId: 2}})
+	degiro.positions.Add([]Position{{ProductId: "1", Size: -5}})
+
+	orderId, err := degiro.ClosePosition("1")
+	assert.Nil(err)
+	assert.Equal("closing", orderId)
+	assert.Equal([]string{"DELETE", "POST", "POST"}, requests)
+
+	_, err = degiro.ClosePosition("2")
+	assert.NotNil(err)
+}
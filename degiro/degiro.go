@@ -1,6 +1,7 @@
 package degiro
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/cookiejar"
@@ -19,6 +20,28 @@ type Client struct {
 	StreamingUpdatePeriod          time.Duration
 	TryReloginOn401                bool
 	HistoricalPositionUpdatePeriod time.Duration
+	DictionaryCacheDuration        time.Duration
+	// ValidateOrders makes PlaceOrder check orders with ValidateOrder before
+	// sending them.
+	ValidateOrders bool
+	// SnapPrices makes PlaceOrder round prices with SnapOrderPrices.
+	SnapPrices bool
+	// TickSizes are tick size tables by exchange id or MIC code, overriding
+	// DefaultTickSizes.
+	TickSizes map[string]TickSizeTable
+	// ProductTickSizes are tick size tables by product id, overriding
+	// TickSizes, for the venues where the tick size depends on the product.
+	ProductTickSizes map[string]TickSizeTable
+	// BulkConcurrency is the maximum number of concurrent requests of the
+	// bulk order operations.
+	BulkConcurrency int
+	// RiskPolicy, if set, is checked by PlaceOrder before any request.
+	RiskPolicy *RiskPolicy
+	// OrderJournal records the orders of PlaceOrderWithKey.
+	OrderJournal OrderJournal
+	// JournalGracePeriod is how long an order whose confirmation failed is
+	// looked for before being considered as not placed.
+	JournalGracePeriod time.Duration
 
 	httpclient      *http.Client
 	sling           *sling.Sling
@@ -42,9 +65,20 @@ type Client struct {
 
 	transactions *TransactionCache
 	products     *ProductCache
+	dictionary   dictionaryCache
 
-	reloginMu     sync.Mutex
+	journalMu   sync.Mutex
+	journalKeys keyMutex
+
+	handlersMu    sync.RWMutex
+	orderHandlers []func(OrderUpdate)
+
+	// reloginMu is held for reading by requests and for writing by relogins
+	reloginMu     sync.RWMutex
 	lastLoginDate time.Time
+	// sessionMu guards sessionId, which is renewed by relogins while other
+	// requests are built
+	sessionMu sync.RWMutex
 }
 
 func NewClient(httpClient *http.Client) *Client {
@@ -69,12 +103,15 @@ func NewClient(httpClient *http.Client) *Client {
 		UpdatePeriod:                   2 * time.Second,
 		StreamingUpdatePeriod:          1 * time.Second,
 		HistoricalPositionUpdatePeriod: 1 * time.Minute,
+		DictionaryCacheDuration:        24 * time.Hour,
+		BulkConcurrency:                4,
+		OrderJournal:                   NewMemoryOrderJournal(),
+		JournalGracePeriod:             10 * time.Minute,
 		TryReloginOn401:                true,
 		streamingClient:                nil,
 		transactions:                   newTransactionCache(),
-		reloginMu:                      sync.Mutex{},
 	}
-	client.products = newProductCache(client, 24*time.Hour)
+	client.products = newProductCache(client.getProducts, 24*time.Hour)
 	return client
 }
 
@@ -100,7 +137,7 @@ func (c *Client) Login(username string, password string) error {
 	if err != nil {
 		return fmt.Errorf("login: %v", err)
 	}
-	c.sessionId = LoginResponse.SessionId
+	c.setSession(LoginResponse.SessionId)
 	c.configuration, err = c.getConfiguration()
 	if err != nil {
 		return fmt.Errorf("getting configuration: %v", err)
@@ -161,21 +198,60 @@ type Configuration struct {
 	LoginUrl               string `json:"loginUrl"`
 }
 
+func (c *Client) session() string {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+	return c.sessionId
+}
+
+func (c *Client) setSession(sessionId string) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	c.sessionId = sessionId
+}
+
+// send sends the request built by request, returning the session id it was
+// built with.
+func (c *Client) send(request func() *sling.Sling, successV interface{}) (*http.Response, string, error) {
+	c.reloginMu.RLock()
+	defer c.reloginMu.RUnlock()
+	session := c.session()
+	resp, err := request().ReceiveSuccess(successV)
+	return resp, session, err
+}
+
 func (c *Client) ReceiveSuccessReloginOn401(s *sling.Sling, successV interface{}) (*http.Response, error) {
-	c.reloginMu.Lock()
-	defer c.reloginMu.Unlock()
+	resp, session, err := c.send(func() *sling.Sling { return s }, successV)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == 401 && c.TryReloginOn401 {
+		if err := c.relogin(session); err != nil {
+			return nil, fmt.Errorf("relogin on 401: %v", err)
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("not 2xx HTTP status code: %d - %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
 
-	resp, err := s.ReceiveSuccess(successV)
+// receiveSuccessReloginOn401 sends the request built by request, building and
+// sending it once more with the new session id if it got a 401 and the
+// session was renewed.
+func (c *Client) receiveSuccessReloginOn401(request func() *sling.Sling, successV interface{}) (*http.Response, error) {
+	resp, session, err := c.send(request, successV)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode == 401 && c.TryReloginOn401 && (time.Now().Sub(c.lastLoginDate) >= 15*time.Second) {
-		log.Info("Try relogin")
-		LoginResponse, err := c.login(c.username, c.password)
-		if err != nil {
+	if resp.StatusCode == 401 && c.TryReloginOn401 {
+		if err := c.relogin(session); err != nil {
 			return nil, fmt.Errorf("relogin on 401: %v", err)
 		}
-		c.sessionId = LoginResponse.SessionId
+		resp, _, err = c.send(request, successV)
+		if err != nil {
+			return nil, err
+		}
 	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return nil, fmt.Errorf("not 2xx HTTP status code: %d - %s", resp.StatusCode, http.StatusText(resp.StatusCode))
@@ -183,6 +259,27 @@ func (c *Client) ReceiveSuccessReloginOn401(s *sling.Sling, successV interface{}
 	return resp, nil
 }
 
+// relogin replaces the rejected session by a new one. It does nothing if the
+// session was already renewed, e.g. by a concurrent request which got a 401
+// as well, and fails if the last login was less than 15 seconds ago.
+func (c *Client) relogin(rejected string) error {
+	c.reloginMu.Lock()
+	defer c.reloginMu.Unlock()
+	if c.session() != rejected {
+		return nil
+	}
+	if time.Now().Sub(c.lastLoginDate) < 15*time.Second {
+		return errors.New("session rejected less than 15 seconds after login")
+	}
+	log.Info("Try relogin")
+	LoginResponse, err := c.login(c.username, c.password)
+	if err != nil {
+		return err
+	}
+	c.setSession(LoginResponse.SessionId)
+	return nil
+}
+
 func (c *Client) getConfiguration() (*Configuration, error) {
 	configuration := &Configuration{}
 	resp, err := c.sling.New().Get("login/secure/config").
@@ -252,7 +349,7 @@ func (c *Client) getUserConfiguration() (*UserConfiguration, error) {
 	userConfigurationResponse := &UserConfigurationResponse{}
 	resp, err := c.sling.New().Get("pa/secure/client").
 		QueryStruct(&UserConfigurationQueryParams{
-			SessionId: c.sessionId,
+			SessionId: c.session(),
 		}).ReceiveSuccess(userConfigurationResponse)
 	if err != nil {
 		return nil, fmt.Errorf("requesting user configuration: %v", err)
@@ -279,6 +376,13 @@ func (c *Client) SubscribeQuotes(idlist []string) error {
 	return nil
 }
 
+func (c *Client) SubscribeQuotesWithFields(idlist []string, fields []streaming.Field) error {
+	if c.streamingClient == nil {
+		return fmt.Errorf("streaming client is not initialized")
+	}
+	return c.streamingClient.SubscribeQuotesWithFields(idlist, fields)
+}
+
 func (c *Client) UnSubscribeQuotes(idlist []string) error {
 	if c.streamingClient == nil {
 		return fmt.Errorf("streaming client is not initialized")
@@ -297,6 +401,42 @@ func (c *Client) GetQuote(productvwid string) streaming.ProductQuote {
 	return c.streamingClient.GetQuote(productvwid)
 }
 
+func (c *Client) SubscribeOrderBook(productvwid string, depth int) error {
+	if c.streamingClient == nil {
+		return fmt.Errorf("streaming client is not initialized")
+	}
+	return c.streamingClient.SubscribeOrderBook(productvwid, depth)
+}
+
+func (c *Client) UnSubscribeOrderBook(productvwid string) error {
+	if c.streamingClient == nil {
+		return fmt.Errorf("streaming client is not initialized")
+	}
+	return c.streamingClient.UnSubscribeOrderBook(productvwid)
+}
+
+func (c *Client) GetOrderBook(productvwid string) streaming.OrderBook {
+	if c.streamingClient == nil {
+		return streaming.OrderBook{IssueId: productvwid}
+	}
+	return c.streamingClient.GetOrderBook(productvwid)
+}
+
+func (c *Client) OnQuoteUpdate(handler func(streaming.ProductQuote)) error {
+	if c.streamingClient == nil {
+		return fmt.Errorf("streaming client is not initialized")
+	}
+	c.streamingClient.OnQuoteUpdate(handler)
+	return nil
+}
+
+func (c *Client) StreamingConnectionEvents() <-chan streaming.ConnectionEvent {
+	if c.streamingClient == nil {
+		return nil
+	}
+	return c.streamingClient.ConnectionEvents()
+}
+
 func (c *Client) GetBalance() Balance {
 	return c.balance.Get()
 }
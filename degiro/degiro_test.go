@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/dghubble/sling"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -97,3 +98,44 @@ func TestGetUserConfiguration(t *testing.T) {
 		assert.Equal("username", config.Username)
 	}
 }
+
+func TestReceiveSuccessReloginOn401(t *testing.T) {
+	assert := assert.New(t)
+	logins := 0
+	requests := 0
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		status := 200
+		body := `{}`
+		if req.URL.Path == "/login/secure/login" {
+			logins++
+			body = fmt.Sprintf(`{"sessionId":"session%d","status":0}`, logins)
+		} else {
+			requests++
+			status = 401
+		}
+		return &http.Response{
+			StatusCode: status,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.sessionId = "expired"
+
+	// the exported function renews the session but still reports the 401
+	resp, err := degiro.ReceiveSuccessReloginOn401(degiro.sling.New().Get("pa/secure/client"), nil)
+	assert.Nil(resp)
+	assert.NotNil(err)
+	assert.Equal(1, logins)
+	assert.Equal(1, requests)
+	assert.Equal("session1", degiro.session())
+
+	// a session rejected right after the login is not renewed again, and the
+	// request is not sent again with it
+	_, err = degiro.receiveSuccessReloginOn401(func() *sling.Sling {
+		return degiro.sling.New().Get("pa/secure/client")
+	}, nil)
+	assert.NotNil(err)
+	assert.Equal(1, logins)
+	assert.Equal(2, requests)
+}
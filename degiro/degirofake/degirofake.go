@@ -0,0 +1,252 @@
+// Package degirofake provides fakes of the degiro interfaces whose responses
+// are set with function fields, e.g.
+//
+//	trader := &degirofake.Trader{
+//		PlaceOrderFunc: func(input degiro.PlaceOrderInput) (string, error) {
+//			return "order-1", nil
+//		},
+//	}
+//
+// A method whose function is nil returns zero values. The fakes are safe for
+// concurrent use if their functions are.
+package degirofake
+
+import (
+	"sync"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro"
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+)
+
+var (
+	_ degiro.Trader          = (*Trader)(nil)
+	_ degiro.PortfolioReader = (*PortfolioReader)(nil)
+	_ degiro.Broker          = (*Broker)(nil)
+	_ degiro.QuoteSource     = (*QuoteSource)(nil)
+	_ degiro.ProductCatalog  = (*ProductCatalog)(nil)
+)
+
+// Trader is a fake degiro.Trader. The handlers registered by OnOrderUpdate
+// are called by Notify.
+type Trader struct {
+	PlaceOrderFunc          func(input degiro.PlaceOrderInput) (string, error)
+	DeleteOrderFunc         func(orderId string) error
+	GetPendingOrdersFunc    func(productId int) []degiro.Order
+	GetAllPendingOrdersFunc func() []degiro.Order
+
+	mu       sync.RWMutex
+	handlers []func(degiro.OrderUpdate)
+}
+
+func (t *Trader) PlaceOrder(input degiro.PlaceOrderInput) (string, error) {
+	if t.PlaceOrderFunc == nil {
+		return "", nil
+	}
+	return t.PlaceOrderFunc(input)
+}
+
+func (t *Trader) DeleteOrder(orderId string) error {
+	if t.DeleteOrderFunc == nil {
+		return nil
+	}
+	return t.DeleteOrderFunc(orderId)
+}
+
+func (t *Trader) GetPendingOrders(productId int) []degiro.Order {
+	if t.GetPendingOrdersFunc == nil {
+		return nil
+	}
+	return t.GetPendingOrdersFunc(productId)
+}
+
+func (t *Trader) GetAllPendingOrders() []degiro.Order {
+	if t.GetAllPendingOrdersFunc == nil {
+		return nil
+	}
+	return t.GetAllPendingOrdersFunc()
+}
+
+func (t *Trader) OnOrderUpdate(handler func(degiro.OrderUpdate)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handlers = append(t.handlers, handler)
+}
+
+// Notify calls the handlers registered by OnOrderUpdate with update.
+func (t *Trader) Notify(update degiro.OrderUpdate) {
+	t.mu.RLock()
+	handlers := t.handlers
+	t.mu.RUnlock()
+	for _, handler := range handlers {
+		handler(update)
+	}
+}
+
+// PortfolioReader is a fake degiro.PortfolioReader.
+type PortfolioReader struct {
+	GetBalanceFunc                            func() degiro.Balance
+	GetOpenedPositionForProductFunc           func(productId string) (degiro.Position, bool)
+	GetTransactionsFunc                       func(fromDate time.Time, toDate time.Time) ([]degiro.Transaction, error)
+	GetAllHistoricalPositionsFunc             func() []degiro.HistoricalPosition
+	GetOpenedHistoricalPositionForProductFunc func(productId string) (degiro.HistoricalPosition, bool)
+}
+
+func (p *PortfolioReader) GetBalance() degiro.Balance {
+	if p.GetBalanceFunc == nil {
+		return degiro.Balance{}
+	}
+	return p.GetBalanceFunc()
+}
+
+func (p *PortfolioReader) GetOpenedPositionForProduct(productId string) (degiro.Position, bool) {
+	if p.GetOpenedPositionForProductFunc == nil {
+		return degiro.Position{}, false
+	}
+	return p.GetOpenedPositionForProductFunc(productId)
+}
+
+func (p *PortfolioReader) GetTransactions(fromDate time.Time, toDate time.Time) ([]degiro.Transaction, error) {
+	if p.GetTransactionsFunc == nil {
+		return nil, nil
+	}
+	return p.GetTransactionsFunc(fromDate, toDate)
+}
+
+func (p *PortfolioReader) GetAllHistoricalPositions() []degiro.HistoricalPosition {
+	if p.GetAllHistoricalPositionsFunc == nil {
+		return nil
+	}
+	return p.GetAllHistoricalPositionsFunc()
+}
+
+func (p *PortfolioReader) GetOpenedHistoricalPositionForProduct(productId string) (degiro.HistoricalPosition, bool) {
+	if p.GetOpenedHistoricalPositionForProductFunc == nil {
+		return degiro.HistoricalPosition{}, false
+	}
+	return p.GetOpenedHistoricalPositionForProductFunc(productId)
+}
+
+// Broker is a fake degiro.Broker.
+type Broker struct {
+	Trader
+	PortfolioReader
+}
+
+// QuoteSource is a fake degiro.QuoteSource. Without GetQuoteFunc, GetQuote
+// returns the last quote given to Publish for the issue.
+type QuoteSource struct {
+	GetQuoteFunc                  func(productvwid string) streaming.ProductQuote
+	SubscribeQuotesFunc           func(idlist []string) error
+	SubscribeQuotesWithFieldsFunc func(idlist []string, fields []streaming.Field) error
+	UnSubscribeQuotesFunc         func(idlist []string) error
+
+	mu       sync.RWMutex
+	quotes   map[string]streaming.ProductQuote
+	handlers []func(streaming.ProductQuote)
+}
+
+func (q *QuoteSource) GetQuote(productvwid string) streaming.ProductQuote {
+	if q.GetQuoteFunc != nil {
+		return q.GetQuoteFunc(productvwid)
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.quotes[productvwid]
+}
+
+func (q *QuoteSource) SubscribeQuotes(idlist []string) error {
+	if q.SubscribeQuotesFunc == nil {
+		return nil
+	}
+	return q.SubscribeQuotesFunc(idlist)
+}
+
+func (q *QuoteSource) SubscribeQuotesWithFields(idlist []string, fields []streaming.Field) error {
+	if q.SubscribeQuotesWithFieldsFunc == nil {
+		return nil
+	}
+	return q.SubscribeQuotesWithFieldsFunc(idlist, fields)
+}
+
+func (q *QuoteSource) UnSubscribeQuotes(idlist []string) error {
+	if q.UnSubscribeQuotesFunc == nil {
+		return nil
+	}
+	return q.UnSubscribeQuotesFunc(idlist)
+}
+
+func (q *QuoteSource) OnQuoteUpdate(handler func(streaming.ProductQuote)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers = append(q.handlers, handler)
+	return nil
+}
+
+// Publish stores quote and calls the handlers registered by OnQuoteUpdate.
+func (q *QuoteSource) Publish(quote streaming.ProductQuote) {
+	q.mu.Lock()
+	if q.quotes == nil {
+		q.quotes = make(map[string]streaming.ProductQuote)
+	}
+	q.quotes[quote.IssueId] = quote
+	handlers := q.handlers
+	q.mu.Unlock()
+	for _, handler := range handlers {
+		handler(quote)
+	}
+}
+
+// ProductCatalog is a fake degiro.ProductCatalog. Without GetProductFunc and
+// GetProductsFunc, the products are looked up in Products by id.
+type ProductCatalog struct {
+	Products map[string]degiro.Product
+
+	GetProductFunc     func(productId string) (degiro.Product, bool)
+	GetProductsFunc    func(productIds []string) []degiro.Product
+	SearchProductFunc  func(searchtext string) (*degiro.Product, bool, error)
+	SearchProductsFunc func(options degiro.SearchProductsOptions) ([]degiro.Product, error)
+	GetExchangeFunc    func(exchangeId string) (degiro.Exchange, bool, error)
+}
+
+func (c *ProductCatalog) GetProduct(productId string) (degiro.Product, bool) {
+	if c.GetProductFunc != nil {
+		return c.GetProductFunc(productId)
+	}
+	product, found := c.Products[productId]
+	return product, found
+}
+
+func (c *ProductCatalog) GetProducts(productIds []string) []degiro.Product {
+	if c.GetProductsFunc != nil {
+		return c.GetProductsFunc(productIds)
+	}
+	var res []degiro.Product
+	for _, id := range productIds {
+		if product, found := c.GetProduct(id); found {
+			res = append(res, product)
+		}
+	}
+	return res
+}
+
+func (c *ProductCatalog) SearchProduct(searchtext string) (*degiro.Product, bool, error) {
+	if c.SearchProductFunc == nil {
+		return nil, false, nil
+	}
+	return c.SearchProductFunc(searchtext)
+}
+
+func (c *ProductCatalog) SearchProducts(options degiro.SearchProductsOptions) ([]degiro.Product, error) {
+	if c.SearchProductsFunc == nil {
+		return nil, nil
+	}
+	return c.SearchProductsFunc(options)
+}
+
+func (c *ProductCatalog) GetExchange(exchangeId string) (degiro.Exchange, bool, error) {
+	if c.GetExchangeFunc == nil {
+		return degiro.Exchange{}, false, nil
+	}
+	return c.GetExchangeFunc(exchangeId)
+}
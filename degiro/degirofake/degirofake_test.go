@@ -0,0 +1,65 @@
+package degirofake
+
+import (
+	"testing"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro"
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriggerEngineWithFakes(t *testing.T) {
+	assert := assert.New(t)
+	placed := make(chan degiro.PlaceOrderInput, 1)
+	trader := &Trader{
+		PlaceOrderFunc: func(input degiro.PlaceOrderInput) (string, error) {
+			placed <- input
+			return "order-1", nil
+		},
+	}
+	var subscribed []string
+	quotes := &QuoteSource{
+		SubscribeQuotesWithFieldsFunc: func(idlist []string, fields []streaming.Field) error {
+			subscribed = append(subscribed, idlist...)
+			return nil
+		},
+	}
+	engine, err := degiro.NewTriggerEngine(trader, quotes, nil)
+	assert.Nil(err)
+	stop := degiro.PlaceOrderInput{BuySell: degiro.Sell, OrderType: degiro.MarketOrder, ProductId: "1", Quantity: 10, TimeType: degiro.Day}
+	_, err = engine.Add(degiro.Trigger{IssueId: "350015372", Condition: degiro.CrossBelow, Level: decimal.New(100, 0), Order: stop})
+	assert.Nil(err)
+	assert.Equal([]string{"350015372"}, subscribed)
+
+	quotes.Publish(streaming.ProductQuote{IssueId: "350015372", LastPrice: decimal.New(101, 0)})
+	quotes.Publish(streaming.ProductQuote{IssueId: "350015372", LastPrice: decimal.New(99, 0)})
+	assert.Equal("99", quotes.GetQuote("350015372").LastPrice.String())
+	select {
+	case input := <-placed:
+		assert.Equal(stop, input)
+	case <-time.After(time.Second):
+		t.Fatal("no order placed")
+	}
+}
+
+func TestBroker(t *testing.T) {
+	assert := assert.New(t)
+	broker := &Broker{}
+	broker.GetBalanceFunc = func() degiro.Balance {
+		return degiro.Balance{Cash: decimal.New(100, 0)}
+	}
+	var updates []degiro.OrderUpdate
+	broker.OnOrderUpdate(func(update degiro.OrderUpdate) {
+		updates = append(updates, update)
+	})
+	broker.Notify(degiro.OrderUpdate{Removed: []string{"order-1"}})
+	assert.Equal(1, len(updates))
+	assert.Equal("100", broker.GetBalance().Cash.String())
+	_, found := broker.GetOpenedPositionForProduct("1")
+	assert.False(found)
+
+	catalog := &ProductCatalog{Products: map[string]degiro.Product{"1": {Id: "1", Name: "ING"}}}
+	assert.Equal(1, len(catalog.GetProducts([]string{"1", "2"})))
+}
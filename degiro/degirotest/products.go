@@ -0,0 +1,158 @@
+package degirotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/llehouerou/go-degiro/degiro"
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+)
+
+// market holds the products and quotes of the server, implementing
+// degiro.PaperMarket
+type market struct {
+	mu         sync.RWMutex
+	products   map[string]degiro.Product
+	productIds []string
+	quotes     map[string]streaming.ProductQuote
+	dictionary degiro.Dictionary
+}
+
+func newMarket() *market {
+	return &market{
+		products: make(map[string]degiro.Product),
+		quotes:   make(map[string]streaming.ProductQuote),
+		dictionary: degiro.Dictionary{
+			Exchanges: []degiro.Exchange{
+				{Id: 194, Code: "XET", HiqAbbr: "XET", Country: "DE", City: "Frankfurt", MicCode: "XETR", Name: "Xetra"},
+				{Id: 200, Code: "EAM", HiqAbbr: "EAM", Country: "NL", City: "Amsterdam", MicCode: "XAMS", Name: "Euronext Amsterdam"},
+				{Id: 663, Code: "NDQ", HiqAbbr: "NDQ", Country: "US", City: "New York", MicCode: "XNAS", Name: "NASDAQ"},
+				{Id: 676, Code: "NSY", HiqAbbr: "NSY", Country: "US", City: "New York", MicCode: "XNYS", Name: "NYSE"},
+				{Id: 710, Code: "EPA", HiqAbbr: "EPA", Country: "FR", City: "Paris", MicCode: "XPAR", Name: "Euronext Paris"},
+			},
+			ProductTypes: []degiro.ProductTypeInfo{
+				{Id: int(degiro.Stock), Name: "STOCK", Translation: "list.producttype.stock"},
+				{Id: int(degiro.Bond), Name: "BOND", Translation: "list.producttype.bond"},
+				{Id: int(degiro.Future), Name: "FUTURE", Translation: "list.producttype.future"},
+				{Id: int(degiro.Option), Name: "OPTION", Translation: "list.producttype.option"},
+				{Id: int(degiro.Etf), Name: "ETF", Translation: "list.producttype.etf"},
+			},
+		},
+	}
+}
+
+func (m *market) GetProduct(productId string) (degiro.Product, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	product, found := m.products[productId]
+	return product, found
+}
+
+func (m *market) GetQuote(productvwid string) streaming.ProductQuote {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	quote, found := m.quotes[productvwid]
+	if !found {
+		return streaming.ProductQuote{IssueId: productvwid}
+	}
+	return quote
+}
+
+// AddProduct adds or replaces a product. Orders are validated against it
+// with degiro.ValidateOrder, so it should be tradable and list its order
+// types as the products of DEGIRO do.
+func (s *Server) AddProduct(product degiro.Product) {
+	s.market.mu.Lock()
+	defer s.market.mu.Unlock()
+	if _, found := s.market.products[product.Id]; !found {
+		s.market.productIds = append(s.market.productIds, product.Id)
+	}
+	s.market.products[product.Id] = product
+}
+
+// SetDictionary replaces the dictionary of exchanges and product types.
+func (s *Server) SetDictionary(dictionary degiro.Dictionary) {
+	s.market.mu.Lock()
+	defer s.market.mu.Unlock()
+	s.market.dictionary = dictionary
+}
+
+func (s *Server) handleDictionary(w http.ResponseWriter) {
+	s.market.mu.RLock()
+	defer s.market.mu.RUnlock()
+	writeJSON(w, http.StatusOK, s.market.dictionary)
+}
+
+func (s *Server) handleProductInfo(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		writeError(w, fmt.Errorf("decoding product ids: %v", err))
+		return
+	}
+	products := make(map[string]degiro.Product)
+	for _, id := range ids {
+		if product, found := s.market.GetProduct(id); found {
+			products[id] = product
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": products})
+}
+
+var searchProductTypes = map[string]degiro.ProductType{
+	"stocks":     degiro.Stock,
+	"etfs":       degiro.Etf,
+	"bonds":      degiro.Bond,
+	"options":    degiro.Option,
+	"futures":    degiro.Future,
+	"leverageds": degiro.Leveraged,
+}
+
+func (s *Server) handleProductSearch(w http.ResponseWriter, r *http.Request, path string) {
+	query := r.URL.Query()
+	productType, _ := strconv.Atoi(query.Get("productTypeId"))
+	if path != "products/lookup" {
+		t, found := searchProductTypes[path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		productType = int(t)
+	}
+	text := strings.ToLower(query.Get("searchText"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	s.market.mu.RLock()
+	var matches []degiro.Product
+	for _, id := range s.market.productIds {
+		product := s.market.products[id]
+		if productType != 0 && product.ProductTypeId != productType {
+			continue
+		}
+		if text != "" && product.Id != text &&
+			!strings.Contains(strings.ToLower(product.Name), text) &&
+			!strings.Contains(strings.ToLower(product.Symbol), text) &&
+			strings.ToLower(product.Isin) != text {
+			continue
+		}
+		matches = append(matches, product)
+	}
+	s.market.mu.RUnlock()
+
+	page := []degiro.Product{}
+	if offset < len(matches) {
+		page = matches[offset:]
+	}
+	if limit > 0 && len(page) > limit {
+		page = page[:limit]
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"offset":   offset,
+		"total":    len(matches),
+		"products": page,
+	})
+}
@@ -0,0 +1,187 @@
+package degirotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+)
+
+type vwdMessage struct {
+	Name  string        `json:"m"`
+	Value []interface{} `json:"v"`
+}
+
+// vwdSession is a quotecast session, holding the index of each requested
+// field and the messages not polled yet
+type vwdSession struct {
+	indexes map[string]int
+	pending []vwdMessage
+}
+
+// quoteValue returns the message setting a field of quote, if it is set
+func quoteValue(quote streaming.ProductQuote, field streaming.Field) (vwdMessage, bool) {
+	var number decimal.Decimal
+	var text string
+	switch field {
+	case streaming.FullName:
+		text = quote.FullName
+	case streaming.LastTime:
+		text = vwdTime(quote.LastTime)
+	case streaming.BidTime:
+		text = vwdTime(quote.BidTime)
+	case streaming.AskTime:
+		text = vwdTime(quote.AskTime)
+	case streaming.LastPrice:
+		number = quote.LastPrice
+	case streaming.LastVolume:
+		number = quote.LastVolume
+	case streaming.CumulativeVolume:
+		number = quote.CumulativeVolume
+	case streaming.BidPrice:
+		number = quote.BidPrice
+	case streaming.BidVolume:
+		number = quote.BidVolume
+	case streaming.AskPrice:
+		number = quote.AskPrice
+	case streaming.AskVolume:
+		number = quote.AskVolume
+	case streaming.OpenPrice:
+		number = quote.OpenPrice
+	case streaming.HighPrice:
+		number = quote.HighPrice
+	case streaming.LowPrice:
+		number = quote.LowPrice
+	case streaming.ClosePrice:
+		number = quote.ClosePrice
+	case streaming.PreviousClosePrice:
+		number = quote.PreviousClosePrice
+	}
+	if text != "" {
+		return vwdMessage{Name: "us", Value: []interface{}{text}}, true
+	}
+	if !number.IsZero() {
+		f, _ := number.Float64()
+		return vwdMessage{Name: "un", Value: []interface{}{f}}, true
+	}
+	return vwdMessage{}, false
+}
+
+// vwdTime formats t in the time zone of the vwd values
+func vwdTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if loc, err := time.LoadLocation("Europe/Amsterdam"); err == nil {
+		t = t.In(loc)
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+func (s *vwdSession) queueValue(index int, quote streaming.ProductQuote, field streaming.Field) {
+	if message, found := quoteValue(quote, field); found {
+		message.Value = append([]interface{}{index}, message.Value...)
+		s.pending = append(s.pending, message)
+	}
+}
+
+// SetQuote sets the quote of an issue, sending the subscribed values to the
+// quotecast sessions and filling the pending orders it reaches. Zero values
+// are not sent.
+func (s *Server) SetQuote(quote streaming.ProductQuote) {
+	s.market.mu.Lock()
+	s.market.quotes[quote.IssueId] = quote
+	s.market.mu.Unlock()
+
+	s.mu.Lock()
+	for _, session := range s.vwdSessions {
+		for name, index := range session.indexes {
+			i := strings.LastIndex(name, ".")
+			if name[:i] == quote.IssueId {
+				session.queueValue(index, quote, streaming.Field(name[i+1:]))
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	s.paper.ProcessQuote(quote)
+	s.syncPortfolio()
+}
+
+func (s *Server) handleQuotecast(w http.ResponseWriter, r *http.Request, path string) {
+	if path == "request_session" && r.Method == http.MethodPost {
+		s.mu.Lock()
+		s.lastVwdId++
+		sessionId := fmt.Sprintf("vwd-session-%d", s.lastVwdId)
+		s.vwdSessions[sessionId] = &vwdSession{indexes: make(map[string]int)}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, map[string]string{"sessionId": sessionId})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		request := struct {
+			ControlData string `json:"controlData"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, fmt.Errorf("decoding control data: %v", err))
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		session, found := s.vwdSessions[path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		for _, command := range strings.Split(request.ControlData, ";") {
+			s.applyControlCommand(session, strings.TrimSpace(command))
+		}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		session, found := s.vwdSessions[path]
+		if !found {
+			writeJSON(w, http.StatusOK, []vwdMessage{{Name: "sr"}})
+			return
+		}
+		messages := session.pending
+		session.pending = nil
+		if len(messages) == 0 {
+			messages = []vwdMessage{{Name: "h"}}
+		}
+		writeJSON(w, http.StatusOK, messages)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// applyControlCommand applies a req(issue.Field) or rel(issue.Field) command
+func (s *Server) applyControlCommand(session *vwdSession, command string) {
+	if len(command) < 5 || !strings.HasSuffix(command, ")") {
+		return
+	}
+	name := command[4 : len(command)-1]
+	i := strings.LastIndex(name, ".")
+	if i <= 0 {
+		return
+	}
+	switch command[:4] {
+	case "req(":
+		if _, found := session.indexes[name]; found {
+			return
+		}
+		s.lastVwdIndex++
+		session.indexes[name] = s.lastVwdIndex
+		session.pending = append(session.pending, vwdMessage{Name: "a_req", Value: []interface{}{name, s.lastVwdIndex}})
+		session.queueValue(s.lastVwdIndex, s.market.GetQuote(name[:i]), streaming.Field(name[i+1:]))
+	case "rel(":
+		delete(session.indexes, name)
+	}
+}
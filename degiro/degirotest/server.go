@@ -0,0 +1,246 @@
+// Package degirotest provides a fake DEGIRO backend running on a local
+// httptest server, so that a degiro.Client can be tested end to end:
+//
+//	server := degirotest.NewServer(decimal.New(10000, 0))
+//	defer server.Close()
+//	server.AddProduct(product)
+//	server.SetQuote(streaming.ProductQuote{IssueId: product.VwdId, LastPrice: price})
+//	client := degiro.NewClient(server.Client())
+//	err := client.Login(degirotest.Username, degirotest.Password)
+//
+// The server emulates the login, configuration, update, order, product,
+// transaction and vwd quotecast endpoints. Orders are filled by a
+// degiro.PaperClient against the quotes set with SetQuote.
+package degirotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/llehouerou/go-degiro/degiro"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	Username  = "user"
+	Password  = "password"
+	ClientId  = 1234567
+	AccountId = 7654321
+)
+
+// Server is a fake DEGIRO backend. It is safe for concurrent use.
+type Server struct {
+	server *httptest.Server
+	market *market
+	paper  *degiro.PaperClient
+
+	mu            sync.Mutex
+	sessionId     string
+	lastSessionId int
+	confirmations map[string]degiro.PlaceOrderInput
+	lastConfirmId int
+	fees          degiro.FeeModel
+
+	version      int
+	orders       map[string]*trackedOrder
+	orderIds     []string
+	positions    map[string]*trackedPosition
+	positionIds  []string
+	balance      degiro.Balance
+	balanceSince int
+
+	vwdSessions  map[string]*vwdSession
+	lastVwdId    int
+	lastVwdIndex int
+}
+
+// NewServer starts a server for an account holding cash.
+func NewServer(cash decimal.Decimal) *Server {
+	s := &Server{
+		market:        newMarket(),
+		confirmations: make(map[string]degiro.PlaceOrderInput),
+		orders:        make(map[string]*trackedOrder),
+		positions:     make(map[string]*trackedPosition),
+		vwdSessions:   make(map[string]*vwdSession),
+	}
+	s.paper = degiro.NewPaperClient(s.market, cash)
+	s.paper.OnOrderUpdate(s.recordOrderUpdate)
+	s.syncPortfolio()
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// URL is the base URL of the server.
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// SetFees sets the fees charged on fills and returned by checkOrder.
+func (s *Server) SetFees(fees degiro.FeeModel) {
+	s.mu.Lock()
+	s.fees = fees
+	s.mu.Unlock()
+	s.paper.SetFees(fees)
+}
+
+// Client returns an http.Client sending the requests of any host to the
+// server, to be given to degiro.NewClient.
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.server.URL)
+	return &http.Client{
+		Transport: rewriteTransport{target: target, base: s.server.Client().Transport},
+	}
+}
+
+type rewriteTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	r.Host = t.target.Host
+	return t.base.RoundTrip(r)
+}
+
+// ExpireSessions invalidates the trading and quotecast sessions, the next
+// requests failing with 401 and the next polls with a session expiry.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionId = ""
+	s.vwdSessions = make(map[string]*vwdSession)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path, sessionId := splitSessionId(r.URL.Path)
+	if sessionId == "" {
+		sessionId = r.URL.Query().Get("sessionId")
+	}
+	if cookie, err := r.Cookie("JSESSIONID"); sessionId == "" && err == nil {
+		sessionId = cookie.Value
+	}
+	switch {
+	case path == "/login/secure/login" && r.Method == http.MethodPost:
+		s.handleLogin(w, r)
+	case strings.HasPrefix(path, "/CORS/"):
+		s.handleQuotecast(w, r, strings.TrimPrefix(path, "/CORS/"))
+	case !s.validSession(sessionId):
+		writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"status": 401, "statusText": "unauthorized"})
+	case path == "/login/secure/config":
+		s.handleConfig(w)
+	case path == "/pa/secure/client":
+		s.handleUserConfiguration(w)
+	case strings.HasPrefix(path, "/trading/secure/v5/update/"):
+		s.handleUpdate(w, r)
+	case path == "/trading/secure/v5/checkOrder" && r.Method == http.MethodPost:
+		s.handleCheckOrder(w, r)
+	case strings.HasPrefix(path, "/trading/secure/v5/order/") && r.Method == http.MethodPost:
+		s.handleConfirmOrder(w, r, strings.TrimPrefix(path, "/trading/secure/v5/order/"))
+	case strings.HasPrefix(path, "/trading/secure/v5/order/") && r.Method == http.MethodDelete:
+		s.handleDeleteOrder(w, strings.TrimPrefix(path, "/trading/secure/v5/order/"))
+	case path == "/reporting/secure/v4/transactions":
+		s.handleTransactions(w, r)
+	case path == "/product_search/config/dictionary/":
+		s.handleDictionary(w)
+	case path == "/product_search/secure/v5/products/info" && r.Method == http.MethodPost:
+		s.handleProductInfo(w, r)
+	case strings.HasPrefix(path, "/product_search/secure/v5/"):
+		s.handleProductSearch(w, r, strings.TrimPrefix(path, "/product_search/secure/v5/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// splitSessionId removes the ;jsessionid= parameter of a path
+func splitSessionId(path string) (string, string) {
+	i := strings.Index(path, ";jsessionid=")
+	if i < 0 {
+		return path, ""
+	}
+	return path[:i], path[i+len(";jsessionid="):]
+}
+
+func (s *Server) validSession(sessionId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionId != "" && sessionId == s.sessionId
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"errors": []map[string]string{{"text": err.Error()}},
+	})
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	params := degiro.LoginParams{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, fmt.Errorf("decoding login: %v", err))
+		return
+	}
+	if params.Username != Username || params.Password != Password {
+		writeJSON(w, http.StatusBadRequest, degiro.LoginResponse{Status: 3, StatusText: "badCredentials"})
+		return
+	}
+	s.mu.Lock()
+	s.lastSessionId++
+	s.sessionId = fmt.Sprintf("SESSION%d.prod_b_112_1", s.lastSessionId)
+	sessionId := s.sessionId
+	s.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: sessionId, Path: "/"})
+	writeJSON(w, http.StatusOK, degiro.LoginResponse{
+		Locale:      "en_GB",
+		RedirectUrl: "https://trader.degiro.nl/trader/",
+		SessionId:   sessionId,
+		StatusText:  "success",
+	})
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter) {
+	s.mu.Lock()
+	sessionId := s.sessionId
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, degiro.Configuration{
+		ClientId:               ClientId,
+		SessionId:              sessionId,
+		TradingUrl:             "https://trader.degiro.nl/trading/secure/",
+		PaUrl:                  "https://trader.degiro.nl/pa/secure/",
+		ReportingUrl:           "https://trader.degiro.nl/reporting/secure/",
+		VwdQuotecastServiceUrl: "https://degiro.quotecast.vwdservices.com/CORS/",
+		ProductSearchUrl:       "https://trader.degiro.nl/product_search/secure/",
+		DictionaryUrl:          "https://trader.degiro.nl/product_search/config/dictionary/",
+		LoginUrl:               "https://trader.degiro.nl/login/nl",
+	})
+}
+
+func (s *Server) handleUserConfiguration(w http.ResponseWriter) {
+	configuration := degiro.UserConfiguration{
+		ClientId:     ClientId,
+		AccountId:    AccountId,
+		ClientRole:   "BASIC",
+		ContractType: "PRIVATE",
+		Username:     Username,
+		Locale:       "en_GB",
+		Language:     "en",
+		Culture:      "GB",
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": configuration})
+}
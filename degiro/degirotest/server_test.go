@@ -0,0 +1,155 @@
+package degirotest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro"
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func eventually(t *testing.T, condition func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", msg)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+var ing = degiro.Product{
+	Id:                    "331868",
+	Name:                  "ING Groep NV",
+	Isin:                  "NL0011821202",
+	Symbol:                "INGA",
+	ContractSize:          decimal.New(1, 0),
+	ProductTypeId:         int(degiro.Stock),
+	Tradable:              true,
+	Currency:              "EUR",
+	ExchangeId:            "200",
+	TimeTypes:             []string{"DAY", "GTC"},
+	GtcAllowed:            true,
+	BuyOrderTypes:         []string{"LIMIT", "MARKET", "STOPLOSS", "STOPLIMIT"},
+	SellOrderTypes:        []string{"LIMIT", "MARKET", "STOPLOSS", "STOPLIMIT"},
+	MarketAllowed:         true,
+	LimitHitOrderAllowed:  true,
+	StopLossAllowed:       true,
+	StopLimitOrderAllowed: true,
+	VwdId:                 "350015372",
+}
+
+func TestServer(t *testing.T) {
+	assert := assert.New(t)
+	server := NewServer(decimal.New(10000, 0))
+	defer server.Close()
+	server.SetFees(degiro.FlatFee{Fixed: decimal.New(2, 0)})
+	server.AddProduct(ing)
+	server.SetQuote(streaming.ProductQuote{
+		IssueId:   ing.VwdId,
+		FullName:  ing.Name,
+		LastPrice: decimal.New(10, 0),
+		BidPrice:  decimal.RequireFromString("9.9"),
+		AskPrice:  decimal.New(10, 0),
+	})
+
+	client := degiro.NewClient(server.Client())
+	client.UpdatePeriod = 20 * time.Millisecond
+	client.StreamingUpdatePeriod = 20 * time.Millisecond
+	assert.NotNil(client.Login(Username, "wrong"))
+	if err := client.Login(Username, Password); err != nil {
+		t.Fatal(err)
+	}
+
+	product, found, err := client.SearchProduct("inga")
+	assert.Nil(err)
+	if assert.True(found) {
+		assert.Equal(ing.Id, product.Id)
+	}
+	product2, found := client.GetProduct(ing.Id)
+	assert.True(found)
+	assert.Equal(ing.VwdId, product2.VwdId)
+	exchange, found, err := client.GetExchange(ing.ExchangeId)
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal("XAMS", exchange.MicCode)
+
+	var mu sync.Mutex
+	var quotes []streaming.ProductQuote
+	assert.Nil(client.OnQuoteUpdate(func(quote streaming.ProductQuote) {
+		mu.Lock()
+		defer mu.Unlock()
+		quotes = append(quotes, quote)
+	}))
+	assert.Nil(client.SubscribeQuotes([]string{ing.VwdId}))
+	eventually(t, func() bool {
+		return client.GetQuote(ing.VwdId).LastPrice.Equal(decimal.New(10, 0))
+	}, "the quote")
+	assert.Equal(ing.Name, client.GetQuote(ing.VwdId).FullName)
+
+	// a market order is filled at once
+	_, err = client.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Buy, OrderType: degiro.MarketOrder, ProductId: ing.Id, Quantity: 100, TimeType: degiro.Day})
+	assert.Nil(err)
+	eventually(t, func() bool {
+		position, found := client.GetOpenedPositionForProduct(ing.Id)
+		return found && position.Size == 100
+	}, "the position")
+	eventually(t, func() bool {
+		return client.GetBalance().Cash.Equal(decimal.New(8998, 0))
+	}, "the balance")
+
+	// a limit order rests until the market reaches it
+	orderId, err := client.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Sell, OrderType: degiro.Limited, ProductId: ing.Id, Quantity: 100, Price: decimal.New(11, 0), TimeType: degiro.Permanent})
+	assert.Nil(err)
+	eventually(t, func() bool {
+		return len(client.GetPendingOrders(331868)) == 1
+	}, "the pending order")
+	order := client.GetPendingOrders(331868)[0]
+	assert.Equal(orderId, order.Id)
+	assert.Equal(degiro.Sell, order.BuySell)
+	assert.Equal("11", order.Price.String())
+
+	server.SetQuote(streaming.ProductQuote{IssueId: ing.VwdId, LastPrice: decimal.New(11, 0), BidPrice: decimal.New(11, 0), AskPrice: decimal.RequireFromString("11.1")})
+	eventually(t, func() bool {
+		return len(client.GetAllPendingOrders()) == 0
+	}, "the fill")
+	eventually(t, func() bool {
+		_, found := client.GetOpenedPositionForProduct(ing.Id)
+		return !found
+	}, "the closed position")
+	eventually(t, func() bool {
+		return client.GetQuote(ing.VwdId).LastPrice.Equal(decimal.New(11, 0))
+	}, "the new quote")
+	mu.Lock()
+	assert.True(len(quotes) >= 2)
+	mu.Unlock()
+
+	transactions, err := client.GetTransactions(time.Now().AddDate(0, 0, -1), time.Now())
+	assert.Nil(err)
+	if assert.Equal(2, len(transactions)) {
+		assert.Equal(-100, transactions[1].Quantity)
+		assert.Equal("11", transactions[1].Price.String())
+		assert.Equal("-2", transactions[1].FeeInBaseCurrency.String())
+	}
+
+	// cancelled orders are removed
+	orderId, err = client.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Buy, OrderType: degiro.Limited, ProductId: ing.Id, Quantity: 10, Price: decimal.New(9, 0), TimeType: degiro.Day})
+	assert.Nil(err)
+	eventually(t, func() bool {
+		return len(client.GetAllPendingOrders()) == 1
+	}, "the pending order")
+	assert.Nil(client.DeleteOrder(orderId))
+	eventually(t, func() bool {
+		return len(client.GetAllPendingOrders()) == 0
+	}, "the cancellation")
+	assert.NotNil(client.DeleteOrder(orderId))
+	assert.Equal(0, len(server.Orders()))
+
+	// invalid orders are rejected by checkOrder
+	_, err = client.PlaceOrder(degiro.PlaceOrderInput{BuySell: degiro.Buy, OrderType: degiro.TrailingStop, ProductId: ing.Id, Quantity: 10, Trail: decimal.New(1, 0), TimeType: degiro.Day})
+	assert.NotNil(err)
+}
@@ -0,0 +1,335 @@
+package degirotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro"
+	"github.com/shopspring/decimal"
+)
+
+// trackedOrder and trackedPosition keep the version of their last change,
+// to answer the updates with the changes since the lastUpdated of the client
+type trackedOrder struct {
+	order   degiro.Order
+	added   int
+	changed int
+	removed bool
+}
+
+type trackedPosition struct {
+	size    int
+	added   int
+	changed int
+}
+
+type property struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+type updateItem struct {
+	Id        string     `json:"id"`
+	Name      string     `json:"name"`
+	IsAdded   bool       `json:"isAdded"`
+	IsRemoved bool       `json:"isRemoved"`
+	Value     []property `json:"value"`
+}
+
+type updateSection struct {
+	LastUpdated int           `json:"lastUpdated"`
+	Value       []interface{} `json:"value"`
+}
+
+func number(d decimal.Decimal) float64 {
+	f, _ := d.Float64()
+	return f
+}
+
+func shortActionType(buySell degiro.ActionType) string {
+	if buySell == degiro.Sell {
+		return "S"
+	}
+	return "B"
+}
+
+func (s *Server) recordOrderUpdate(update degiro.OrderUpdate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, order := range update.Added {
+		s.version++
+		s.orders[order.Id] = &trackedOrder{order: order, added: s.version, changed: s.version}
+		s.orderIds = append(s.orderIds, order.Id)
+	}
+	for _, id := range update.Removed {
+		if order, found := s.orders[id]; found && !order.removed {
+			s.version++
+			order.removed = true
+			order.changed = s.version
+		}
+	}
+}
+
+// syncPortfolio records the changes of the positions and balance of the paper
+// client.
+func (s *Server) syncPortfolio() {
+	transactions, _ := s.paper.GetTransactions(time.Time{}, time.Now().AddDate(1, 0, 0))
+	sizes := make(map[string]int)
+	for _, transaction := range transactions {
+		sizes[strconv.Itoa(transaction.ProductId)] += transaction.Quantity
+	}
+	balance := s.paper.GetBalance()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(sizes))
+	for id := range sizes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		position, found := s.positions[id]
+		if !found {
+			s.version++
+			s.positions[id] = &trackedPosition{size: sizes[id], added: s.version, changed: s.version}
+			s.positionIds = append(s.positionIds, id)
+			continue
+		}
+		if position.size != sizes[id] {
+			s.version++
+			position.size = sizes[id]
+			position.changed = s.version
+		}
+	}
+	if s.balanceSince == 0 || !balanceEqual(balance, s.balance) {
+		s.version++
+		s.balance = balance
+		s.balanceSince = s.version
+	}
+}
+
+func balanceEqual(a degiro.Balance, b degiro.Balance) bool {
+	return a.Cash.Equal(b.Cash) &&
+		a.FreeSpaceNewInEuros.Equal(b.FreeSpaceNewInEuros) &&
+		a.ReportPortfValue.Equal(b.ReportPortfValue) &&
+		a.ReportNetliq.Equal(b.ReportNetliq)
+}
+
+func orderProperties(order degiro.Order) []property {
+	return []property{
+		{"id", order.Id},
+		{"date", order.Date.Format("15:04")},
+		{"productId", order.ProductId},
+		{"product", order.ProductName},
+		{"contractType", 1},
+		{"contractSize", 1},
+		{"currency", order.Currency},
+		{"buysell", shortActionType(order.BuySell)},
+		{"size", order.Size},
+		{"quantity", order.Quantity},
+		{"price", number(order.Price)},
+		{"stopPrice", number(order.StopPrice)},
+		{"totalOrderValue", number(order.Price.Mul(decimal.New(int64(order.Size), 0)))},
+		{"orderTypeId", int(order.OrderType)},
+		{"orderTimeTypeId", int(order.TimeType)},
+		{"isModifiable", order.IsModifiable},
+		{"isDeletable", order.IsDeletable},
+	}
+}
+
+// orderChanges returns the orders changed since a version, all the pending
+// orders if since is 0.
+func (s *Server) orderChanges(since int) []interface{} {
+	res := []interface{}{}
+	for _, id := range s.orderIds {
+		order := s.orders[id]
+		switch {
+		case since == 0 && !order.removed:
+			res = append(res, updateItem{Id: id, Name: "order", IsAdded: true, Value: orderProperties(order.order)})
+		case since == 0 || order.changed <= since:
+		case order.removed:
+			if order.added <= since {
+				res = append(res, updateItem{Id: id, Name: "order", IsRemoved: true, Value: []property{}})
+			}
+		default:
+			res = append(res, updateItem{Id: id, Name: "order", IsAdded: order.added > since, Value: orderProperties(order.order)})
+		}
+	}
+	return res
+}
+
+func (s *Server) positionChanges(since int) []interface{} {
+	res := []interface{}{}
+	for _, id := range s.positionIds {
+		position := s.positions[id]
+		if since != 0 && position.changed <= since {
+			continue
+		}
+		productId, _ := strconv.Atoi(id)
+		res = append(res, updateItem{
+			Id:      id,
+			Name:    "positionrow",
+			IsAdded: since == 0 || position.added > since,
+			Value: []property{
+				{"id", id},
+				{"positionType", "PRODUCT"},
+				{"productId", productId},
+				{"size", position.size},
+			},
+		})
+	}
+	return res
+}
+
+func (s *Server) balanceChanges(since int) []interface{} {
+	res := []interface{}{}
+	if since != 0 && s.balanceSince <= since {
+		return res
+	}
+	for _, p := range []property{
+		{"cash", number(s.balance.Cash)},
+		{"freeSpaceNew", map[string]float64{"EUR": number(s.balance.FreeSpaceNewInEuros)}},
+		{"reportPortfValue", number(s.balance.ReportPortfValue)},
+		{"reportNetliq", number(s.balance.ReportNetliq)},
+	} {
+		res = append(res, p)
+	}
+	return res
+}
+
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	since := func(name string) int {
+		n, _ := strconv.Atoi(r.URL.Query().Get(name))
+		return n
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]updateSection{
+		"orders":         {LastUpdated: s.version, Value: s.orderChanges(since("orders"))},
+		"portfolio":      {LastUpdated: s.version, Value: s.positionChanges(since("portfolio"))},
+		"totalPortfolio": {LastUpdated: s.version, Value: s.balanceChanges(since("totalPortfolio"))},
+	})
+}
+
+type fee struct {
+	Id       int     `json:"id"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+func (s *Server) handleCheckOrder(w http.ResponseWriter, r *http.Request) {
+	var input degiro.PlaceOrderInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, fmt.Errorf("decoding order: %v", err))
+		return
+	}
+	product, found := s.market.GetProduct(input.ProductId)
+	if !found {
+		writeError(w, fmt.Errorf("product %s not found", input.ProductId))
+		return
+	}
+	if err := degiro.ValidateOrder(input, product); err != nil {
+		writeError(w, err)
+		return
+	}
+	price := input.Price
+	if price.IsZero() {
+		quote := s.market.GetQuote(product.VwdId)
+		price = quote.LastPrice
+		if input.BuySell == degiro.Buy && quote.AskPrice.IsPositive() {
+			price = quote.AskPrice
+		}
+	}
+	s.mu.Lock()
+	model := s.fees
+	s.mu.Unlock()
+	fees := []fee{}
+	if model != nil {
+		fees = append(fees, fee{Id: 2, Amount: number(model.Fee(product, input, price)), Currency: "EUR"})
+	}
+	freeSpace := s.paper.GetBalance().FreeSpaceNewInEuros
+	if input.BuySell == degiro.Buy {
+		freeSpace = freeSpace.Sub(price.Mul(decimal.New(int64(input.Quantity), 0)))
+	}
+
+	s.mu.Lock()
+	s.lastConfirmId++
+	confirmationId := fmt.Sprintf("confirmation-%d", s.lastConfirmId)
+	s.confirmations[confirmationId] = input
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{
+			"confirmationId":  confirmationId,
+			"freeSpaceNew":    number(freeSpace),
+			"transactionFees": fees,
+		},
+	})
+}
+
+func (s *Server) handleConfirmOrder(w http.ResponseWriter, r *http.Request, confirmationId string) {
+	s.mu.Lock()
+	input, found := s.confirmations[confirmationId]
+	delete(s.confirmations, confirmationId)
+	s.mu.Unlock()
+	if !found {
+		writeError(w, fmt.Errorf("unknown confirmation %s", confirmationId))
+		return
+	}
+	orderId, err := s.paper.PlaceOrder(input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.syncPortfolio()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]string{"orderId": orderId}})
+}
+
+func (s *Server) handleDeleteOrder(w http.ResponseWriter, orderId string) {
+	if err := s.paper.DeleteOrder(orderId); err != nil {
+		writeError(w, err)
+		return
+	}
+	s.syncPortfolio()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{}})
+}
+
+func (s *Server) handleTransactions(w http.ResponseWriter, r *http.Request) {
+	from, err := time.ParseInLocation("02/01/2006", r.URL.Query().Get("fromDate"), time.Local)
+	if err != nil {
+		writeError(w, fmt.Errorf("parsing fromDate: %v", err))
+		return
+	}
+	to, err := time.ParseInLocation("02/01/2006", r.URL.Query().Get("toDate"), time.Local)
+	if err != nil {
+		writeError(w, fmt.Errorf("parsing toDate: %v", err))
+		return
+	}
+	transactions, err := s.paper.GetTransactions(from, to.AddDate(0, 0, 1).Add(-time.Nanosecond))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if transactions == nil {
+		transactions = []degiro.Transaction{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": transactions})
+}
+
+// Orders returns the pending orders.
+func (s *Server) Orders() []degiro.Order {
+	return s.paper.GetAllPendingOrders()
+}
+
+// Position returns the size of the position of a product.
+func (s *Server) Position(productId string) int {
+	position, _ := s.paper.GetOpenedPositionForProduct(productId)
+	return position.Size
+}
+
+func (s *Server) Balance() degiro.Balance {
+	return s.paper.GetBalance()
+}
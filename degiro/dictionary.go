@@ -0,0 +1,178 @@
+package degiro
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dghubble/sling"
+)
+
+type Region struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type Country struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Region Region `json:"region"`
+}
+
+type Exchange struct {
+	Id           int          `json:"id"`
+	Code         string       `json:"code"`
+	HiqAbbr      string       `json:"hiqAbbr"`
+	Country      string       `json:"country"`
+	City         string       `json:"city"`
+	MicCode      string       `json:"micCode"`
+	Name         string       `json:"name"`
+	TradingHours TradingHours `json:"-"`
+}
+
+type ProductTypeInfo struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Translation string `json:"translation"`
+}
+
+type IndexInfo struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type CurrencyInfo struct {
+	Id     int    `json:"id"`
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	Symbol string `json:"symbol"`
+}
+
+// Dictionary is the reference data used by DEGIRO to describe products.
+type Dictionary struct {
+	Exchanges    []Exchange        `json:"exchanges"`
+	Countries    []Country         `json:"countries"`
+	Regions      []Region          `json:"regions"`
+	ProductTypes []ProductTypeInfo `json:"productTypes"`
+	Indices      []IndexInfo       `json:"indices"`
+	Currencies   []CurrencyInfo    `json:"currencies"`
+}
+
+func (d *Dictionary) Exchange(exchangeId string) (Exchange, bool) {
+	id, err := strconv.Atoi(exchangeId)
+	if err != nil {
+		return Exchange{}, false
+	}
+	for _, exchange := range d.Exchanges {
+		if exchange.Id == id {
+			return exchange, true
+		}
+	}
+	return Exchange{}, false
+}
+
+func (d *Dictionary) ExchangeByMic(mic string) (Exchange, bool) {
+	for _, exchange := range d.Exchanges {
+		if exchange.MicCode == mic {
+			return exchange, true
+		}
+	}
+	return Exchange{}, false
+}
+
+func (d *Dictionary) Country(name string) (Country, bool) {
+	for _, country := range d.Countries {
+		if country.Name == name {
+			return country, true
+		}
+	}
+	return Country{}, false
+}
+
+func (d *Dictionary) ProductType(id int) (ProductTypeInfo, bool) {
+	for _, productType := range d.ProductTypes {
+		if productType.Id == id {
+			return productType, true
+		}
+	}
+	return ProductTypeInfo{}, false
+}
+
+func (d *Dictionary) Index(id int) (IndexInfo, bool) {
+	for _, index := range d.Indices {
+		if index.Id == id {
+			return index, true
+		}
+	}
+	return IndexInfo{}, false
+}
+
+func (d *Dictionary) Currency(code string) (CurrencyInfo, bool) {
+	for _, currency := range d.Currencies {
+		if currency.Code == code {
+			return currency, true
+		}
+	}
+	return CurrencyInfo{}, false
+}
+
+type dictionaryCache struct {
+	sync.Mutex
+	dictionary *Dictionary
+	loadedAt   time.Time
+}
+
+func (c *Client) getDictionary() (*Dictionary, error) {
+	if c.configuration == nil || c.configuration.DictionaryUrl == "" {
+		return nil, fmt.Errorf("no dictionary url, client is not logged in")
+	}
+	dictionary := &Dictionary{}
+	_, err := c.receiveSuccessReloginOn401(func() *sling.Sling {
+		return c.sling.New().
+			Get(c.configuration.DictionaryUrl).
+			QueryStruct(&struct {
+				AccountId int64  `url:"intAccount"`
+				SessionId string `url:"sessionId"`
+			}{
+				AccountId: c.accountId,
+				SessionId: c.session(),
+			})
+	}, dictionary)
+	if err != nil {
+		return nil, err
+	}
+	for i, exchange := range dictionary.Exchanges {
+		dictionary.Exchanges[i].TradingHours = DefaultTradingHours[exchange.MicCode]
+	}
+	return dictionary, nil
+}
+
+// GetDictionary returns the product dictionary, loading it if it was never
+// loaded or if it is older than DictionaryCacheDuration.
+func (c *Client) GetDictionary() (*Dictionary, error) {
+	c.dictionary.Lock()
+	defer c.dictionary.Unlock()
+	if c.dictionary.dictionary != nil && time.Now().Sub(c.dictionary.loadedAt) < c.DictionaryCacheDuration {
+		return c.dictionary.dictionary, nil
+	}
+	dictionary, err := c.getDictionary()
+	if err != nil {
+		if c.dictionary.dictionary != nil {
+			return c.dictionary.dictionary, nil
+		}
+		return nil, fmt.Errorf("getting dictionary: %v", err)
+	}
+	c.dictionary.dictionary = dictionary
+	c.dictionary.loadedAt = time.Now()
+	return dictionary, nil
+}
+
+func (c *Client) GetExchange(exchangeId string) (Exchange, bool, error) {
+	dictionary, err := c.GetDictionary()
+	if err != nil {
+		return Exchange{}, false, err
+	}
+	exchange, found := dictionary.Exchange(exchangeId)
+	return exchange, found, nil
+}
@@ -0,0 +1,65 @@
+package degiro
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetExchange(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		requests++
+		assert.Equal("https://trader.degiro.nl/product_search/config/dictionary/?intAccount=12345678&sessionId=abc", req.URL.String())
+		return &http.Response{
+			StatusCode: 200,
+			Body: ioutil.NopCloser(bytes.NewBufferString(`{` +
+				`"exchanges":[{"id":200,"code":"EAM","hiqAbbr":"EAM","country":"NL","city":"Amsterdam","micCode":"XAMS","name":"Euronext Amsterdam"}],` +
+				`"countries":[{"id":846,"name":"NL","region":{"id":1,"name":"europe"}}],` +
+				`"regions":[{"id":1,"name":"europe"}],` +
+				`"productTypes":[{"id":1,"name":"STOCK","translation":"list.producttype.stock"}],` +
+				`"indices":[{"id":121,"name":"AEX"}]}`)),
+			Header: getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.accountId = 12345678
+	degiro.sessionId = "abc"
+	degiro.configuration = &Configuration{DictionaryUrl: "https://trader.degiro.nl/product_search/config/dictionary/"}
+
+	exchange, found, err := degiro.GetExchange("200")
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal("XAMS", exchange.MicCode)
+	assert.Equal("Euronext Amsterdam", exchange.Name)
+	assert.Equal(9*time.Hour, exchange.TradingHours.Open)
+
+	_, found, err = degiro.GetExchange("1")
+	assert.Nil(err)
+	assert.False(found)
+	assert.Equal(1, requests)
+
+	dictionary, err := degiro.GetDictionary()
+	assert.Nil(err)
+	country, found := dictionary.Country("NL")
+	assert.True(found)
+	assert.Equal("europe", country.Region.Name)
+	productType, found := dictionary.ProductType(int(Stock))
+	assert.True(found)
+	assert.Equal("STOCK", productType.Name)
+}
+
+func TestTradingHours_IsOpen(t *testing.T) {
+	assert := assert.New(t)
+	hours := TradingHours{Timezone: "UTC", Open: clock(9, 0), Close: clock(17, 30)}
+	assert.True(hours.IsOpen(time.Date(2019, 10, 10, 9, 0, 0, 0, time.UTC)))
+	assert.True(hours.IsOpen(time.Date(2019, 10, 10, 17, 29, 59, 0, time.UTC)))
+	assert.False(hours.IsOpen(time.Date(2019, 10, 10, 17, 30, 0, 0, time.UTC)))
+	assert.False(hours.IsOpen(time.Date(2019, 10, 12, 12, 0, 0, 0, time.UTC)))
+	assert.False(TradingHours{}.IsOpen(time.Date(2019, 10, 10, 12, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,212 @@
+package degiro
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/shopspring/decimal"
+)
+
+type FeeProfile string
+
+const (
+	BasicProfile  FeeProfile = "basic"
+	ActiveProfile FeeProfile = "active"
+	TraderProfile FeeProfile = "trader"
+)
+
+// FeeRule is the fee of the orders on some exchanges and product types: a
+// fixed commission, a rate of the order value and an amount per unit, bounded
+// by Min and Max when positive, plus the handling fee charged on every
+// order. Empty Exchanges or ProductTypes match all of them.
+type FeeRule struct {
+	// Exchanges are exchange ids or MIC codes.
+	Exchanges    []string        `json:"exchanges"`
+	ProductTypes []ProductType   `json:"productTypes"`
+	Fixed        decimal.Decimal `json:"fixed"`
+	Rate         decimal.Decimal `json:"rate"`
+	PerUnit      decimal.Decimal `json:"perUnit"`
+	Min          decimal.Decimal `json:"min"`
+	Max          decimal.Decimal `json:"max"`
+	HandlingFee  decimal.Decimal `json:"handlingFee"`
+}
+
+func (r FeeRule) matches(product Product, mic string) bool {
+	if len(r.ProductTypes) > 0 {
+		found := false
+		for _, productType := range r.ProductTypes {
+			if int(productType) == product.ProductTypeId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Exchanges) == 0 {
+		return true
+	}
+	for _, exchange := range r.Exchanges {
+		if exchange == product.ExchangeId || (mic != "" && exchange == mic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r FeeRule) commission(quantity int, value decimal.Decimal) decimal.Decimal {
+	fee := r.Fixed.
+		Add(value.Mul(r.Rate)).
+		Add(r.PerUnit.Mul(decimal.New(int64(quantity), 0)))
+	if r.Min.IsPositive() && fee.LessThan(r.Min) {
+		fee = r.Min
+	}
+	if r.Max.IsPositive() && fee.GreaterThan(r.Max) {
+		fee = r.Max
+	}
+	return fee
+}
+
+// FeeSchedule is the price list of an account profile, the first matching
+// rule giving the fee of an order. Orders of at least CoreSelectionMinValue
+// on the products of CoreSelection, by id or ISIN, only pay the handling
+// fee. DEGIRO grants this free trade once a month per product, which is not
+// tracked. All amounts are considered to be in the base currency.
+type FeeSchedule struct {
+	Profile               FeeProfile      `json:"profile"`
+	Rules                 []FeeRule       `json:"rules"`
+	CoreSelection         []string        `json:"coreSelection"`
+	CoreSelectionMinValue decimal.Decimal `json:"coreSelectionMinValue"`
+}
+
+var (
+	usMics     = []string{"XNYS", "XNAS", "ARCX", "BATS"}
+	stockTypes = []ProductType{Stock, Etf, Fund}
+)
+
+func defaultFeeSchedule(profile FeeProfile, perContract decimal.Decimal) FeeSchedule {
+	one := decimal.New(1, 0)
+	return FeeSchedule{
+		Profile: profile,
+		Rules: []FeeRule{
+			{Exchanges: usMics, ProductTypes: stockTypes, Fixed: one, HandlingFee: one},
+			{ProductTypes: stockTypes, Fixed: decimal.New(2, 0), HandlingFee: one},
+			{ProductTypes: []ProductType{Option, Future}, PerUnit: perContract},
+			{ProductTypes: []ProductType{Bond}, Fixed: decimal.New(2, 0), Rate: decimal.New(3, -4), HandlingFee: one},
+			{Fixed: decimal.New(2, 0), HandlingFee: one},
+		},
+		CoreSelectionMinValue: decimal.New(1000, 0),
+	}
+}
+
+// DefaultFeeSchedules approximate the DEGIRO price lists of each profile, the
+// profiles differing by their fees on derivatives. They should be checked
+// against the price list of the account and the core selection filled in,
+// see LoadFeeSchedules.
+var DefaultFeeSchedules = map[FeeProfile]FeeSchedule{
+	BasicProfile:  defaultFeeSchedule(BasicProfile, decimal.New(75, -2)),
+	ActiveProfile: defaultFeeSchedule(ActiveProfile, decimal.New(50, -2)),
+	TraderProfile: defaultFeeSchedule(TraderProfile, decimal.New(25, -2)),
+}
+
+// LoadFeeSchedules reads fee schedules from a JSON array, e.g.
+// [{"profile": "basic", "rules": [{"productTypes": [1], "fixed": "2"}]}].
+func LoadFeeSchedules(r io.Reader) (map[FeeProfile]FeeSchedule, error) {
+	var schedules []FeeSchedule
+	if err := json.NewDecoder(r).Decode(&schedules); err != nil {
+		return nil, fmt.Errorf("decoding fee schedules: %v", err)
+	}
+	res := make(map[FeeProfile]FeeSchedule)
+	for _, schedule := range schedules {
+		if schedule.Profile == "" {
+			return nil, fmt.Errorf("fee schedule without profile")
+		}
+		if _, found := res[schedule.Profile]; found {
+			return nil, fmt.Errorf("duplicate fee schedule %s", schedule.Profile)
+		}
+		res[schedule.Profile] = schedule
+	}
+	return res, nil
+}
+
+func (s FeeSchedule) isCoreSelection(product Product) bool {
+	for _, id := range s.CoreSelection {
+		if id == product.Id || (product.Isin != "" && id == product.Isin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fee implements FeeModel, matching the exchanges of the rules by id only.
+// Use Client.FeeModel to match them by MIC code too.
+func (s FeeSchedule) Fee(product Product, input PlaceOrderInput, price decimal.Decimal) decimal.Decimal {
+	return s.ExchangeFee("", product, input, price)
+}
+
+// ExchangeFee returns the fee of input filled at price on the exchange of
+// product, whose MIC code is mic.
+func (s FeeSchedule) ExchangeFee(mic string, product Product, input PlaceOrderInput, price decimal.Decimal) decimal.Decimal {
+	var rule FeeRule
+	found := false
+	for _, r := range s.Rules {
+		if r.matches(product, mic) {
+			rule = r
+			found = true
+			break
+		}
+	}
+	if !found {
+		return decimal.Zero
+	}
+	value := price.Mul(decimal.New(int64(input.Quantity), 0))
+	if input.OrderType == StandardAmount && input.Quantity == 0 {
+		value = input.Amount
+	}
+	if product.ContractSize.IsPositive() {
+		value = value.Mul(product.ContractSize)
+	}
+	if s.isCoreSelection(product) && value.GreaterThanOrEqual(s.CoreSelectionMinValue) {
+		return rule.HandlingFee
+	}
+	return rule.commission(input.Quantity, value).Add(rule.HandlingFee)
+}
+
+type clientFeeModel struct {
+	client   *Client
+	schedule FeeSchedule
+}
+
+func (m clientFeeModel) Fee(product Product, input PlaceOrderInput, price decimal.Decimal) decimal.Decimal {
+	mic := ""
+	if exchange, found, err := m.client.GetExchange(product.ExchangeId); err == nil && found {
+		mic = exchange.MicCode
+	}
+	return m.schedule.ExchangeFee(mic, product, input, price)
+}
+
+// FeeModel returns a FeeModel using schedule, matching the exchanges of its
+// rules by id or MIC code.
+func (c *Client) FeeModel(schedule FeeSchedule) FeeModel {
+	return clientFeeModel{client: c, schedule: schedule}
+}
+
+// EstimateOrderFee returns the fee schedule would charge for input, valuing
+// market orders at the last price of the product or at its close price.
+func (c *Client) EstimateOrderFee(schedule FeeSchedule, input PlaceOrderInput) (decimal.Decimal, error) {
+	product, found := c.GetProduct(input.ProductId)
+	if !found {
+		return decimal.Zero, fmt.Errorf("product %s not found", input.ProductId)
+	}
+	exchange, _, err := c.GetExchange(product.ExchangeId)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("getting exchange: %v", err)
+	}
+	price := firstPositive(input.Price, input.StopPrice, c.GetQuote(product.VwdId).LastPrice, product.ClosePrice)
+	if !price.IsPositive() && input.OrderType != StandardAmount {
+		return decimal.Zero, fmt.Errorf("no price for product %s", input.ProductId)
+	}
+	return schedule.ExchangeFee(exchange.MicCode, product, input, price), nil
+}
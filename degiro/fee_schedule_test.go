@@ -0,0 +1,72 @@
+package degiro
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_EstimateOrderFee(t *testing.T) {
+	assert := assert.New(t)
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"exchanges":[{"id":663,"micCode":"XNAS"},{"id":200,"micCode":"XAMS"}]}`)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.configuration = &Configuration{DictionaryUrl: "https://trader.degiro.nl/product_search/config/dictionary/"}
+	schedules, err := LoadFeeSchedules(strings.NewReader(`[{"profile":"custom","rules":[` +
+		`{"exchanges":["XNAS"],"productTypes":[1],"rate":"0.001","handlingFee":"0.5"},` +
+		`{"fixed":"3"}]}]`))
+	assert.Nil(err)
+	schedule := schedules["custom"]
+	usStock := Product{Id: "331941", ExchangeId: "663", ProductTypeId: int(Stock), ClosePrice: decimal.New(190, 0)}
+	degiro.products.add(usStock)
+	degiro.products.add(Product{Id: "331868", ExchangeId: "200", ProductTypeId: int(Stock)})
+
+	// the rule is matched by MIC code, the order valued at its limit, then
+	// at its stop, then at the close price without a quote
+	input := PlaceOrderInput{BuySell: Buy, OrderType: Limited, ProductId: usStock.Id, Quantity: 10, Price: decimal.New(200, 0)}
+	fee, err := degiro.EstimateOrderFee(schedule, input)
+	assert.Nil(err)
+	assert.Equal("2.5", fee.String())
+	input = PlaceOrderInput{BuySell: Sell, OrderType: StopLoss, ProductId: usStock.Id, Quantity: 10, StopPrice: decimal.New(180, 0)}
+	fee, err = degiro.EstimateOrderFee(schedule, input)
+	assert.Nil(err)
+	assert.Equal("2.3", fee.String())
+	input = PlaceOrderInput{BuySell: Buy, OrderType: MarketOrder, ProductId: usStock.Id, Quantity: 10}
+	fee, err = degiro.EstimateOrderFee(schedule, input)
+	assert.Nil(err)
+	assert.Equal("2.4", fee.String())
+	// the exchange id alone does not match the MIC code of the rule
+	assert.Equal("3", schedule.Fee(usStock, input, decimal.New(190, 0)).String())
+
+	// no price to value the order at
+	input = PlaceOrderInput{BuySell: Buy, OrderType: MarketOrder, ProductId: "331868", Quantity: 10}
+	_, err = degiro.EstimateOrderFee(schedule, input)
+	assert.NotNil(err)
+}
+
+func TestFeeRule_Bounds(t *testing.T) {
+	assert := assert.New(t)
+	schedules, err := LoadFeeSchedules(strings.NewReader(`[{"profile":"custom","rules":[` +
+		`{"productTypes":[2],"rate":"0.001","min":"5","max":"30","handlingFee":"1"}]}]`))
+	assert.Nil(err)
+	schedule := schedules["custom"]
+	bond := Product{Id: "1", ProductTypeId: int(Bond)}
+	input := PlaceOrderInput{BuySell: Sell, OrderType: Limited, Quantity: 10}
+	assert.Equal("6", schedule.Fee(bond, input, decimal.New(100, 0)).String())
+	assert.Equal("21", schedule.Fee(bond, input, decimal.New(2000, 0)).String())
+	assert.Equal("31", schedule.Fee(bond, input, decimal.New(5000, 0)).String())
+	assert.Equal("0", schedule.Fee(Product{ProductTypeId: int(Stock)}, input, decimal.New(100, 0)).String())
+
+	_, err = LoadFeeSchedules(strings.NewReader(`[{"rules":[]}]`))
+	assert.NotNil(err)
+}
@@ -135,8 +135,11 @@ func (p *HistoricalPosition) GetLastTransactionDate() time.Time {
 	return p.transactions[len(p.transactions)-1].Date
 }
 
-func getHistoricalPositionsFromTransactions(transactions []Transaction) []HistoricalPosition {
+// HistoricalPositionsFromTransactions groups transactions by product into
+// positions, a new position starting each time the size gets back to zero.
+func HistoricalPositionsFromTransactions(transactions []Transaction) []HistoricalPosition {
 	tmap := make(map[int][]Transaction)
+	transactions = append([]Transaction(nil), transactions...)
 	sortTransactionsByDateAscending(transactions)
 	for _, t := range transactions {
 		tmap[t.ProductId] = append(tmap[t.ProductId], t)
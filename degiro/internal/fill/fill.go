@@ -0,0 +1,53 @@
+// Package fill holds the fill rules shared by the simulated brokers.
+package fill
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// Fill is an order filled for a quantity at a price, signed the way DeGiro
+// reports its transactions: sells have a negative quantity, totals are the
+// cash received and fees are negative.
+type Fill struct {
+	// BuySell is "B" or "S".
+	BuySell      string
+	Quantity     int
+	Total        decimal.Decimal
+	Fee          decimal.Decimal
+	TotalPlusFee decimal.Decimal
+}
+
+func New(sell bool, quantity int, price decimal.Decimal, fee decimal.Decimal) Fill {
+	signed := quantity
+	side := "B"
+	if sell {
+		signed = -quantity
+		side = "S"
+	}
+	total := price.Mul(decimal.New(int64(-signed), 0))
+	return Fill{
+		BuySell:      side,
+		Quantity:     signed,
+		Total:        total,
+		Fee:          fee.Neg(),
+		TotalPlusFee: total.Sub(fee),
+	}
+}
+
+// Check returns an error if filling an order for quantity at price would
+// make cash negative or sell more than held.
+func Check(sell bool, quantity int, price decimal.Decimal, fee decimal.Decimal, cash decimal.Decimal, held int) error {
+	if sell {
+		if quantity > held {
+			return fmt.Errorf("insufficient position: %d to sell, %d held", quantity, held)
+		}
+		return nil
+	}
+	cost := price.Mul(decimal.New(int64(quantity), 0)).Add(fee)
+	if cost.GreaterThan(cash) {
+		return fmt.Errorf("insufficient cash: %s needed, %s available", cost, cash)
+	}
+	return nil
+}
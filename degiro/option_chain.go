@@ -0,0 +1,182 @@
+package degiro
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type OptionStrike struct {
+	Strike decimal.Decimal
+	Call   *OptionProduct
+	Put    *OptionProduct
+}
+
+type OptionExpiry struct {
+	Date    time.Time
+	Strikes []OptionStrike
+}
+
+// OptionChain holds the options of an underlying, by expiry then strike,
+// both in ascending order.
+type OptionChain struct {
+	Underlying Product
+	Expiries   []OptionExpiry
+}
+
+// GetOptionChain retrieves all the options listed on the underlying.
+func (c *Client) GetOptionChain(underlying Product) (*OptionChain, error) {
+	if underlying.Isin == "" {
+		return nil, fmt.Errorf("underlying %s has no isin", underlying.Id)
+	}
+	var options []OptionProduct
+	it := c.IterateOptions(OptionSearchOptions{
+		UnderlyingIsin: underlying.Isin,
+	})
+	for it.Next() {
+		option := OptionProduct{}
+		if err := it.Scan(&option); err != nil {
+			return nil, fmt.Errorf("decoding option: %v", err)
+		}
+		options = append(options, option)
+	}
+	if it.Err() != nil {
+		return nil, fmt.Errorf("searching options: %v", it.Err())
+	}
+	chain := BuildOptionChain(underlying, options)
+	return &chain, nil
+}
+
+// BuildOptionChain organizes options in a chain. Options which are neither
+// calls nor puts are ignored.
+func BuildOptionChain(underlying Product, options []OptionProduct) OptionChain {
+	chain := OptionChain{
+		Underlying: underlying,
+	}
+	for i := range options {
+		option := options[i]
+		if !option.IsCall() && !option.IsPut() {
+			continue
+		}
+		expiry := chain.expiry(option.ExpirationDate.Time)
+		strike := expiry.strike(option.StrikePrice)
+		if option.IsCall() {
+			strike.Call = &option
+		} else {
+			strike.Put = &option
+		}
+	}
+	sort.Slice(chain.Expiries, func(i, j int) bool {
+		return chain.Expiries[i].Date.Before(chain.Expiries[j].Date)
+	})
+	for _, expiry := range chain.Expiries {
+		strikes := expiry.Strikes
+		sort.Slice(strikes, func(i, j int) bool {
+			return strikes[i].Strike.LessThan(strikes[j].Strike)
+		})
+	}
+	return chain
+}
+
+func sameDay(a time.Time, b time.Time) bool {
+	ya, ma, da := a.Date()
+	yb, mb, db := b.Date()
+	return ya == yb && ma == mb && da == db
+}
+
+func (c *OptionChain) expiry(date time.Time) *OptionExpiry {
+	for i := range c.Expiries {
+		if sameDay(c.Expiries[i].Date, date) {
+			return &c.Expiries[i]
+		}
+	}
+	c.Expiries = append(c.Expiries, OptionExpiry{Date: date})
+	return &c.Expiries[len(c.Expiries)-1]
+}
+
+func (e *OptionExpiry) strike(price decimal.Decimal) *OptionStrike {
+	for i := range e.Strikes {
+		if e.Strikes[i].Strike.Equal(price) {
+			return &e.Strikes[i]
+		}
+	}
+	e.Strikes = append(e.Strikes, OptionStrike{Strike: price})
+	return &e.Strikes[len(e.Strikes)-1]
+}
+
+// Expiry returns the expiry on the same day as date.
+func (c OptionChain) Expiry(date time.Time) (OptionExpiry, bool) {
+	for _, expiry := range c.Expiries {
+		if sameDay(expiry.Date, date) {
+			return expiry, true
+		}
+	}
+	return OptionExpiry{}, false
+}
+
+// NearestExpiry returns the first expiry on or after t.
+func (c OptionChain) NearestExpiry(t time.Time) (OptionExpiry, bool) {
+	for _, expiry := range c.Expiries {
+		if sameDay(expiry.Date, t) || expiry.Date.After(t) {
+			return expiry, true
+		}
+	}
+	return OptionExpiry{}, false
+}
+
+// NearestStrike returns the strike closest to price, the lower one in case
+// of tie.
+func (e OptionExpiry) NearestStrike(price decimal.Decimal) (OptionStrike, bool) {
+	if len(e.Strikes) == 0 {
+		return OptionStrike{}, false
+	}
+	best := e.Strikes[0]
+	for _, strike := range e.Strikes[1:] {
+		if strike.Strike.Sub(price).Abs().LessThan(best.Strike.Sub(price).Abs()) {
+			best = strike
+		}
+	}
+	return best, true
+}
+
+func (e OptionExpiry) Calls() []OptionProduct {
+	var res []OptionProduct
+	for _, strike := range e.Strikes {
+		if strike.Call != nil {
+			res = append(res, *strike.Call)
+		}
+	}
+	return res
+}
+
+func (e OptionExpiry) Puts() []OptionProduct {
+	var res []OptionProduct
+	for _, strike := range e.Strikes {
+		if strike.Put != nil {
+			res = append(res, *strike.Put)
+		}
+	}
+	return res
+}
+
+// Find returns the call or the put with the given expiry day and strike.
+func (c OptionChain) Find(expiry time.Time, strike decimal.Decimal, call bool) (OptionProduct, bool) {
+	e, found := c.Expiry(expiry)
+	if !found {
+		return OptionProduct{}, false
+	}
+	for _, s := range e.Strikes {
+		if !s.Strike.Equal(strike) {
+			continue
+		}
+		if call && s.Call != nil {
+			return *s.Call, true
+		}
+		if !call && s.Put != nil {
+			return *s.Put, true
+		}
+	}
+	return OptionProduct{}, false
+}
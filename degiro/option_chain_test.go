@@ -0,0 +1,64 @@
+package degiro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestOption(id string, name string, strike int64, expiry time.Time) OptionProduct {
+	return OptionProduct{
+		Product: Product{
+			Id:             id,
+			Name:           name,
+			StrikePrice:    decimal.New(strike, 0),
+			ExpirationDate: productTime{expiry},
+		},
+	}
+}
+
+func TestBuildOptionChain(t *testing.T) {
+	assert := assert.New(t)
+	oct := time.Date(2019, 10, 18, 0, 0, 0, 0, time.UTC)
+	nov := time.Date(2019, 11, 15, 0, 0, 0, 0, time.UTC)
+	options := []OptionProduct{
+		newTestOption("1", "AEX C580.00 15NOV19", 580, nov),
+		newTestOption("2", "AEX C560.00 18OCT19", 560, oct),
+		newTestOption("3", "AEX P560.00 18OCT19", 560, oct),
+		newTestOption("4", "AEX C540.00 18OCT19", 540, oct),
+		newTestOption("5", "AEX FUTURE", 0, oct),
+	}
+	options[4].PutCall = ""
+	options[0].PutCall = "CALL"
+
+	chain := BuildOptionChain(Product{Id: "360114899", Isin: "NL0000000107"}, options)
+	if !assert.Equal(2, len(chain.Expiries)) {
+		return
+	}
+	assert.Equal(oct, chain.Expiries[0].Date)
+	assert.Equal(2, len(chain.Expiries[0].Strikes))
+	assert.True(decimal.New(540, 0).Equal(chain.Expiries[0].Strikes[0].Strike))
+	assert.Equal(2, len(chain.Expiries[0].Calls()))
+	assert.Equal(1, len(chain.Expiries[0].Puts()))
+
+	expiry, found := chain.NearestExpiry(time.Date(2019, 10, 20, 10, 0, 0, 0, time.UTC))
+	assert.True(found)
+	assert.Equal(nov, expiry.Date)
+	_, found = chain.NearestExpiry(time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC))
+	assert.False(found)
+
+	strike, found := chain.Expiries[0].NearestStrike(decimal.New(555, 0))
+	assert.True(found)
+	assert.True(decimal.New(560, 0).Equal(strike.Strike))
+	if assert.NotNil(strike.Put) {
+		assert.Equal("3", strike.Put.Id)
+	}
+
+	put, found := chain.Find(oct, decimal.New(560, 0), false)
+	assert.True(found)
+	assert.Equal("3", put.Id)
+	_, found = chain.Find(oct, decimal.New(540, 0), false)
+	assert.False(found)
+}
@@ -1,10 +1,12 @@
 package degiro
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/dghubble/sling"
 	"github.com/shopspring/decimal"
 )
 
@@ -64,6 +66,14 @@ cacheloop:
 	}
 }
 
+func (c *OrderCache) GetAll() []Order {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res := make([]Order, len(c.cache))
+	copy(res, c.cache)
+	return res
+}
+
 func (c *OrderCache) Get(productId int) []Order {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -87,10 +97,15 @@ const (
 type OrderType int
 
 const (
-	Limited     OrderType = 0
-	MarketOrder OrderType = 2
-	StopLoss    OrderType = 3
-	StopLimited OrderType = 1
+	Limited      OrderType = 0
+	MarketOrder  OrderType = 2
+	StopLoss     OrderType = 3
+	StopLimited  OrderType = 1
+	TrailingStop OrderType = 13
+	// StandardAmount is a market order for an amount of money instead of a
+	// number of shares, see Product.SellAmountAllowed.
+	StandardAmount OrderType = 14
+	StandardSize   OrderType = 15
 )
 
 type TimeType int
@@ -98,6 +113,9 @@ type TimeType int
 const (
 	Day       TimeType = 1
 	Permanent TimeType = 3
+
+	GoodTillDay       = Day
+	GoodTillCancelled = Permanent
 )
 
 type Fee struct {
@@ -118,13 +136,90 @@ type checkOrderResponse struct {
 }
 
 type PlaceOrderInput struct {
-	BuySell   ActionType      `json:"buySell"`
-	OrderType OrderType       `json:"orderType"`
-	ProductId string          `json:"productId"`
-	Quantity  int             `json:"size"`
-	TimeType  TimeType        `json:"timeType"`
-	Price     decimal.Decimal `json:"price"`
-	StopPrice decimal.Decimal `json:"stopPrice"`
+	BuySell   ActionType
+	OrderType OrderType
+	ProductId string
+	Quantity  int
+	TimeType  TimeType
+	Price     decimal.Decimal
+	StopPrice decimal.Decimal
+	// Amount is the amount of money of StandardAmount orders.
+	Amount decimal.Decimal
+	// Trail is the distance of TrailingStop orders to the best price, in
+	// price or, if TrailPercentage is set, in percent of the price.
+	Trail           decimal.Decimal
+	TrailPercentage bool
+}
+
+// MarshalJSON writes the checkOrder and confirmOrder payload, omitting the
+// fields that are not used by the order type. The trail of trailing stops is
+// sent as the stop price.
+func (i PlaceOrderInput) MarshalJSON() ([]byte, error) {
+	payload := struct {
+		BuySell                ActionType       `json:"buySell"`
+		OrderType              OrderType        `json:"orderType"`
+		ProductId              string           `json:"productId"`
+		Size                   int              `json:"size,omitempty"`
+		TimeType               TimeType         `json:"timeType"`
+		Price                  *decimal.Decimal `json:"price,omitempty"`
+		StopPrice              *decimal.Decimal `json:"stopPrice,omitempty"`
+		Amount                 *decimal.Decimal `json:"amount,omitempty"`
+		TrailingStopPercentage bool             `json:"trailingStopPercentage,omitempty"`
+	}{
+		BuySell:   i.BuySell,
+		OrderType: i.OrderType,
+		ProductId: i.ProductId,
+		Size:      i.Quantity,
+		TimeType:  i.TimeType,
+	}
+	if !i.Price.IsZero() {
+		payload.Price = &i.Price
+	}
+	if !i.StopPrice.IsZero() {
+		payload.StopPrice = &i.StopPrice
+	}
+	if !i.Amount.IsZero() {
+		payload.Amount = &i.Amount
+	}
+	if i.OrderType == TrailingStop && !i.Trail.IsZero() {
+		payload.StopPrice = &i.Trail
+		payload.TrailingStopPercentage = i.TrailPercentage
+	}
+	return json.Marshal(payload)
+}
+
+// UnmarshalJSON reads the payload written by MarshalJSON.
+func (i *PlaceOrderInput) UnmarshalJSON(data []byte) error {
+	payload := struct {
+		BuySell                ActionType      `json:"buySell"`
+		OrderType              OrderType       `json:"orderType"`
+		ProductId              string          `json:"productId"`
+		Size                   int             `json:"size"`
+		TimeType               TimeType        `json:"timeType"`
+		Price                  decimal.Decimal `json:"price"`
+		StopPrice              decimal.Decimal `json:"stopPrice"`
+		Amount                 decimal.Decimal `json:"amount"`
+		TrailingStopPercentage bool            `json:"trailingStopPercentage"`
+	}{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	*i = PlaceOrderInput{
+		BuySell:   payload.BuySell,
+		OrderType: payload.OrderType,
+		ProductId: payload.ProductId,
+		Quantity:  payload.Size,
+		TimeType:  payload.TimeType,
+		Price:     payload.Price,
+		StopPrice: payload.StopPrice,
+		Amount:    payload.Amount,
+	}
+	if payload.OrderType == TrailingStop {
+		i.Trail = payload.StopPrice
+		i.StopPrice = decimal.Zero
+		i.TrailPercentage = payload.TrailingStopPercentage
+	}
+	return nil
 }
 
 type placeOrderQueryParams struct {
@@ -134,13 +229,16 @@ type placeOrderQueryParams struct {
 
 func (c *Client) checkOrder(input PlaceOrderInput) (string, error) {
 	checkOrderResponse := &checkOrderResponse{}
-	_, err := c.ReceiveSuccessReloginOn401(c.sling.New().
-		Post(fmt.Sprintf("trading/secure/v5/checkOrder;jsessionid=%s", c.sessionId)).
-		QueryStruct(&placeOrderQueryParams{
-			AccountId: c.accountId,
-			SessionId: c.sessionId,
-		}).
-		BodyJSON(input), checkOrderResponse)
+	_, err := c.receiveSuccessReloginOn401(func() *sling.Sling {
+		sessionId := c.session()
+		return c.sling.New().
+			Post(fmt.Sprintf("trading/secure/v5/checkOrder;jsessionid=%s", sessionId)).
+			QueryStruct(&placeOrderQueryParams{
+				AccountId: c.accountId,
+				SessionId: sessionId,
+			}).
+			BodyJSON(input)
+	}, checkOrderResponse)
 	if err != nil {
 		return "", err
 	}
@@ -156,20 +254,57 @@ func (c *Client) confirmOrder(confirmationId string, input PlaceOrderInput) (str
 		} `json:"data"`
 	}
 	confirmOrderResponse := &ConfirmOrderResponse{}
-	_, err := c.ReceiveSuccessReloginOn401(c.sling.New().
-		Post(fmt.Sprintf("trading/secure/v5/order/%s;jsessionid=%s", confirmationId, c.sessionId)).
-		QueryStruct(&placeOrderQueryParams{
-			AccountId: c.accountId,
-			SessionId: c.sessionId,
-		}).
-		BodyJSON(input), confirmOrderResponse)
+	_, err := c.receiveSuccessReloginOn401(func() *sling.Sling {
+		sessionId := c.session()
+		return c.sling.New().
+			Post(fmt.Sprintf("trading/secure/v5/order/%s;jsessionid=%s", confirmationId, sessionId)).
+			QueryStruct(&placeOrderQueryParams{
+				AccountId: c.accountId,
+				SessionId: sessionId,
+			}).
+			BodyJSON(input)
+	}, confirmOrderResponse)
 	if err != nil {
 		return "", err
 	}
 	return confirmOrderResponse.Data.OrderId, nil
 }
 
+// prepareOrder snaps, validates and checks input according to SnapPrices,
+// ValidateOrders and RiskPolicy
+func (c *Client) prepareOrder(input PlaceOrderInput) (PlaceOrderInput, error) {
+	if !c.ValidateOrders && !c.SnapPrices && c.RiskPolicy == nil {
+		return input, nil
+	}
+	product, found := c.GetProduct(input.ProductId)
+	if !found {
+		return input, fmt.Errorf("product %s not found", input.ProductId)
+	}
+	if c.SnapPrices {
+		var err error
+		input, err = c.SnapOrderPrices(input, product)
+		if err != nil {
+			return input, fmt.Errorf("snapping order prices: %v", err)
+		}
+	}
+	if c.ValidateOrders {
+		if err := ValidateOrder(input, product); err != nil {
+			return input, err
+		}
+	}
+	if c.RiskPolicy != nil {
+		if err := c.RiskPolicy.Check(input, c.riskContext(product)); err != nil {
+			return input, err
+		}
+	}
+	return input, nil
+}
+
 func (c *Client) PlaceOrder(input PlaceOrderInput) (string, error) {
+	input, err := c.prepareOrder(input)
+	if err != nil {
+		return "", err
+	}
 	confirmationId, err := c.checkOrder(input)
 	if err != nil {
 		return "", fmt.Errorf("checking order: %v", err)
@@ -182,12 +317,15 @@ func (c *Client) PlaceOrder(input PlaceOrderInput) (string, error) {
 }
 
 func (c *Client) DeleteOrder(orderid string) error {
-	_, err := c.ReceiveSuccessReloginOn401(c.sling.New().
-		Delete(fmt.Sprintf("trading/secure/v5/order/%s;jsessionid=%s", orderid, c.sessionId)).
-		QueryStruct(&placeOrderQueryParams{
-			AccountId: c.accountId,
-			SessionId: c.sessionId,
-		}), nil)
+	_, err := c.receiveSuccessReloginOn401(func() *sling.Sling {
+		sessionId := c.session()
+		return c.sling.New().
+			Delete(fmt.Sprintf("trading/secure/v5/order/%s;jsessionid=%s", orderid, sessionId)).
+			QueryStruct(&placeOrderQueryParams{
+				AccountId: c.accountId,
+				SessionId: sessionId,
+			})
+	}, nil)
 	if err != nil {
 		return err
 	}
@@ -307,6 +445,10 @@ func (c *Client) GetPendingOrders(productId int) []Order {
 	return c.orders.Get(productId)
 }
 
+func (c *Client) GetAllPendingOrders() []Order {
+	return c.orders.GetAll()
+}
+
 func convertShortActionType(s string) (ActionType, error) {
 	switch s {
 	case "B":
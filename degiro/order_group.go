@@ -0,0 +1,875 @@
+package degiro
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type OrderLegRole string
+
+const (
+	EntryLeg      OrderLegRole = "entry"
+	TakeProfitLeg OrderLegRole = "takeProfit"
+	StopLossLeg   OrderLegRole = "stopLoss"
+	OcoLeg        OrderLegRole = "oco"
+)
+
+type OrderLegState string
+
+const (
+	// LegWaiting legs are placed once the entry leg of their group is filled.
+	LegWaiting   OrderLegState = "waiting"
+	LegPending   OrderLegState = "pending"
+	LegFilled    OrderLegState = "filled"
+	LegCancelled OrderLegState = "cancelled"
+	LegFailed    OrderLegState = "failed"
+)
+
+type OrderLeg struct {
+	Role    OrderLegRole    `json:"role"`
+	Input   PlaceOrderInput `json:"input"`
+	OrderId string          `json:"orderId,omitempty"`
+	Placed  time.Time       `json:"placed"`
+	State   OrderLegState   `json:"state"`
+	// Filled is the quantity filled, set once the leg is filled.
+	Filled int    `json:"filled,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (l OrderLeg) done() bool {
+	return l.State == LegFilled || l.State == LegCancelled || l.State == LegFailed
+}
+
+// partiallyFilled returns whether the leg was removed after filling only
+// part of its quantity.
+func (l OrderLeg) partiallyFilled() bool {
+	return l.State == LegFilled && l.Input.Quantity > 0 && l.Filled < l.Input.Quantity
+}
+
+// OrderGroup is a set of linked orders: an optional entry leg, and exit legs
+// cancelling each other when one of them is filled. An exit leg removed after
+// a partial fill reduces the other exit legs by the quantity filled instead,
+// by cancelling and placing them again. Fills happening while the
+// OrderManager is not running are not acted upon: the legs left pending are
+// only reported by Orphans.
+type OrderGroup struct {
+	Id      string     `json:"id"`
+	Created time.Time  `json:"created"`
+	Legs    []OrderLeg `json:"legs"`
+}
+
+func (g OrderGroup) Done() bool {
+	for _, leg := range g.Legs {
+		if !leg.done() {
+			return false
+		}
+	}
+	return true
+}
+
+func (g OrderGroup) placed() bool {
+	for _, leg := range g.Legs {
+		if leg.OrderId != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (g OrderGroup) copy() OrderGroup {
+	legs := make([]OrderLeg, len(g.Legs))
+	copy(legs, g.Legs)
+	g.Legs = legs
+	return g
+}
+
+// OrphanedLeg is a leg left alone by its group: a pending order whose
+// sibling was filled but which could not be cancelled, an exit leg which
+// could not be placed after the entry was filled, or a leg which is not
+// pending on the server anymore without the manager knowing why.
+type OrphanedLeg struct {
+	GroupId string
+	Leg     OrderLeg
+	Reason  string
+}
+
+// OrderGroupStore persists the order groups so that they survive restarts.
+type OrderGroupStore interface {
+	Load() ([]OrderGroup, error)
+	Save(groups []OrderGroup) error
+}
+
+type FileOrderGroupStore struct {
+	path string
+}
+
+func NewFileOrderGroupStore(path string) *FileOrderGroupStore {
+	return &FileOrderGroupStore{path: path}
+}
+
+func (s *FileOrderGroupStore) Load() ([]OrderGroup, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening order group store: %v", err)
+	}
+	defer f.Close()
+	var groups []OrderGroup
+	if err := json.NewDecoder(f).Decode(&groups); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decoding order group store: %v", err)
+	}
+	return groups, nil
+}
+
+func (s *FileOrderGroupStore) Save(groups []OrderGroup) error {
+	err := writeFileAtomic(s.path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(groups)
+	})
+	if err != nil {
+		return fmt.Errorf("writing order group store: %v", err)
+	}
+	return nil
+}
+
+type orderPlacer interface {
+	PlaceOrder(input PlaceOrderInput) (string, error)
+	DeleteOrder(orderId string) error
+	GetAllPendingOrders() []Order
+	GetTransactions(fromDate time.Time, toDate time.Time) ([]Transaction, error)
+}
+
+// fillClockSkew is the tolerance between the local and server clocks when
+// matching transactions with the time a leg was placed.
+const fillClockSkew = time.Minute
+
+// OrderManager emulates one-cancels-other and bracket orders on top of
+// PlaceOrder and DeleteOrder. It follows the pending orders updates: an
+// order of a group removed from the pending orders without having been
+// cancelled by the manager is looked up in the transactions. It is filled
+// for the quantity of the matching transactions, and considered cancelled,
+// e.g. expired or rejected, when there is none. The updates are queued and
+// handled by a goroutine of the manager, so that the transaction lookups and
+// the placement of the other legs do not hold up the order updates of the
+// broker.
+type OrderManager struct {
+	// OnOrphan is called when a leg becomes orphaned.
+	OnOrphan func(OrphanedLeg)
+	// FillCheckRetries is the number of times the transactions are fetched
+	// again, FillCheckDelay apart, while a removed order is not entirely
+	// filled, as transactions can show up after the order is removed.
+	FillCheckRetries int
+	FillCheckDelay   time.Duration
+
+	mu         sync.Mutex
+	broker     orderPlacer
+	store      OrderGroupStore
+	groups     map[string]*OrderGroup
+	order      []string
+	cancelling map[string]bool
+	checking   map[string]bool
+	// placing counts the orders being placed, whose removal can be notified
+	// before PlaceOrder returns
+	placing             int
+	removedWhilePlacing map[string]bool
+	usedTransactions    map[int]bool
+	lastId              int64
+
+	queueMu    sync.Mutex
+	queueCond  *sync.Cond
+	queue      []OrderUpdate
+	processing bool
+	closed     bool
+	done       chan struct{}
+}
+
+// NewOrderManager creates an order manager restoring the groups of store,
+// which can be nil, and following the order updates of broker.
+func NewOrderManager(broker Broker, store OrderGroupStore) (*OrderManager, error) {
+	m, err := newOrderManager(broker, store)
+	if err != nil {
+		return nil, err
+	}
+	broker.OnOrderUpdate(m.queueOrderUpdate)
+	return m, nil
+}
+
+func newOrderManager(broker orderPlacer, store OrderGroupStore) (*OrderManager, error) {
+	m := &OrderManager{
+		FillCheckRetries:    2,
+		FillCheckDelay:      2 * time.Second,
+		broker:              broker,
+		store:               store,
+		groups:              make(map[string]*OrderGroup),
+		cancelling:          make(map[string]bool),
+		checking:            make(map[string]bool),
+		removedWhilePlacing: make(map[string]bool),
+		usedTransactions:    make(map[int]bool),
+		done:                make(chan struct{}),
+	}
+	m.queueCond = sync.NewCond(&m.queueMu)
+	if store != nil {
+		groups, err := store.Load()
+		if err != nil {
+			return nil, fmt.Errorf("loading order groups: %v", err)
+		}
+		for i := range groups {
+			group := groups[i]
+			m.groups[group.Id] = &group
+			m.order = append(m.order, group.Id)
+		}
+	}
+	go m.run()
+	return m, nil
+}
+
+// queueOrderUpdate queues update to be handled by run. It never blocks, as
+// it is called from the update loop of the broker.
+func (m *OrderManager) queueOrderUpdate(update OrderUpdate) {
+	if len(update.Removed) == 0 {
+		return
+	}
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	if m.closed {
+		return
+	}
+	m.queue = append(m.queue, update)
+	m.queueCond.Broadcast()
+}
+
+// run handles the queued order updates until the manager is closed.
+func (m *OrderManager) run() {
+	defer close(m.done)
+	m.queueMu.Lock()
+	for {
+		for len(m.queue) == 0 && !m.closed {
+			m.queueCond.Wait()
+		}
+		if len(m.queue) == 0 {
+			m.queueMu.Unlock()
+			return
+		}
+		updates := m.queue
+		m.queue = nil
+		m.processing = true
+		m.queueMu.Unlock()
+		for _, update := range updates {
+			m.processOrderUpdate(update)
+		}
+		m.queueMu.Lock()
+		m.processing = false
+		m.queueCond.Broadcast()
+	}
+}
+
+// Wait blocks until the order updates received so far are handled. It must
+// not be called from OnOrphan.
+func (m *OrderManager) Wait() {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	for len(m.queue) > 0 || m.processing {
+		m.queueCond.Wait()
+	}
+}
+
+// Close handles the order updates already queued, then stops following the
+// order updates.
+func (m *OrderManager) Close() {
+	m.queueMu.Lock()
+	m.closed = true
+	m.queueCond.Broadcast()
+	m.queueMu.Unlock()
+	<-m.done
+}
+
+// PlaceBracket places entry, then takeProfit and stopLoss once entry is
+// filled, for at most the filled quantity. The first of takeProfit and
+// stopLoss filled cancels the other, or reduces it if partially filled.
+func (m *OrderManager) PlaceBracket(entry PlaceOrderInput, takeProfit PlaceOrderInput, stopLoss PlaceOrderInput) (OrderGroup, error) {
+	return m.place([]OrderLeg{
+		{Role: EntryLeg, Input: entry, State: LegWaiting},
+		{Role: TakeProfitLeg, Input: takeProfit, State: LegWaiting},
+		{Role: StopLossLeg, Input: stopLoss, State: LegWaiting},
+	})
+}
+
+// PlaceOCO places both orders, the first filled cancelling the other, or
+// reducing it if partially filled.
+func (m *OrderManager) PlaceOCO(first PlaceOrderInput, second PlaceOrderInput) (OrderGroup, error) {
+	return m.place([]OrderLeg{
+		{Role: OcoLeg, Input: first, State: LegWaiting},
+		{Role: OcoLeg, Input: second, State: LegWaiting},
+	})
+}
+
+// place registers a new group and places its first legs. The broker is
+// never called with mu held, as it can notify order updates synchronously.
+func (m *OrderManager) place(legs []OrderLeg) (OrderGroup, error) {
+	m.mu.Lock()
+	m.lastId++
+	if now := time.Now().UnixNano(); now > m.lastId {
+		m.lastId = now
+	}
+	group := &OrderGroup{
+		Id:      strconv.FormatInt(m.lastId, 36),
+		Created: time.Now(),
+		Legs:    legs,
+	}
+	m.groups[group.Id] = group
+	m.order = append(m.order, group.Id)
+	m.mu.Unlock()
+
+	var removed []string
+	var err error
+	if legs[0].Role == EntryLeg {
+		removed, err = m.placeLeg(group, 0)
+	} else {
+		removed, err = m.placeExitLegs(group)
+	}
+
+	m.mu.Lock()
+	// a group with placed orders is kept even on error so that the orders
+	// which could not be cancelled are still followed
+	if err != nil && !group.placed() {
+		m.removeGroup(group.Id)
+		m.mu.Unlock()
+		return OrderGroup{}, err
+	}
+	m.save()
+	res := group.copy()
+	m.mu.Unlock()
+	m.queueOrderUpdate(OrderUpdate{Removed: removed})
+	return res, err
+}
+
+func (m *OrderManager) removeGroup(groupId string) {
+	delete(m.groups, groupId)
+	for i, id := range m.order {
+		if id == groupId {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// placeLeg places a leg of group, returning its order id if it was removed
+// from the pending orders while being placed.
+func (m *OrderManager) placeLeg(group *OrderGroup, index int) ([]string, error) {
+	m.mu.Lock()
+	leg := group.Legs[index]
+	m.placing++
+	m.mu.Unlock()
+
+	placed := time.Now()
+	orderId, err := m.broker.PlaceOrder(leg.Input)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.placing--
+	var removed []string
+	if err == nil && m.removedWhilePlacing[orderId] {
+		removed = append(removed, orderId)
+	}
+	if m.placing == 0 {
+		m.removedWhilePlacing = make(map[string]bool)
+	}
+	if err != nil {
+		group.Legs[index].State = LegFailed
+		group.Legs[index].Error = err.Error()
+		m.save()
+		return nil, fmt.Errorf("placing %s order: %v", leg.Role, err)
+	}
+	group.Legs[index].OrderId = orderId
+	group.Legs[index].State = LegPending
+	group.Legs[index].Placed = placed
+	m.save()
+	return removed, nil
+}
+
+// placeExitLegs places all the waiting exit legs of group, cancelling the
+// ones already placed if one fails
+func (m *OrderManager) placeExitLegs(group *OrderGroup) ([]string, error) {
+	var removed []string
+	for i := range group.Legs {
+		m.mu.Lock()
+		leg := group.Legs[i]
+		m.mu.Unlock()
+		if leg.Role == EntryLeg || leg.State != LegWaiting {
+			continue
+		}
+		r, err := m.placeLeg(group, i)
+		if err != nil {
+			for j := range group.Legs {
+				if j != i && group.Legs[j].Role != EntryLeg {
+					m.cancelLeg(group, j)
+				}
+			}
+			return removed, err
+		}
+		removed = append(removed, r...)
+	}
+	return removed, nil
+}
+
+// cancelLeg cancels a waiting or pending leg, returning false if the order
+// could not be deleted
+func (m *OrderManager) cancelLeg(group *OrderGroup, index int) bool {
+	m.mu.Lock()
+	leg := &group.Legs[index]
+	if leg.State == LegWaiting {
+		leg.State = LegCancelled
+		m.save()
+		m.mu.Unlock()
+		return true
+	}
+	if leg.State != LegPending || m.cancelling[leg.OrderId] {
+		m.mu.Unlock()
+		return true
+	}
+	orderId := leg.OrderId
+	m.cancelling[orderId] = true
+	m.mu.Unlock()
+
+	err := m.broker.DeleteOrder(orderId)
+	if err == nil {
+		return true
+	}
+	m.mu.Lock()
+	delete(m.cancelling, orderId)
+	group.Legs[index].Error = err.Error()
+	m.save()
+	m.mu.Unlock()
+	return false
+}
+
+// reduceLeg reduces a waiting or pending leg to quantity, deleting a pending
+// order and placing it again. It returns the orders removed while being
+// placed, and an error if the order could not be deleted or placed again.
+func (m *OrderManager) reduceLeg(group *OrderGroup, index int, quantity int) ([]string, error) {
+	m.mu.Lock()
+	leg := &group.Legs[index]
+	if leg.State == LegWaiting {
+		leg.Input.Quantity = quantity
+		m.save()
+		m.mu.Unlock()
+		return nil, nil
+	}
+	if leg.State != LegPending || m.cancelling[leg.OrderId] {
+		m.mu.Unlock()
+		return nil, nil
+	}
+	orderId := leg.OrderId
+	m.cancelling[orderId] = true
+	m.mu.Unlock()
+
+	if err := m.broker.DeleteOrder(orderId); err != nil {
+		m.mu.Lock()
+		delete(m.cancelling, orderId)
+		group.Legs[index].Error = err.Error()
+		m.save()
+		m.mu.Unlock()
+		return nil, fmt.Errorf("deleting order %s: %v", orderId, err)
+	}
+	m.mu.Lock()
+	// the removal of the deleted order, once notified, matches no leg
+	delete(m.cancelling, orderId)
+	leg = &group.Legs[index]
+	leg.State = LegWaiting
+	leg.OrderId = ""
+	leg.Input.Quantity = quantity
+	m.save()
+	m.mu.Unlock()
+	return m.placeLeg(group, index)
+}
+
+// Cancel cancels all the remaining legs of a group.
+func (m *OrderManager) Cancel(groupId string) error {
+	m.mu.Lock()
+	group, found := m.groups[groupId]
+	m.mu.Unlock()
+	if !found {
+		return fmt.Errorf("unknown order group %s", groupId)
+	}
+	var err error
+	for i := range group.Legs {
+		if !m.cancelLeg(group, i) {
+			m.mu.Lock()
+			err = fmt.Errorf("cancelling order %s: %s", group.Legs[i].OrderId, group.Legs[i].Error)
+			m.mu.Unlock()
+		}
+	}
+	return err
+}
+
+type removedLeg struct {
+	group *OrderGroup
+	index int
+	leg   OrderLeg
+}
+
+// processOrderUpdate resolves the legs removed from the pending orders by
+// update, looking up their fills and placing or cancelling their siblings.
+func (m *OrderManager) processOrderUpdate(update OrderUpdate) {
+	if len(update.Removed) == 0 {
+		return
+	}
+	m.mu.Lock()
+	var removed []removedLeg
+	changed := false
+	for _, orderId := range update.Removed {
+		group, i, found := m.findLeg(orderId)
+		if !found {
+			if m.placing > 0 {
+				m.removedWhilePlacing[orderId] = true
+			}
+			continue
+		}
+		if m.cancelling[orderId] {
+			delete(m.cancelling, orderId)
+			group.Legs[i].State = LegCancelled
+			changed = true
+			continue
+		}
+		if m.checking[orderId] {
+			continue
+		}
+		m.checking[orderId] = true
+		removed = append(removed, removedLeg{group: group, index: i, leg: group.Legs[i]})
+	}
+	if changed {
+		m.save()
+	}
+	m.mu.Unlock()
+	if len(removed) == 0 {
+		return
+	}
+
+	filled, err := m.filledQuantities(removed)
+	var orphans []OrphanedLeg
+	for i, r := range removed {
+		quantity := 0
+		if err == nil {
+			quantity = filled[i]
+		}
+		orphans = append(orphans, m.resolveRemoved(r, quantity, err)...)
+	}
+	m.mu.Lock()
+	onOrphan := m.OnOrphan
+	m.mu.Unlock()
+	if onOrphan != nil {
+		for _, orphan := range orphans {
+			onOrphan(orphan)
+		}
+	}
+}
+
+func (m *OrderManager) findLeg(orderId string) (*OrderGroup, int, bool) {
+	for _, group := range m.groups {
+		for i, leg := range group.Legs {
+			if leg.State == LegPending && leg.OrderId == orderId {
+				return group, i, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// filledQuantities returns the quantity filled of each removed leg according
+// to the transactions made since it was placed.
+func (m *OrderManager) filledQuantities(removed []removedLeg) ([]int, error) {
+	from := removed[0].leg.Placed
+	for _, r := range removed {
+		if r.leg.Placed.Before(from) {
+			from = r.leg.Placed
+		}
+	}
+	from = from.Add(-fillClockSkew)
+	for attempt := 0; ; attempt++ {
+		transactions, err := m.broker.GetTransactions(from, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("getting transactions: %v", err)
+		}
+		filled, used := m.matchTransactions(removed, transactions)
+		complete := true
+		for i, r := range removed {
+			if filled[i] == 0 || (r.leg.Input.Quantity > 0 && filled[i] < r.leg.Input.Quantity) {
+				complete = false
+			}
+		}
+		if complete || attempt >= m.FillCheckRetries {
+			m.mu.Lock()
+			for _, id := range used {
+				m.usedTransactions[id] = true
+			}
+			m.mu.Unlock()
+			return filled, nil
+		}
+		time.Sleep(m.FillCheckDelay)
+	}
+}
+
+// matchTransactions attributes to each removed leg the transactions of its
+// product and direction made since it was placed, and not already
+// attributed to another leg, up to its quantity.
+func (m *OrderManager) matchTransactions(removed []removedLeg, transactions []Transaction) ([]int, []int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sortTransactionsByDateAscending(transactions)
+	taken := make(map[int]bool)
+	filled := make([]int, len(removed))
+	var used []int
+	for i, r := range removed {
+		input := r.leg.Input
+		buySell := "B"
+		if input.BuySell == Sell {
+			buySell = "S"
+		}
+		for _, transaction := range transactions {
+			if input.Quantity > 0 && filled[i] >= input.Quantity {
+				break
+			}
+			if m.usedTransactions[transaction.Id] || taken[transaction.Id] ||
+				strconv.Itoa(transaction.ProductId) != input.ProductId ||
+				transaction.BuySell != buySell ||
+				transaction.Date.Before(r.leg.Placed.Add(-fillClockSkew)) {
+				continue
+			}
+			quantity := transaction.Quantity
+			if quantity < 0 {
+				quantity = -quantity
+			}
+			filled[i] += quantity
+			taken[transaction.Id] = true
+			used = append(used, transaction.Id)
+		}
+	}
+	return filled, used
+}
+
+// resolveRemoved updates a leg removed from the pending orders with the
+// quantity filled, then places or cancels the other legs of its group.
+func (m *OrderManager) resolveRemoved(r removedLeg, filled int, fillErr error) []OrphanedLeg {
+	m.mu.Lock()
+	delete(m.checking, r.leg.OrderId)
+	group := r.group
+	leg := &group.Legs[r.index]
+	if leg.State != LegPending || leg.OrderId != r.leg.OrderId {
+		m.mu.Unlock()
+		return nil
+	}
+	if fillErr != nil {
+		leg.Error = fillErr.Error()
+		m.save()
+		orphan := OrphanedLeg{GroupId: group.Id, Leg: *leg, Reason: fmt.Sprintf("order removed but fill not confirmed: %v", fillErr)}
+		m.mu.Unlock()
+		return []OrphanedLeg{orphan}
+	}
+	if filled == 0 {
+		leg.State = LegCancelled
+		leg.Error = "order removed without being filled"
+		if leg.Role == EntryLeg {
+			for i := range group.Legs {
+				if group.Legs[i].State == LegWaiting {
+					group.Legs[i].State = LegCancelled
+				}
+			}
+		}
+		m.save()
+		m.mu.Unlock()
+		return nil
+	}
+	leg.State = LegFilled
+	leg.Filled = filled
+	if leg.Role == EntryLeg {
+		for i := range group.Legs {
+			exit := &group.Legs[i]
+			if exit.State == LegWaiting && exit.Input.Quantity > filled {
+				exit.Input.Quantity = filled
+			}
+		}
+	}
+	m.save()
+	filledLeg := *leg
+	m.mu.Unlock()
+	return m.legFilled(group, r.index, filledLeg)
+}
+
+func (m *OrderManager) legFilled(group *OrderGroup, index int, filled OrderLeg) []OrphanedLeg {
+	var orphans []OrphanedLeg
+	if filled.Role == EntryLeg {
+		removed, err := m.placeExitLegs(group)
+		if err != nil {
+			orphans = append(orphans, OrphanedLeg{
+				GroupId: group.Id,
+				Leg:     filled,
+				Reason:  fmt.Sprintf("entry filled but exit legs not placed: %v", err),
+			})
+		}
+		m.queueOrderUpdate(OrderUpdate{Removed: removed})
+		return orphans
+	}
+	if filled.partiallyFilled() {
+		return m.reduceSiblings(group, index, filled)
+	}
+	for i := range group.Legs {
+		if i == index || group.Legs[i].Role == EntryLeg {
+			continue
+		}
+		if !m.cancelLeg(group, i) {
+			m.mu.Lock()
+			leg := group.Legs[i]
+			m.mu.Unlock()
+			orphans = append(orphans, OrphanedLeg{
+				GroupId: group.Id,
+				Leg:     leg,
+				Reason:  fmt.Sprintf("sibling %s leg filled but cancelling failed: %s", filled.Role, leg.Error),
+			})
+		}
+	}
+	return orphans
+}
+
+// reduceSiblings reduces the other exit legs of group by the quantity of the
+// partially filled leg, cancelling the ones left without quantity.
+func (m *OrderManager) reduceSiblings(group *OrderGroup, index int, filled OrderLeg) []OrphanedLeg {
+	var orphans []OrphanedLeg
+	var removed []string
+	for i := range group.Legs {
+		m.mu.Lock()
+		leg := group.Legs[i]
+		m.mu.Unlock()
+		if i == index || leg.Role == EntryLeg || leg.done() {
+			continue
+		}
+		if leg.Input.Quantity <= 0 {
+			orphans = append(orphans, OrphanedLeg{
+				GroupId: group.Id,
+				Leg:     leg,
+				Reason:  fmt.Sprintf("sibling %s leg partially filled but amount orders cannot be reduced", filled.Role),
+			})
+			continue
+		}
+		quantity := leg.Input.Quantity - filled.Filled
+		if quantity <= 0 {
+			if !m.cancelLeg(group, i) {
+				m.mu.Lock()
+				leg = group.Legs[i]
+				m.mu.Unlock()
+				orphans = append(orphans, OrphanedLeg{
+					GroupId: group.Id,
+					Leg:     leg,
+					Reason:  fmt.Sprintf("sibling %s leg filled but cancelling failed: %s", filled.Role, leg.Error),
+				})
+			}
+			continue
+		}
+		r, err := m.reduceLeg(group, i, quantity)
+		removed = append(removed, r...)
+		if err != nil {
+			m.mu.Lock()
+			leg = group.Legs[i]
+			m.mu.Unlock()
+			orphans = append(orphans, OrphanedLeg{
+				GroupId: group.Id,
+				Leg:     leg,
+				Reason:  fmt.Sprintf("sibling %s leg partially filled but reducing failed: %v", filled.Role, err),
+			})
+		}
+	}
+	m.queueOrderUpdate(OrderUpdate{Removed: removed})
+	return orphans
+}
+
+// Orphans returns the legs left alone: pending legs whose sibling was filled,
+// and pending legs missing from the pending orders of the server, e.g. filled
+// or cancelled while the manager was not running. It should be called once
+// the pending orders are up to date.
+func (m *OrderManager) Orphans() []OrphanedLeg {
+	pending := make(map[string]bool)
+	for _, order := range m.broker.GetAllPendingOrders() {
+		pending[order.Id] = true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []OrphanedLeg
+	for _, id := range m.order {
+		group := m.groups[id]
+		filledExit := ""
+		for _, leg := range group.Legs {
+			if leg.Role != EntryLeg && leg.State == LegFilled && !leg.partiallyFilled() {
+				filledExit = string(leg.Role)
+			}
+		}
+		for _, leg := range group.Legs {
+			if leg.State != LegPending || m.cancelling[leg.OrderId] {
+				continue
+			}
+			switch {
+			case !pending[leg.OrderId]:
+				res = append(res, OrphanedLeg{GroupId: group.Id, Leg: leg, Reason: "order is not pending anymore"})
+			case filledExit != "":
+				res = append(res, OrphanedLeg{GroupId: group.Id, Leg: leg, Reason: fmt.Sprintf("sibling %s leg filled", filledExit)})
+			}
+		}
+	}
+	return res
+}
+
+// Groups returns the groups in creation order.
+func (m *OrderManager) Groups() []OrderGroup {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var res []OrderGroup
+	for _, id := range m.order {
+		res = append(res, m.groups[id].copy())
+	}
+	return res
+}
+
+func (m *OrderManager) Group(groupId string) (OrderGroup, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	group, found := m.groups[groupId]
+	if !found {
+		return OrderGroup{}, false
+	}
+	return group.copy(), true
+}
+
+// RemoveDone removes the done groups.
+func (m *OrderManager) RemoveDone() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var order []string
+	for _, id := range m.order {
+		if m.groups[id].Done() {
+			delete(m.groups, id)
+			continue
+		}
+		order = append(order, id)
+	}
+	m.order = order
+	m.save()
+}
+
+func (m *OrderManager) save() {
+	if m.store == nil {
+		return
+	}
+	groups := make([]OrderGroup, 0, len(m.order))
+	for _, id := range m.order {
+		groups = append(groups, *m.groups[id])
+	}
+	if err := m.store.Save(groups); err != nil {
+		log.Errorf("saving order groups: %v", err)
+	}
+}
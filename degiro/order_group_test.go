@@ -0,0 +1,328 @@
+package degiro
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOrderPlacer struct {
+	lastId       int
+	placed       map[string]PlaceOrderInput
+	deleted      []string
+	transactions []Transaction
+	failPlace    bool
+	failDelete   bool
+}
+
+func newFakeOrderPlacer() *fakeOrderPlacer {
+	return &fakeOrderPlacer{placed: make(map[string]PlaceOrderInput)}
+}
+
+func (p *fakeOrderPlacer) PlaceOrder(input PlaceOrderInput) (string, error) {
+	if p.failPlace {
+		return "", fmt.Errorf("rejected")
+	}
+	p.lastId++
+	id := fmt.Sprintf("order-%d", p.lastId)
+	p.placed[id] = input
+	return id, nil
+}
+
+func (p *fakeOrderPlacer) DeleteOrder(orderId string) error {
+	if p.failDelete {
+		return fmt.Errorf("not deletable")
+	}
+	p.deleted = append(p.deleted, orderId)
+	delete(p.placed, orderId)
+	return nil
+}
+
+func (p *fakeOrderPlacer) GetAllPendingOrders() []Order {
+	var res []Order
+	for id := range p.placed {
+		res = append(res, Order{Id: id})
+	}
+	return res
+}
+
+func (p *fakeOrderPlacer) GetTransactions(fromDate time.Time, toDate time.Time) ([]Transaction, error) {
+	return append([]Transaction(nil), p.transactions...), nil
+}
+
+func (p *fakeOrderPlacer) fill(orderId string) OrderUpdate {
+	return p.fillQuantity(orderId, p.placed[orderId].Quantity)
+}
+
+func (p *fakeOrderPlacer) fillQuantity(orderId string, quantity int) OrderUpdate {
+	input := p.placed[orderId]
+	productId, _ := strconv.Atoi(input.ProductId)
+	buySell := "B"
+	if input.BuySell == Sell {
+		buySell = "S"
+		quantity = -quantity
+	}
+	p.transactions = append(p.transactions, Transaction{
+		Id:        len(p.transactions) + 1,
+		ProductId: productId,
+		BuySell:   buySell,
+		Quantity:  quantity,
+		Date:      time.Now(),
+	})
+	return p.expire(orderId)
+}
+
+func (p *fakeOrderPlacer) expire(orderId string) OrderUpdate {
+	delete(p.placed, orderId)
+	return OrderUpdate{Removed: []string{orderId}}
+}
+
+func TestOrderManager_Bracket(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "ordergroups")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	store := NewFileOrderGroupStore(filepath.Join(dir, "groups.json"))
+
+	broker := newFakeOrderPlacer()
+	manager, err := newOrderManager(broker, store)
+	assert.Nil(err)
+	entry := PlaceOrderInput{BuySell: Buy, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Day, Price: decimal.New(10, 0)}
+	takeProfit := PlaceOrderInput{BuySell: Sell, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Permanent, Price: decimal.New(12, 0)}
+	stopLoss := PlaceOrderInput{BuySell: Sell, OrderType: TrailingStop, ProductId: "1", Quantity: 10, TimeType: Permanent, Trail: decimal.New(5, 0), TrailPercentage: true}
+	group, err := manager.PlaceBracket(entry, takeProfit, stopLoss)
+	assert.Nil(err)
+	assert.Equal(1, len(broker.placed))
+	assert.Equal(LegPending, group.Legs[0].State)
+	assert.Equal(LegWaiting, group.Legs[1].State)
+
+	manager.processOrderUpdate(broker.fill(group.Legs[0].OrderId))
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegFilled, group.Legs[0].State)
+	assert.Equal(LegPending, group.Legs[1].State)
+	assert.Equal(LegPending, group.Legs[2].State)
+	assert.Equal(2, len(broker.placed))
+
+	// restart: the groups are restored from the store
+	manager, err = newOrderManager(broker, store)
+	assert.Nil(err)
+	group, found := manager.Group(group.Id)
+	assert.True(found)
+	assert.Equal(decimal.New(5, 0).String(), group.Legs[2].Input.Trail.String())
+	assert.True(group.Legs[2].Input.TrailPercentage)
+	assert.Equal(0, len(manager.Orphans()))
+
+	manager.processOrderUpdate(broker.fill(group.Legs[1].OrderId))
+	assert.Equal([]string{group.Legs[2].OrderId}, broker.deleted)
+	manager.processOrderUpdate(OrderUpdate{Removed: []string{group.Legs[2].OrderId}})
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegFilled, group.Legs[1].State)
+	assert.Equal(LegCancelled, group.Legs[2].State)
+	assert.True(group.Done())
+
+	manager.RemoveDone()
+	assert.Equal(0, len(manager.Groups()))
+}
+
+func TestOrderManager_Orphans(t *testing.T) {
+	assert := assert.New(t)
+	broker := newFakeOrderPlacer()
+	manager, err := newOrderManager(broker, nil)
+	assert.Nil(err)
+	var orphans []OrphanedLeg
+	manager.OnOrphan = func(orphan OrphanedLeg) {
+		orphans = append(orphans, orphan)
+	}
+	first := PlaceOrderInput{BuySell: Sell, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Day, Price: decimal.New(12, 0)}
+	second := PlaceOrderInput{BuySell: Sell, OrderType: StopLoss, ProductId: "1", Quantity: 10, TimeType: Day, StopPrice: decimal.New(9, 0)}
+	group, err := manager.PlaceOCO(first, second)
+	assert.Nil(err)
+
+	broker.failDelete = true
+	manager.processOrderUpdate(broker.fill(group.Legs[0].OrderId))
+	if assert.Equal(1, len(orphans)) {
+		assert.Equal(group.Legs[1].OrderId, orphans[0].Leg.OrderId)
+	}
+	listed := manager.Orphans()
+	if assert.Equal(1, len(listed)) {
+		assert.Equal("sibling oco leg filled", listed[0].Reason)
+	}
+
+	// the order disappears while the manager is not following the updates
+	delete(broker.placed, group.Legs[1].OrderId)
+	listed = manager.Orphans()
+	if assert.Equal(1, len(listed)) {
+		assert.Equal("order is not pending anymore", listed[0].Reason)
+	}
+
+	broker.failPlace = true
+	_, err = manager.PlaceOCO(first, second)
+	assert.NotNil(err)
+	assert.Equal(1, len(manager.Groups()))
+}
+
+func TestOrderManager_RemovedWithoutFill(t *testing.T) {
+	assert := assert.New(t)
+	broker := newFakeOrderPlacer()
+	manager, err := newOrderManager(broker, nil)
+	assert.Nil(err)
+	manager.FillCheckDelay = 0
+	entry := PlaceOrderInput{BuySell: Buy, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Day, Price: decimal.New(10, 0)}
+	takeProfit := PlaceOrderInput{BuySell: Sell, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Permanent, Price: decimal.New(12, 0)}
+	stopLoss := PlaceOrderInput{BuySell: Sell, OrderType: StopLoss, ProductId: "1", Quantity: 10, TimeType: Permanent, StopPrice: decimal.New(9, 0)}
+
+	// the entry expires at the close: no exit leg is placed
+	group, err := manager.PlaceBracket(entry, takeProfit, stopLoss)
+	assert.Nil(err)
+	manager.processOrderUpdate(broker.expire(group.Legs[0].OrderId))
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegCancelled, group.Legs[0].State)
+	assert.Equal(LegCancelled, group.Legs[1].State)
+	assert.Equal(LegCancelled, group.Legs[2].State)
+	assert.True(group.Done())
+	assert.Equal(0, len(broker.placed))
+
+	// an oco leg cancelled by hand leaves its sibling pending
+	oco, err := manager.PlaceOCO(takeProfit, stopLoss)
+	assert.Nil(err)
+	manager.processOrderUpdate(broker.expire(oco.Legs[0].OrderId))
+	oco, _ = manager.Group(oco.Id)
+	assert.Equal(LegCancelled, oco.Legs[0].State)
+	assert.Equal(LegPending, oco.Legs[1].State)
+	assert.Equal(0, len(broker.deleted))
+}
+
+func TestOrderManager_PartialFill(t *testing.T) {
+	assert := assert.New(t)
+	broker := newFakeOrderPlacer()
+	manager, err := newOrderManager(broker, nil)
+	assert.Nil(err)
+	manager.FillCheckDelay = 0
+	entry := PlaceOrderInput{BuySell: Buy, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Day, Price: decimal.New(10, 0)}
+	takeProfit := PlaceOrderInput{BuySell: Sell, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Permanent, Price: decimal.New(12, 0)}
+	stopLoss := PlaceOrderInput{BuySell: Sell, OrderType: StopLoss, ProductId: "1", Quantity: 10, TimeType: Permanent, StopPrice: decimal.New(9, 0)}
+	group, err := manager.PlaceBracket(entry, takeProfit, stopLoss)
+	assert.Nil(err)
+
+	manager.processOrderUpdate(broker.fillQuantity(group.Legs[0].OrderId, 4))
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegFilled, group.Legs[0].State)
+	assert.Equal(4, group.Legs[0].Filled)
+	assert.Equal(4, broker.placed[group.Legs[1].OrderId].Quantity)
+	assert.Equal(4, broker.placed[group.Legs[2].OrderId].Quantity)
+}
+
+func TestOrderManager_PaperClient(t *testing.T) {
+	assert := assert.New(t)
+	market := &fakePaperMarket{
+		products: map[string]Product{
+			"1": {Id: "1", VwdId: "vwd1", Tradable: true, GtcAllowed: true, MarketAllowed: true, StopLossAllowed: true},
+		},
+		quotes: make(map[string]streaming.ProductQuote),
+	}
+	market.setQuote("vwd1", 9.9, 10, 10)
+	paper := NewPaperClient(market, decimal.New(1000, 0))
+	manager, err := NewOrderManager(paper, nil)
+	assert.Nil(err)
+
+	// the market entry is filled while it is being placed
+	entry := PlaceOrderInput{BuySell: Buy, OrderType: MarketOrder, ProductId: "1", Quantity: 10, TimeType: Day}
+	takeProfit := PlaceOrderInput{BuySell: Sell, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Permanent, Price: decimal.New(12, 0)}
+	stopLoss := PlaceOrderInput{BuySell: Sell, OrderType: StopLoss, ProductId: "1", Quantity: 10, TimeType: Permanent, StopPrice: decimal.New(9, 0)}
+	group, err := manager.PlaceBracket(entry, takeProfit, stopLoss)
+	assert.Nil(err)
+	manager.Wait()
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegFilled, group.Legs[0].State)
+	assert.Equal(LegPending, group.Legs[1].State)
+	assert.Equal(LegPending, group.Legs[2].State)
+
+	paper.ProcessQuote(market.setQuote("vwd1", 12, 12.1, 12))
+	manager.Wait()
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegFilled, group.Legs[1].State)
+	assert.Equal(LegCancelled, group.Legs[2].State)
+	position, _ := paper.GetOpenedPositionForProduct("1")
+	assert.Equal(0, position.Size)
+	manager.Close()
+}
+
+func TestOrderManager_QueuedUpdates(t *testing.T) {
+	assert := assert.New(t)
+	broker := newFakeOrderPlacer()
+	manager, err := newOrderManager(broker, nil)
+	assert.Nil(err)
+	manager.FillCheckDelay = 100 * time.Millisecond
+	entry := PlaceOrderInput{BuySell: Buy, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Day, Price: decimal.New(10, 0)}
+	takeProfit := PlaceOrderInput{BuySell: Sell, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Permanent, Price: decimal.New(12, 0)}
+	stopLoss := PlaceOrderInput{BuySell: Sell, OrderType: StopLoss, ProductId: "1", Quantity: 10, TimeType: Permanent, StopPrice: decimal.New(9, 0)}
+	group, err := manager.PlaceBracket(entry, takeProfit, stopLoss)
+	assert.Nil(err)
+
+	// the partial fill makes the manager check the transactions again, which
+	// does not hold up the caller
+	start := time.Now()
+	manager.queueOrderUpdate(broker.fillQuantity(group.Legs[0].OrderId, 4))
+	assert.True(time.Since(start) < manager.FillCheckDelay)
+	manager.Wait()
+	assert.True(time.Since(start) >= 2*manager.FillCheckDelay)
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegFilled, group.Legs[0].State)
+	assert.Equal(LegPending, group.Legs[1].State)
+
+	manager.Close()
+	manager.queueOrderUpdate(broker.fill(group.Legs[1].OrderId))
+	manager.Wait()
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegPending, group.Legs[1].State)
+}
+
+func TestOrderManager_PartialExitFill(t *testing.T) {
+	assert := assert.New(t)
+	broker := newFakeOrderPlacer()
+	manager, err := newOrderManager(broker, nil)
+	assert.Nil(err)
+	manager.FillCheckDelay = 0
+	var orphans []OrphanedLeg
+	manager.OnOrphan = func(orphan OrphanedLeg) {
+		orphans = append(orphans, orphan)
+	}
+	takeProfit := PlaceOrderInput{BuySell: Sell, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Day, Price: decimal.New(12, 0)}
+	stopLoss := PlaceOrderInput{BuySell: Sell, OrderType: StopLoss, ProductId: "1", Quantity: 10, TimeType: Permanent, StopPrice: decimal.New(9, 0)}
+	group, err := manager.PlaceOCO(takeProfit, stopLoss)
+	assert.Nil(err)
+	stopId := group.Legs[1].OrderId
+
+	// the take profit expires after selling 4: the stop loss is placed
+	// again for the 6 left
+	manager.processOrderUpdate(broker.fillQuantity(group.Legs[0].OrderId, 4))
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegFilled, group.Legs[0].State)
+	assert.Equal(4, group.Legs[0].Filled)
+	assert.Equal([]string{stopId}, broker.deleted)
+	assert.Equal(LegPending, group.Legs[1].State)
+	assert.NotEqual(stopId, group.Legs[1].OrderId)
+	assert.Equal(6, group.Legs[1].Input.Quantity)
+	assert.Equal(6, broker.placed[group.Legs[1].OrderId].Quantity)
+	assert.Equal(0, len(manager.Orphans()))
+
+	// the removal of the deleted order is ignored
+	manager.processOrderUpdate(OrderUpdate{Removed: []string{stopId}})
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegPending, group.Legs[1].State)
+
+	manager.processOrderUpdate(broker.fill(group.Legs[1].OrderId))
+	group, _ = manager.Group(group.Id)
+	assert.Equal(LegFilled, group.Legs[1].State)
+	assert.Equal(6, group.Legs[1].Filled)
+	assert.True(group.Done())
+	assert.Equal(0, len(orphans))
+}
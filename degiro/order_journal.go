@@ -0,0 +1,508 @@
+package degiro
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dghubble/sling"
+)
+
+type JournalState string
+
+const (
+	// JournalPending entries are recorded before the order is checked and
+	// confirmed.
+	JournalPending JournalState = "pending"
+	// JournalUncertain entries had an error while confirming: the order may
+	// or may not exist. They stay uncertain until the order is found, the
+	// grace period is over or they are resolved with ResolveOrder.
+	JournalUncertain JournalState = "uncertain"
+	JournalPlaced    JournalState = "placed"
+	// JournalFilled entries were found in the transactions while reconciling,
+	// the order id being unknown.
+	JournalFilled JournalState = "filled"
+	// JournalFailed entries were rejected before being confirmed, or not
+	// found while reconciling after the grace period.
+	JournalFailed JournalState = "failed"
+)
+
+// ErrOrderUncertain is returned when an order whose confirmation failed can
+// not be found yet.
+var ErrOrderUncertain = errors.New("order outcome uncertain")
+
+type JournalEntry struct {
+	Key     string          `json:"key"`
+	Input   PlaceOrderInput `json:"input"`
+	State   JournalState    `json:"state"`
+	OrderId string          `json:"orderId,omitempty"`
+	Created time.Time       `json:"created"`
+	Updated time.Time       `json:"updated"`
+	Error   string          `json:"error,omitempty"`
+	// TransactionIds are the transactions attributed to a filled entry, which
+	// are not attributed to another entry.
+	TransactionIds []int `json:"transactionIds,omitempty"`
+}
+
+// OrderJournal records the orders placed with a key, Entries returning the
+// last version of each entry.
+type OrderJournal interface {
+	Entries() ([]JournalEntry, error)
+	Put(entry JournalEntry) error
+}
+
+type MemoryOrderJournal struct {
+	mu      sync.Mutex
+	keys    []string
+	entries map[string]JournalEntry
+}
+
+func NewMemoryOrderJournal() *MemoryOrderJournal {
+	return &MemoryOrderJournal{entries: make(map[string]JournalEntry)}
+}
+
+func (j *MemoryOrderJournal) Entries() ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	res := make([]JournalEntry, 0, len(j.keys))
+	for _, key := range j.keys {
+		res = append(res, j.entries[key])
+	}
+	return res, nil
+}
+
+func (j *MemoryOrderJournal) Put(entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.put(entry)
+	return nil
+}
+
+func (j *MemoryOrderJournal) put(entry JournalEntry) {
+	if _, found := j.entries[entry.Key]; !found {
+		j.keys = append(j.keys, entry.Key)
+	}
+	j.entries[entry.Key] = entry
+}
+
+// FileOrderJournal appends the entries to a file, one JSON document per
+// line, synced before Put returns.
+type FileOrderJournal struct {
+	path string
+
+	mu     sync.Mutex
+	memory *MemoryOrderJournal
+}
+
+func NewFileOrderJournal(path string) *FileOrderJournal {
+	return &FileOrderJournal{path: path}
+}
+
+func (j *FileOrderJournal) load() error {
+	if j.memory != nil {
+		return nil
+	}
+	memory := NewMemoryOrderJournal()
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		j.memory = memory
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening order journal: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a line truncated by a crash
+			continue
+		}
+		memory.put(entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading order journal: %v", err)
+	}
+	j.memory = memory
+	return nil
+}
+
+func (j *FileOrderJournal) Entries() ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	return j.memory.Entries()
+}
+
+func (j *FileOrderJournal) Put(entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.load(); err != nil {
+		return err
+	}
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding order journal entry: %v", err)
+	}
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("opening order journal: %v", err)
+	}
+	if _, err := f.Write(append(buf, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("writing order journal: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("writing order journal: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing order journal: %v", err)
+	}
+	j.memory.put(entry)
+	return nil
+}
+
+// NewOrderKey returns a random key for PlaceOrderWithKey.
+func NewOrderKey() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// keyMutex is a set of mutexes by key.
+type keyMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock locks key and returns the function unlocking it.
+func (m *keyMutex) Lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = make(map[string]*keyLock)
+	}
+	lock, found := m.locks[key]
+	if !found {
+		lock = &keyLock{}
+		m.locks[key] = lock
+	}
+	lock.refs++
+	m.mu.Unlock()
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		m.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(m.locks, key)
+		}
+		m.mu.Unlock()
+	}
+}
+
+func (c *Client) journalEntry(key string) (JournalEntry, []JournalEntry, bool, error) {
+	entries, err := c.OrderJournal.Entries()
+	if err != nil {
+		return JournalEntry{}, nil, false, fmt.Errorf("reading order journal: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Key == key {
+			return entry, entries, true, nil
+		}
+	}
+	return JournalEntry{}, entries, false, nil
+}
+
+func (c *Client) putJournalEntry(entry JournalEntry) error {
+	c.journalMu.Lock()
+	defer c.journalMu.Unlock()
+	return c.putJournalEntryLocked(entry)
+}
+
+// putJournalEntryLocked records entry, journalMu being held so that the
+// orders attributed to the entries do not change meanwhile
+func (c *Client) putJournalEntryLocked(entry JournalEntry) error {
+	entry.Updated = time.Now()
+	if err := c.OrderJournal.Put(entry); err != nil {
+		return fmt.Errorf("recording order %s: %v", entry.Key, err)
+	}
+	return nil
+}
+
+// PlaceOrderWithKey places an order at most once for a key generated by the
+// caller, e.g. with NewOrderKey, so that it can be retried after an error:
+// the key is recorded in OrderJournal before the order is confirmed, and an
+// order whose confirmation failed is looked for in the pending orders and
+// the transactions of the server. An order which can not be found is never
+// placed again by the same call: ErrOrderUncertain is returned until it is
+// found, JournalGracePeriod is over or the entry is resolved with
+// ResolveOrder, the next call placing it again if it failed.
+func (c *Client) PlaceOrderWithKey(key string, input PlaceOrderInput) (string, error) {
+	if c.OrderJournal == nil {
+		return "", fmt.Errorf("no order journal")
+	}
+	unlock := c.journalKeys.Lock(key)
+	defer unlock()
+	entry, _, found, err := c.journalEntry(key)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		switch entry.State {
+		case JournalPlaced, JournalFilled:
+			return entry.OrderId, nil
+		case JournalPending, JournalUncertain:
+			entry, err = c.reconcileOrder(key)
+			if err != nil {
+				return "", err
+			}
+			if entry.State == JournalPlaced || entry.State == JournalFilled {
+				return entry.OrderId, nil
+			}
+			return "", fmt.Errorf("order %s not found after the grace period, it can be placed again", key)
+		}
+	}
+
+	// the risk policy is only checked for orders to place, a retried order
+	// counting itself in the open orders
+	input, err = c.prepareOrder(input)
+	if err != nil {
+		return "", err
+	}
+
+	entry = JournalEntry{
+		Key:     key,
+		Input:   input,
+		State:   JournalPending,
+		Created: time.Now(),
+	}
+	if err := c.putJournalEntry(entry); err != nil {
+		return "", err
+	}
+	confirmationId, err := c.checkOrder(input)
+	if err != nil {
+		entry.State = JournalFailed
+		entry.Error = err.Error()
+		if jerr := c.putJournalEntry(entry); jerr != nil {
+			return "", jerr
+		}
+		return "", fmt.Errorf("checking order: %v", err)
+	}
+	orderId, err := c.confirmOrder(confirmationId, input)
+	if err != nil {
+		entry.State = JournalUncertain
+		entry.Error = err.Error()
+		if jerr := c.putJournalEntry(entry); jerr != nil {
+			return "", jerr
+		}
+		return "", fmt.Errorf("confirming order: %v", err)
+	}
+	entry.State = JournalPlaced
+	entry.OrderId = orderId
+	entry.Error = ""
+	if err := c.putJournalEntry(entry); err != nil {
+		return orderId, err
+	}
+	return orderId, nil
+}
+
+// ReconcileOrder resolves a pending or uncertain journal entry against the
+// pending orders and the transactions fetched from the server: it is placed
+// if a matching pending order created since is not already attributed to
+// another entry, and filled if matching transactions not attributed to
+// another entry cover its size.
+// Otherwise it stays uncertain, ErrOrderUncertain being returned, until
+// JournalGracePeriod is over and it is failed.
+func (c *Client) ReconcileOrder(key string) (JournalEntry, error) {
+	if c.OrderJournal == nil {
+		return JournalEntry{}, fmt.Errorf("no order journal")
+	}
+	unlock := c.journalKeys.Lock(key)
+	defer unlock()
+	return c.reconcileOrder(key)
+}
+
+// ResolveOrder resolves an uncertain journal entry by hand, with the order
+// id found by the caller, or as failed if orderId is empty.
+func (c *Client) ResolveOrder(key string, orderId string) (JournalEntry, error) {
+	if c.OrderJournal == nil {
+		return JournalEntry{}, fmt.Errorf("no order journal")
+	}
+	unlock := c.journalKeys.Lock(key)
+	defer unlock()
+	entry, _, found, err := c.journalEntry(key)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	if !found {
+		return JournalEntry{}, fmt.Errorf("unknown order key %s", key)
+	}
+	if entry.State != JournalPending && entry.State != JournalUncertain {
+		return entry, fmt.Errorf("order %s is %s", key, entry.State)
+	}
+	if orderId == "" {
+		entry.State = JournalFailed
+		entry.Error = "resolved as not placed"
+	} else {
+		entry.State = JournalPlaced
+		entry.OrderId = orderId
+		entry.Error = ""
+	}
+	return entry, c.putJournalEntry(entry)
+}
+
+func (c *Client) reconcileOrder(key string) (JournalEntry, error) {
+	entry, _, found, err := c.journalEntry(key)
+	if err != nil {
+		return JournalEntry{}, err
+	}
+	if !found {
+		return JournalEntry{}, fmt.Errorf("unknown order key %s", key)
+	}
+	if entry.State != JournalPending && entry.State != JournalUncertain {
+		return entry, nil
+	}
+
+	orders, err := c.fetchPendingOrders()
+	if err != nil {
+		return entry, fmt.Errorf("getting pending orders: %v", err)
+	}
+	transactions, err := c.GetTransactions(entry.Created.Add(-24*time.Hour), time.Now().Add(24*time.Hour))
+	if err != nil {
+		return entry, fmt.Errorf("getting transactions: %v", err)
+	}
+
+	// orders and transactions are attributed to a single entry, whatever its
+	// key
+	c.journalMu.Lock()
+	defer c.journalMu.Unlock()
+	_, entries, _, err := c.journalEntry(key)
+	if err != nil {
+		return entry, err
+	}
+	attributed := make(map[string]bool)
+	claimed := make(map[int]bool)
+	for _, e := range entries {
+		if e.OrderId != "" {
+			attributed[e.OrderId] = true
+		}
+		for _, id := range e.TransactionIds {
+			claimed[id] = true
+		}
+	}
+	for _, order := range orders {
+		if !attributed[order.Id] && journalOrderMatches(entry, order) {
+			entry.State = JournalPlaced
+			entry.OrderId = order.Id
+			entry.Error = ""
+			return entry, c.putJournalEntryLocked(entry)
+		}
+	}
+	if ids, filled := journalFilledBy(entry, transactions, claimed); filled {
+		entry.State = JournalFilled
+		entry.TransactionIds = ids
+		entry.Error = ""
+		return entry, c.putJournalEntryLocked(entry)
+	}
+
+	if time.Now().Sub(entry.Created) < c.JournalGracePeriod {
+		return entry, ErrOrderUncertain
+	}
+	entry.State = JournalFailed
+	if entry.Error == "" {
+		entry.Error = "order not found while reconciling"
+	}
+	return entry, c.putJournalEntryLocked(entry)
+}
+
+// fetchPendingOrders requests all the pending orders from the server,
+// without updating the order cache.
+func (c *Client) fetchPendingOrders() ([]Order, error) {
+	response := &updateResponse{}
+	_, err := c.receiveSuccessReloginOn401(func() *sling.Sling {
+		return c.sling.New().
+			Get(fmt.Sprintf("trading/secure/v5/update/%d;jsessionid=%s", c.accountId, c.session())).
+			QueryStruct(struct {
+				Orders int `url:"orders"`
+			}{})
+	}, response)
+	if err != nil {
+		return nil, err
+	}
+	added, updated, _ := response.Orders.ConvertToOrders()
+	return append(added, updated...), nil
+}
+
+func journalOrderMatches(entry JournalEntry, order Order) bool {
+	input := entry.Input
+	if strconv.Itoa(order.ProductId) != input.ProductId ||
+		order.BuySell != input.BuySell ||
+		order.OrderType != input.OrderType ||
+		order.Size != input.Quantity {
+		return false
+	}
+	if !input.Price.IsZero() && !order.Price.Equal(input.Price) {
+		return false
+	}
+	if !input.StopPrice.IsZero() && !order.StopPrice.Equal(input.StopPrice) {
+		return false
+	}
+	// order dates have a minute precision
+	return !order.Date.IsZero() && !order.Date.Before(entry.Created.Truncate(time.Minute).Add(-time.Minute))
+}
+
+// journalFilledBy returns the transactions filling entry, in date order and
+// skipping the claimed ones, and whether they cover its size.
+func journalFilledBy(entry JournalEntry, transactions []Transaction, claimed map[int]bool) ([]int, bool) {
+	if entry.Input.Quantity <= 0 {
+		return nil, false
+	}
+	buySell := "B"
+	if entry.Input.BuySell == Sell {
+		buySell = "S"
+	}
+	sorted := append([]Transaction(nil), transactions...)
+	sortTransactionsByDateAscending(sorted)
+	filled := 0
+	var ids []int
+	for _, transaction := range sorted {
+		if claimed[transaction.Id] ||
+			strconv.Itoa(transaction.ProductId) != entry.Input.ProductId ||
+			transaction.BuySell != buySell ||
+			transaction.Date.Before(entry.Created.Add(-time.Minute)) {
+			continue
+		}
+		if transaction.Quantity < 0 {
+			filled -= transaction.Quantity
+		} else {
+			filled += transaction.Quantity
+		}
+		ids = append(ids, transaction.Id)
+		if filled >= entry.Input.Quantity {
+			return ids, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,159 @@
+package degiro
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_PlaceOrderWithKey(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "journal")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	confirms := 0
+	pendingOrders := ""
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		body := `{"data":[]}`
+		status := 200
+		switch {
+		case strings.Contains(req.URL.Path, "checkOrder"):
+			body = `{"data":{"confirmationId":"conf"}}`
+		case strings.Contains(req.URL.Path, "order/conf"):
+			confirms++
+			if confirms == 1 {
+				status = 504
+				body = `{}`
+			} else {
+				body = `{"data":{"orderId":"order-2"}}`
+			}
+		case strings.Contains(req.URL.Path, "/update/"):
+			assert.Equal("0", req.URL.Query().Get("orders"))
+			body = fmt.Sprintf(`{"orders":{"lastUpdated":1,"value":[%s]}}`, pendingOrders)
+		}
+		return &http.Response{
+			StatusCode: status,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.OrderJournal = NewFileOrderJournal(filepath.Join(dir, "journal"))
+	input := PlaceOrderInput{BuySell: Buy, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Day, Price: decimal.New(10, 0)}
+
+	_, err = degiro.PlaceOrderWithKey("key-1", input)
+	assert.NotNil(err)
+
+	// the order is not visible yet: it is not placed again
+	_, err = degiro.PlaceOrderWithKey("key-1", input)
+	assert.Equal(ErrOrderUncertain, err)
+	assert.Equal(1, confirms)
+
+	// the timed out order reached the server
+	order := `{"id":"%s","isAdded":true,"value":[{"name":"productId","value":1},{"name":"buysell","value":"B"},{"name":"size","value":10},{"name":"price","value":%d},{"name":"orderTypeId","value":0},{"name":"date","value":"%s"}]}`
+	now := time.Now().UTC().Format("15:04")
+	pendingOrders = fmt.Sprintf(order, "other", 11, now) + "," + fmt.Sprintf(order, "order-1", 10, now)
+	orderId, err := degiro.PlaceOrderWithKey("key-1", input)
+	assert.Nil(err)
+	assert.Equal("order-1", orderId)
+	assert.Equal(1, confirms)
+
+	// the journal survives restarts
+	degiro.OrderJournal = NewFileOrderJournal(filepath.Join(dir, "journal"))
+	orderId, err = degiro.PlaceOrderWithKey("key-1", input)
+	assert.Nil(err)
+	assert.Equal("order-1", orderId)
+
+	// a placed order is returned before the risk policy is checked
+	degiro.RiskPolicy = &RiskPolicy{MaxOpenOrders: 1}
+	degiro.orders.Add([]Order{{Id: "order-1", ProductId: 1}})
+	orderId, err = degiro.PlaceOrderWithKey("key-1", input)
+	assert.Nil(err)
+	assert.Equal("order-1", orderId)
+	degiro.RiskPolicy = nil
+
+	// a same order with another key is not attributed the same order id, and
+	// is only failed after the grace period
+	degiro.OrderJournal.Put(JournalEntry{Key: "key-2", Input: input, State: JournalUncertain, Created: time.Now()})
+	entry, err := degiro.ReconcileOrder("key-2")
+	assert.Equal(ErrOrderUncertain, err)
+	assert.Equal(JournalUncertain, entry.State)
+	degiro.JournalGracePeriod = 0
+	entry, err = degiro.ReconcileOrder("key-2")
+	assert.Nil(err)
+	assert.Equal(JournalFailed, entry.State)
+	orderId, err = degiro.PlaceOrderWithKey("key-2", input)
+	assert.Nil(err)
+	assert.Equal("order-2", orderId)
+	assert.Equal(2, confirms)
+
+	// an uncertain order resolved by hand
+	degiro.OrderJournal.Put(JournalEntry{Key: "key-3", Input: input, State: JournalUncertain, Created: time.Now()})
+	entry, err = degiro.ResolveOrder("key-3", "order-3")
+	assert.Nil(err)
+	assert.Equal(JournalPlaced, entry.State)
+	orderId, err = degiro.PlaceOrderWithKey("key-3", input)
+	assert.Nil(err)
+	assert.Equal("order-3", orderId)
+}
+
+func TestJournalFilledBy(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	entry := JournalEntry{Input: PlaceOrderInput{BuySell: Sell, ProductId: "1", Quantity: 10}, Created: now}
+	transactions := []Transaction{
+		{Id: 1, ProductId: 1, BuySell: "S", Quantity: -6, Date: now.Add(time.Second)},
+		{Id: 2, ProductId: 1, BuySell: "S", Quantity: -4, Date: now.Add(-time.Hour)},
+		{Id: 3, ProductId: 2, BuySell: "S", Quantity: -4, Date: now},
+	}
+	_, filled := journalFilledBy(entry, transactions, nil)
+	assert.False(filled)
+	transactions = append(transactions, Transaction{Id: 4, ProductId: 1, BuySell: "S", Quantity: -4, Date: now.Add(2 * time.Second)})
+	ids, filled := journalFilledBy(entry, transactions, nil)
+	assert.True(filled)
+	assert.Equal([]int{1, 4}, ids)
+
+	// a fill attributed to another entry does not fill this one
+	_, filled = journalFilledBy(entry, transactions, map[int]bool{4: true})
+	assert.False(filled)
+}
+
+func TestClient_ReconcileOrder_ClaimedTransactions(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		body := `{"orders":{"value":[]}}`
+		if strings.Contains(req.URL.Path, "transactions") {
+			body = fmt.Sprintf(`{"data":[{"id":1,"productId":1,"buysell":"B","quantity":10,"date":%q}]}`, now.Format(time.RFC3339))
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.JournalGracePeriod = 0
+	input := PlaceOrderInput{BuySell: Buy, OrderType: Limited, ProductId: "1", Quantity: 10, TimeType: Day, Price: decimal.New(10, 0)}
+	degiro.OrderJournal.Put(JournalEntry{Key: "key-1", Input: input, State: JournalUncertain, Created: now})
+	degiro.OrderJournal.Put(JournalEntry{Key: "key-2", Input: input, State: JournalUncertain, Created: now})
+
+	// a single fill resolves a single entry, the other one can be retried
+	entry, err := degiro.ReconcileOrder("key-1")
+	assert.Nil(err)
+	assert.Equal(JournalFilled, entry.State)
+	assert.Equal([]int{1}, entry.TransactionIds)
+	entry, err = degiro.ReconcileOrder("key-2")
+	assert.Nil(err)
+	assert.Equal(JournalFailed, entry.State)
+}
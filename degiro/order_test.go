@@ -0,0 +1,88 @@
+package degiro
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceOrderInput_MarshalJSON(t *testing.T) {
+	assert := assert.New(t)
+	buf, err := json.Marshal(PlaceOrderInput{
+		BuySell:   Buy,
+		OrderType: Limited,
+		ProductId: "332111",
+		Quantity:  10,
+		TimeType:  GoodTillCancelled,
+		Price:     decimal.RequireFromString("12.5"),
+	})
+	assert.Nil(err)
+	assert.Equal(`{"buySell":"BUY","orderType":0,"productId":"332111","size":10,"timeType":3,"price":"12.5"}`, string(buf))
+
+	buf, err = json.Marshal(PlaceOrderInput{
+		BuySell:         Sell,
+		OrderType:       TrailingStop,
+		ProductId:       "332111",
+		Quantity:        10,
+		TimeType:        Day,
+		Trail:           decimal.RequireFromString("2.5"),
+		TrailPercentage: true,
+	})
+	assert.Nil(err)
+	assert.Equal(`{"buySell":"SELL","orderType":13,"productId":"332111","size":10,"timeType":1,"stopPrice":"2.5","trailingStopPercentage":true}`, string(buf))
+
+	buf, err = json.Marshal(PlaceOrderInput{
+		BuySell:   Sell,
+		OrderType: StandardAmount,
+		ProductId: "332111",
+		TimeType:  Day,
+		Amount:    decimal.New(500, 0),
+	})
+	assert.Nil(err)
+	assert.Equal(`{"buySell":"SELL","orderType":14,"productId":"332111","timeType":1,"amount":"500"}`, string(buf))
+}
+
+func TestPlaceOrderInput_UnmarshalJSON(t *testing.T) {
+	assert := assert.New(t)
+	inputs := []PlaceOrderInput{
+		{
+			BuySell:   Buy,
+			OrderType: StopLimited,
+			ProductId: "332111",
+			Quantity:  10,
+			TimeType:  GoodTillCancelled,
+			Price:     decimal.RequireFromString("12.5"),
+			StopPrice: decimal.RequireFromString("12.4"),
+		},
+		{
+			BuySell:         Sell,
+			OrderType:       TrailingStop,
+			ProductId:       "332111",
+			Quantity:        10,
+			TimeType:        Day,
+			Trail:           decimal.RequireFromString("2.5"),
+			TrailPercentage: true,
+		},
+		{
+			BuySell:   Sell,
+			OrderType: StandardAmount,
+			ProductId: "332111",
+			TimeType:  Day,
+			Amount:    decimal.New(500, 0),
+		},
+	}
+	for _, input := range inputs {
+		buf, err := json.Marshal(input)
+		assert.Nil(err)
+		var decoded PlaceOrderInput
+		assert.Nil(json.Unmarshal(buf, &decoded))
+		assert.True(input.Price.Equal(decoded.Price))
+		assert.True(input.StopPrice.Equal(decoded.StopPrice))
+		assert.True(input.Amount.Equal(decoded.Amount))
+		assert.True(input.Trail.Equal(decoded.Trail))
+		input.Price, input.StopPrice, input.Amount, input.Trail = decoded.Price, decoded.StopPrice, decoded.Amount, decoded.Trail
+		assert.Equal(input, decoded)
+	}
+}
@@ -0,0 +1,174 @@
+package degiro
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// ValidationError is a reason why an order is not accepted for a product.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	var reasons []string
+	for _, err := range e {
+		reasons = append(reasons, err.Error())
+	}
+	return fmt.Sprintf("invalid order: %s", strings.Join(reasons, ", "))
+}
+
+// productOrderTypeNames are the names used in Product.BuyOrderTypes and
+// Product.SellOrderTypes
+var productOrderTypeNames = map[OrderType]string{
+	Limited:        "LIMIT",
+	StopLimited:    "STOPLIMIT",
+	MarketOrder:    "MARKET",
+	StopLoss:       "STOPLOSS",
+	TrailingStop:   "TRAILINGSTOP",
+	StandardAmount: "STANDARDAMOUNT",
+	StandardSize:   "STANDARDSIZE",
+}
+
+// productTimeTypeNames are the names used in Product.TimeTypes
+var productTimeTypeNames = map[TimeType]string{
+	Day:       "DAY",
+	Permanent: "GTC",
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateOrder checks that input can be placed on product. It returns nil
+// or ValidationErrors listing all the problems found.
+func ValidateOrder(input PlaceOrderInput, product Product) error {
+	var errs ValidationErrors
+	add := func(field string, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Field: field, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if !product.Tradable {
+		add("productId", "product %s is not tradable", product.Id)
+	}
+	if input.ProductId != product.Id {
+		add("productId", "order is for product %s, not %s", input.ProductId, product.Id)
+	}
+	if input.OrderType == StandardAmount {
+		if !input.Amount.IsPositive() {
+			add("amount", "a positive amount is required")
+		}
+		if input.Quantity != 0 {
+			add("size", "no size is allowed on amount orders")
+		}
+	} else {
+		if input.Quantity <= 0 {
+			add("size", "size must be positive, got %d", input.Quantity)
+		}
+		if !input.Amount.IsZero() {
+			add("amount", "no amount is allowed")
+		}
+	}
+
+	var allowedTypes []string
+	switch input.BuySell {
+	case Buy:
+		allowedTypes = product.BuyOrderTypes
+	case Sell:
+		allowedTypes = product.SellOrderTypes
+	default:
+		add("buySell", "unknown action type %q", input.BuySell)
+	}
+
+	typeName, known := productOrderTypeNames[input.OrderType]
+	if !known {
+		add("orderType", "unknown order type %d", input.OrderType)
+	} else {
+		if len(allowedTypes) > 0 && !containsName(allowedTypes, typeName) {
+			add("orderType", "%s orders are not allowed for %s on this product", typeName, input.BuySell)
+		}
+		switch input.OrderType {
+		case MarketOrder:
+			if !product.MarketAllowed {
+				add("orderType", "market orders are not allowed on this product")
+			}
+		case StopLoss:
+			if !product.StopLossAllowed {
+				add("orderType", "stop loss orders are not allowed on this product")
+			}
+		case StopLimited:
+			if !product.StopLimitOrderAllowed {
+				add("orderType", "stop limit orders are not allowed on this product")
+			}
+		case TrailingStop:
+			if !product.TrailingStopOrderAllowed {
+				add("orderType", "trailing stop orders are not allowed on this product")
+			}
+		case StandardAmount:
+			if input.BuySell == Sell && !product.SellAmountAllowed {
+				add("orderType", "amount sells are not allowed on this product")
+			}
+		}
+	}
+
+	timeName, known := productTimeTypeNames[input.TimeType]
+	if !known {
+		add("timeType", "unknown time type %d", input.TimeType)
+	} else {
+		if len(product.TimeTypes) > 0 && !containsName(product.TimeTypes, timeName) {
+			add("timeType", "%s orders are not allowed on this product", timeName)
+		}
+		if input.TimeType == Permanent && !product.GtcAllowed {
+			add("timeType", "good till cancelled orders are not allowed on this product")
+		}
+	}
+
+	switch input.OrderType {
+	case Limited, StopLimited:
+		if !input.Price.IsPositive() {
+			add("price", "a positive limit price is required")
+		}
+	case MarketOrder, StopLoss, TrailingStop, StandardAmount, StandardSize:
+		if !input.Price.IsZero() {
+			add("price", "no limit price is allowed")
+		}
+	}
+	switch input.OrderType {
+	case StopLoss, StopLimited:
+		if !input.StopPrice.IsPositive() {
+			add("stopPrice", "a positive stop price is required")
+		}
+	case Limited, MarketOrder, TrailingStop, StandardAmount, StandardSize:
+		if !input.StopPrice.IsZero() {
+			add("stopPrice", "no stop price is allowed")
+		}
+	}
+	if input.OrderType == TrailingStop {
+		if !input.Trail.IsPositive() {
+			add("trail", "a positive trail is required")
+		} else if input.TrailPercentage && input.Trail.GreaterThanOrEqual(decimal.New(100, 0)) {
+			add("trail", "a percentage trail must be below 100")
+		}
+	} else if !input.Trail.IsZero() {
+		add("trail", "a trail is only allowed on trailing stop orders")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
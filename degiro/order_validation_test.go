@@ -0,0 +1,105 @@
+package degiro
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func validationFields(err error) []string {
+	var fields []string
+	if errs, ok := err.(ValidationErrors); ok {
+		for _, e := range errs {
+			fields = append(fields, e.Field)
+		}
+	}
+	return fields
+}
+
+func TestValidateOrder(t *testing.T) {
+	assert := assert.New(t)
+	product := Product{
+		Id:                    "332111",
+		Tradable:              true,
+		TimeTypes:             []string{"DAY", "GTC"},
+		GtcAllowed:            true,
+		BuyOrderTypes:         []string{"LIMIT", "MARKET", "STOPLOSS"},
+		SellOrderTypes:        []string{"LIMIT", "MARKET", "STOPLOSS", "STOPLIMIT"},
+		MarketAllowed:         true,
+		StopLossAllowed:       true,
+		StopLimitOrderAllowed: true,
+	}
+	limit := PlaceOrderInput{
+		BuySell:   Buy,
+		OrderType: Limited,
+		ProductId: "332111",
+		Quantity:  10,
+		TimeType:  Day,
+		Price:     decimal.RequireFromString("12.5"),
+	}
+
+	assert.NoError(ValidateOrder(limit, product))
+
+	input := limit
+	input.Quantity = 0
+	input.Price = decimal.Zero
+	err := ValidateOrder(input, product)
+	assert.Equal([]string{"size", "price"}, validationFields(err))
+
+	input = limit
+	input.OrderType = StopLimited
+	assert.Equal([]string{"orderType", "stopPrice"}, validationFields(ValidateOrder(input, product)))
+	input.BuySell = Sell
+	input.StopPrice = decimal.RequireFromString("12")
+	assert.NoError(ValidateOrder(input, product))
+
+	input = limit
+	input.OrderType = MarketOrder
+	assert.Equal([]string{"price"}, validationFields(ValidateOrder(input, product)))
+	input.Price = decimal.Zero
+	assert.NoError(ValidateOrder(input, product))
+
+	notGtc := product
+	notGtc.GtcAllowed = false
+	notGtc.TimeTypes = []string{"DAY"}
+	notGtc.Tradable = false
+	input = limit
+	input.TimeType = Permanent
+	assert.Equal([]string{"productId", "timeType", "timeType"}, validationFields(ValidateOrder(input, notGtc)))
+}
+
+func TestValidateOrder_TrailingStopAndAmount(t *testing.T) {
+	assert := assert.New(t)
+	product := Product{
+		Id:                       "332111",
+		Tradable:                 true,
+		SellOrderTypes:           []string{"TRAILINGSTOP", "STANDARDAMOUNT"},
+		TrailingStopOrderAllowed: true,
+	}
+	trailing := PlaceOrderInput{
+		BuySell:         Sell,
+		OrderType:       TrailingStop,
+		ProductId:       "332111",
+		Quantity:        10,
+		TimeType:        GoodTillDay,
+		Trail:           decimal.RequireFromString("2.5"),
+		TrailPercentage: true,
+	}
+	assert.NoError(ValidateOrder(trailing, product))
+	trailing.Trail = decimal.New(150, 0)
+	assert.Equal([]string{"trail"}, validationFields(ValidateOrder(trailing, product)))
+
+	amount := PlaceOrderInput{
+		BuySell:   Sell,
+		OrderType: StandardAmount,
+		ProductId: "332111",
+		TimeType:  Day,
+		Amount:    decimal.New(500, 0),
+	}
+	assert.Equal([]string{"orderType"}, validationFields(ValidateOrder(amount, product)))
+	product.SellAmountAllowed = true
+	assert.NoError(ValidateOrder(amount, product))
+	amount.Quantity = 3
+	assert.Equal([]string{"size"}, validationFields(ValidateOrder(amount, product)))
+}
@@ -0,0 +1,390 @@
+package degiro
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro/internal/fill"
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+)
+
+// PaperClient is a simulated broker filling orders against the quotes of a
+// PaperMarket, usually a logged in Client:
+//
+//	paper := NewPaperClient(client, decimal.New(10000, 0))
+//	client.OnQuoteUpdate(paper.ProcessQuote)
+//
+// Orders are filled entirely, market orders at the ask or bid moved by
+// Slippage, limit orders at the ask or bid once it reaches the limit.
+// PlaceOrder returns an error for a buy needing more cash than available at
+// the current price, or a sell of more than the position. An order accepted
+// is rejected, i.e. removed without transaction, when its fill would need
+// more cash than available or sell more than the position at that time, e.g.
+// when another sell was filled before it. All amounts are considered to be
+// in the base currency.
+type PaperClient struct {
+	Fees FeeModel
+	// Slippage is the fraction of the price lost by market fills.
+	Slippage decimal.Decimal
+
+	mu           sync.Mutex
+	market       PaperMarket
+	now          func() time.Time
+	cash         decimal.Decimal
+	positions    map[string]int
+	orders       []*paperOrder
+	transactions *TransactionCache
+	lastOrderId  int
+	lastTransId  int
+
+	handlersMu    sync.RWMutex
+	orderHandlers []func(OrderUpdate)
+}
+
+type paperOrder struct {
+	order   Order
+	input   PlaceOrderInput
+	product Product
+	// triggered stop orders become market or limit orders
+	triggered bool
+	// extreme is the best price seen by a trailing stop
+	extreme decimal.Decimal
+}
+
+func NewPaperClient(market PaperMarket, cash decimal.Decimal) *PaperClient {
+	return &PaperClient{
+		market:       market,
+		now:          time.Now,
+		cash:         cash,
+		positions:    make(map[string]int),
+		transactions: newTransactionCache(),
+	}
+}
+
+func (p *PaperClient) PlaceOrder(input PlaceOrderInput) (string, error) {
+	product, found := p.market.GetProduct(input.ProductId)
+	if !found {
+		return "", fmt.Errorf("product %s not found", input.ProductId)
+	}
+	if err := ValidateOrder(input, product); err != nil {
+		return "", err
+	}
+	productId, err := strconv.Atoi(input.ProductId)
+	if err != nil {
+		return "", fmt.Errorf("parsing product id %s: %v", input.ProductId, err)
+	}
+
+	quote := p.market.GetQuote(product.VwdId)
+	p.mu.Lock()
+	if input.BuySell == Buy {
+		price := input.Price
+		if price.IsZero() {
+			price = firstPositive(quote.AskPrice, quote.LastPrice, input.StopPrice)
+		}
+		cost := input.Amount
+		if input.OrderType != StandardAmount {
+			cost = price.Mul(decimal.New(int64(input.Quantity), 0))
+		}
+		if cost.GreaterThan(p.cash) {
+			p.mu.Unlock()
+			return "", fmt.Errorf("insufficient cash: %s needed, %s available", cost, p.cash)
+		}
+	} else {
+		quantity := input.Quantity
+		if input.OrderType == StandardAmount {
+			price := firstPositive(input.Price, quote.BidPrice, quote.LastPrice, input.StopPrice)
+			if price.IsPositive() {
+				quantity = int(input.Amount.Div(price).IntPart())
+			}
+		}
+		if held := p.positions[input.ProductId]; quantity > held {
+			p.mu.Unlock()
+			return "", fmt.Errorf("insufficient position: %d to sell, %d held", quantity, held)
+		}
+	}
+	p.lastOrderId++
+	o := &paperOrder{
+		order: Order{
+			Id:           fmt.Sprintf("paper-%d", p.lastOrderId),
+			Date:         p.now(),
+			ProductId:    productId,
+			ProductName:  product.Name,
+			Currency:     product.Currency,
+			BuySell:      input.BuySell,
+			Size:         input.Quantity,
+			Quantity:     input.Quantity,
+			Price:        input.Price,
+			StopPrice:    input.StopPrice,
+			OrderType:    input.OrderType,
+			TimeType:     input.TimeType,
+			IsModifiable: true,
+			IsDeletable:  true,
+		},
+		input:   input,
+		product: product,
+	}
+	p.orders = append(p.orders, o)
+	update := OrderUpdate{Added: []Order{o.order}}
+	if fill := p.process(o, quote); fill {
+		update.Removed = []string{o.order.Id}
+	}
+	p.mu.Unlock()
+	p.notifyOrderHandlers(update)
+	return o.order.Id, nil
+}
+
+func (p *PaperClient) DeleteOrder(orderId string) error {
+	p.mu.Lock()
+	found := p.removeOrder(orderId)
+	p.mu.Unlock()
+	if !found {
+		return fmt.Errorf("order %s not found", orderId)
+	}
+	p.notifyOrderHandlers(OrderUpdate{Removed: []string{orderId}})
+	return nil
+}
+
+func (p *PaperClient) removeOrder(orderId string) bool {
+	for i, o := range p.orders {
+		if o.order.Id == orderId {
+			p.orders = append(p.orders[:i], p.orders[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessQuote fills the pending orders of the issue of quote which can be.
+func (p *PaperClient) ProcessQuote(quote streaming.ProductQuote) {
+	p.mu.Lock()
+	var filled []string
+	for _, o := range append([]*paperOrder(nil), p.orders...) {
+		if o.product.VwdId == quote.IssueId && p.process(o, quote) {
+			filled = append(filled, o.order.Id)
+		}
+	}
+	p.mu.Unlock()
+	if len(filled) > 0 {
+		p.notifyOrderHandlers(OrderUpdate{Removed: filled})
+	}
+}
+
+// process fills or rejects o if quote allows it, returning whether it was
+// removed
+func (p *PaperClient) process(o *paperOrder, quote streaming.ProductQuote) bool {
+	buy := o.input.BuySell == Buy
+	last := quote.LastPrice
+	ask := firstPositive(quote.AskPrice, last)
+	bid := firstPositive(quote.BidPrice, last)
+	market := bid
+	if buy {
+		market = ask
+	}
+	if !market.IsPositive() {
+		return false
+	}
+	trigger := firstPositive(last, market)
+
+	switch o.input.OrderType {
+	case StopLoss, StopLimited:
+		if !o.triggered {
+			o.triggered = (buy && trigger.GreaterThanOrEqual(o.input.StopPrice)) ||
+				(!buy && trigger.LessThanOrEqual(o.input.StopPrice))
+		}
+		if !o.triggered {
+			return false
+		}
+	case TrailingStop:
+		if o.extreme.IsZero() || (buy && trigger.LessThan(o.extreme)) || (!buy && trigger.GreaterThan(o.extreme)) {
+			o.extreme = trigger
+		}
+		trail := o.input.Trail
+		if o.input.TrailPercentage {
+			trail = o.extreme.Mul(o.input.Trail).Div(decimal.New(100, 0))
+		}
+		if (buy && trigger.LessThan(o.extreme.Add(trail))) || (!buy && trigger.GreaterThan(o.extreme.Sub(trail))) {
+			return false
+		}
+	}
+
+	var price decimal.Decimal
+	switch o.input.OrderType {
+	case Limited, StopLimited:
+		if (buy && market.GreaterThan(o.input.Price)) || (!buy && market.LessThan(o.input.Price)) {
+			return false
+		}
+		price = market
+	default:
+		slippage := market.Mul(p.Slippage)
+		if buy {
+			price = market.Add(slippage)
+		} else {
+			price = market.Sub(slippage)
+		}
+	}
+
+	quantity := o.input.Quantity
+	if o.input.OrderType == StandardAmount {
+		quantity = int(o.input.Amount.Div(price).IntPart())
+		if quantity <= 0 {
+			return false
+		}
+	}
+	err := fill.Check(o.input.BuySell == Sell, quantity, price, p.fee(o, price, quantity), p.cash, p.positions[o.input.ProductId])
+	if err != nil {
+		log.Warnf("rejecting paper order %s: %v", o.order.Id, err)
+		p.removeOrder(o.order.Id)
+		return true
+	}
+	p.fill(o, price, quantity)
+	return true
+}
+
+// SetFees replaces Fees, possibly while orders are being filled.
+func (p *PaperClient) SetFees(fees FeeModel) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Fees = fees
+}
+
+func (p *PaperClient) fee(o *paperOrder, price decimal.Decimal, quantity int) decimal.Decimal {
+	if p.Fees == nil {
+		return decimal.Zero
+	}
+	input := o.input
+	input.Quantity = quantity
+	return p.Fees.Fee(o.product, input, price)
+}
+
+func (p *PaperClient) fill(o *paperOrder, price decimal.Decimal, quantity int) {
+	p.removeOrder(o.order.Id)
+	p.lastTransId++
+	f := fill.New(o.input.BuySell == Sell, quantity, price, p.fee(o, price, quantity))
+	transaction := Transaction{
+		Id:                         p.lastTransId,
+		BuySell:                    f.BuySell,
+		Quantity:                   f.Quantity,
+		OrderType:                  o.input.OrderType,
+		ProductId:                  o.order.ProductId,
+		Price:                      price,
+		Date:                       p.now(),
+		Total:                      f.Total,
+		TotalInBaseCurrency:        f.Total,
+		FeeInBaseCurrency:          f.Fee,
+		TotalPlusFeeInBaseCurrency: f.TotalPlusFee,
+	}
+	p.cash = p.cash.Add(transaction.TotalPlusFeeInBaseCurrency)
+	p.positions[o.input.ProductId] += transaction.Quantity
+	p.transactions.Merge([]Transaction{transaction})
+}
+
+func firstPositive(values ...decimal.Decimal) decimal.Decimal {
+	for _, value := range values {
+		if value.IsPositive() {
+			return value
+		}
+	}
+	return decimal.Zero
+}
+
+func (p *PaperClient) GetPendingOrders(productId int) []Order {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var res []Order
+	for _, o := range p.orders {
+		if o.order.ProductId == productId {
+			res = append(res, o.order)
+		}
+	}
+	return res
+}
+
+func (p *PaperClient) GetAllPendingOrders() []Order {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var res []Order
+	for _, o := range p.orders {
+		res = append(res, o.order)
+	}
+	return res
+}
+
+// GetBalance values the positions at the last price of their product.
+func (p *PaperClient) GetBalance() Balance {
+	p.mu.Lock()
+	cash := p.cash
+	positions := make(map[string]int, len(p.positions))
+	for productId, size := range p.positions {
+		positions[productId] = size
+	}
+	p.mu.Unlock()
+
+	// the market is not called with mu held, as looking up a product can
+	// take a request
+	value := decimal.Zero
+	for productId, size := range positions {
+		if size == 0 {
+			continue
+		}
+		product, found := p.market.GetProduct(productId)
+		if !found {
+			continue
+		}
+		value = value.Add(p.market.GetQuote(product.VwdId).LastPrice.Mul(decimal.New(int64(size), 0)))
+	}
+	return Balance{
+		Cash:                cash,
+		FreeSpaceNewInEuros: cash,
+		ReportPortfValue:    value,
+		ReportNetliq:        cash.Add(value),
+	}
+}
+
+func (p *PaperClient) GetOpenedPositionForProduct(productId string) (Position, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	size := p.positions[productId]
+	if size == 0 {
+		return Position{}, false
+	}
+	return Position{ProductId: productId, Size: size}, true
+}
+
+func (p *PaperClient) GetTransactions(fromDate time.Time, toDate time.Time) ([]Transaction, error) {
+	p.transactions.RLock()
+	defer p.transactions.RUnlock()
+	var res []Transaction
+	for _, t := range p.transactions.transactions {
+		if !t.Date.Before(fromDate) && !t.Date.After(toDate) {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+func (p *PaperClient) GetAllHistoricalPositions() []HistoricalPosition {
+	return p.transactions.GetAllHistoricalPositions()
+}
+
+func (p *PaperClient) GetOpenedHistoricalPositionForProduct(productId string) (HistoricalPosition, bool) {
+	return p.transactions.GetOpenedHistoricalPositionForProduct(productId)
+}
+
+func (p *PaperClient) OnOrderUpdate(handler func(OrderUpdate)) {
+	p.handlersMu.Lock()
+	defer p.handlersMu.Unlock()
+	p.orderHandlers = append(p.orderHandlers, handler)
+}
+
+func (p *PaperClient) notifyOrderHandlers(update OrderUpdate) {
+	p.handlersMu.RLock()
+	handlers := p.orderHandlers
+	p.handlersMu.RUnlock()
+	for _, handler := range handlers {
+		handler(update)
+	}
+}
@@ -0,0 +1,148 @@
+package degiro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePaperMarket struct {
+	products map[string]Product
+	quotes   map[string]streaming.ProductQuote
+}
+
+func (m *fakePaperMarket) GetProduct(productId string) (Product, bool) {
+	product, found := m.products[productId]
+	return product, found
+}
+
+func (m *fakePaperMarket) GetQuote(productvwid string) streaming.ProductQuote {
+	return m.quotes[productvwid]
+}
+
+func (m *fakePaperMarket) setQuote(issueId string, bid float64, ask float64, last float64) streaming.ProductQuote {
+	quote := streaming.ProductQuote{
+		IssueId:   issueId,
+		BidPrice:  decimal.NewFromFloat(bid),
+		AskPrice:  decimal.NewFromFloat(ask),
+		LastPrice: decimal.NewFromFloat(last),
+	}
+	m.quotes[issueId] = quote
+	return quote
+}
+
+func TestPaperClient(t *testing.T) {
+	assert := assert.New(t)
+	market := &fakePaperMarket{
+		products: map[string]Product{
+			"1": {Id: "1", VwdId: "vwd1", Tradable: true, MarketAllowed: true, StopLossAllowed: true, TrailingStopOrderAllowed: true},
+		},
+		quotes: make(map[string]streaming.ProductQuote),
+	}
+	market.setQuote("vwd1", 9.9, 10, 10)
+	paper := NewPaperClient(market, decimal.New(1000, 0))
+	paper.Fees = FlatFee{Fixed: decimal.New(2, 0)}
+	paper.Slippage = decimal.RequireFromString("0.01")
+	now := time.Date(2019, 10, 14, 10, 0, 0, 0, time.UTC)
+	paper.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	var removed []string
+	paper.OnOrderUpdate(func(update OrderUpdate) {
+		removed = append(removed, update.Removed...)
+	})
+
+	_, err := paper.PlaceOrder(PlaceOrderInput{BuySell: Buy, OrderType: MarketOrder, ProductId: "1", Quantity: 200, TimeType: Day})
+	assert.NotNil(err)
+
+	// market buy filled at once at the ask plus slippage
+	buyId, err := paper.PlaceOrder(PlaceOrderInput{BuySell: Buy, OrderType: MarketOrder, ProductId: "1", Quantity: 50, TimeType: Day})
+	assert.Nil(err)
+	assert.Equal([]string{buyId}, removed)
+	position, found := paper.GetOpenedPositionForProduct("1")
+	assert.True(found)
+	assert.Equal(50, position.Size)
+	assert.Equal("493", paper.GetBalance().Cash.String())
+
+	// limit sell waiting for the bid
+	limitId, err := paper.PlaceOrder(PlaceOrderInput{BuySell: Sell, OrderType: Limited, ProductId: "1", Quantity: 20, TimeType: Day, Price: decimal.New(11, 0)})
+	assert.Nil(err)
+	trailingId, err := paper.PlaceOrder(PlaceOrderInput{BuySell: Sell, OrderType: TrailingStop, ProductId: "1", Quantity: 30, TimeType: Day, Trail: decimal.New(10, 0), TrailPercentage: true})
+	assert.Nil(err)
+	assert.Equal(2, len(paper.GetPendingOrders(1)))
+
+	paper.ProcessQuote(market.setQuote("vwd1", 11, 11.1, 11))
+	assert.Equal([]string{buyId, limitId}, removed)
+	paper.ProcessQuote(market.setQuote("vwd1", 11.9, 12, 12))
+	paper.ProcessQuote(market.setQuote("vwd1", 10.9, 11, 10.9))
+	assert.Equal(1, len(paper.GetAllPendingOrders()))
+	paper.ProcessQuote(market.setQuote("vwd1", 10.8, 10.9, 10.8))
+	assert.Equal([]string{buyId, limitId, trailingId}, removed)
+
+	_, found = paper.GetOpenedPositionForProduct("1")
+	assert.False(found)
+	transactions, err := paper.GetTransactions(time.Time{}, time.Now())
+	assert.Nil(err)
+	assert.Equal(3, len(transactions))
+	positions := paper.GetAllHistoricalPositions()
+	if assert.Equal(1, len(positions)) {
+		assert.Equal(0, positions[0].GetSize())
+		// bought at 10.1 + 2/50 fee, sold 20 at 11 and 30 at 10.692, 2 of
+		// fee each
+		assert.Equal("29.76", positions[0].GetPastPerformance().String())
+	}
+	assert.NotNil(paper.DeleteOrder(trailingId))
+}
+
+func TestPaperClient_RejectAtFill(t *testing.T) {
+	assert := assert.New(t)
+	market := &fakePaperMarket{
+		products: map[string]Product{
+			"1": {Id: "1", VwdId: "vwd1", Tradable: true, MarketAllowed: true, StopLossAllowed: true},
+		},
+		quotes: make(map[string]streaming.ProductQuote),
+	}
+	market.setQuote("vwd1", 9.9, 10, 10)
+	paper := NewPaperClient(market, decimal.New(1000, 0))
+	var removed []string
+	paper.OnOrderUpdate(func(update OrderUpdate) {
+		removed = append(removed, update.Removed...)
+	})
+
+	// the cash is spent before the resting limit buy is filled
+	limitId, err := paper.PlaceOrder(PlaceOrderInput{BuySell: Buy, OrderType: Limited, ProductId: "1", Quantity: 60, TimeType: Day, Price: decimal.New(9, 0)})
+	assert.Nil(err)
+	_, err = paper.PlaceOrder(PlaceOrderInput{BuySell: Buy, OrderType: MarketOrder, ProductId: "1", Quantity: 90, TimeType: Day})
+	assert.Nil(err)
+	paper.ProcessQuote(market.setQuote("vwd1", 8.9, 9, 9))
+	assert.Contains(removed, limitId)
+	position, _ := paper.GetOpenedPositionForProduct("1")
+	assert.Equal(90, position.Size)
+	assert.True(paper.GetBalance().Cash.IsPositive())
+
+	// no naked short
+	_, err = paper.PlaceOrder(PlaceOrderInput{BuySell: Sell, OrderType: StopLoss, ProductId: "1", Quantity: 100, TimeType: Day, StopPrice: decimal.New(8, 0)})
+	assert.NotNil(err)
+	_, err = paper.PlaceOrder(PlaceOrderInput{BuySell: Sell, OrderType: StandardAmount, ProductId: "1", Amount: decimal.New(900, 0), TimeType: Day})
+	assert.NotNil(err)
+
+	// the position is sold by another order before the stop is filled
+	stopId, err := paper.PlaceOrder(PlaceOrderInput{BuySell: Sell, OrderType: StopLoss, ProductId: "1", Quantity: 90, TimeType: Day, StopPrice: decimal.New(8, 0)})
+	assert.Nil(err)
+	limitId, err = paper.PlaceOrder(PlaceOrderInput{BuySell: Sell, OrderType: Limited, ProductId: "1", Quantity: 90, TimeType: Day, Price: decimal.RequireFromString("9.5")})
+	assert.Nil(err)
+	paper.ProcessQuote(market.setQuote("vwd1", 9.6, 9.7, 9.6))
+	assert.Contains(removed, limitId)
+	assert.NotContains(removed, stopId)
+	paper.ProcessQuote(market.setQuote("vwd1", 7.9, 8, 7.9))
+	assert.Contains(removed, stopId)
+	_, found := paper.GetOpenedPositionForProduct("1")
+	assert.False(found)
+	transactions, err := paper.GetTransactions(time.Time{}, time.Now())
+	assert.Nil(err)
+	assert.Equal(2, len(transactions))
+}
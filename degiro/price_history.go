@@ -0,0 +1,135 @@
+package degiro
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+)
+
+const priceHistoryUrl = "https://charting.vwdservices.com/hchart/v1/deGiro/data.js"
+
+// isoDuration matches the ISO 8601 durations used by the charting service,
+// e.g. P1D or PT15M
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+func parseIsoDuration(s string) (time.Duration, error) {
+	match := isoDuration.FindStringSubmatch(s)
+	if match == nil || s == "P" || s == "PT" {
+		return 0, fmt.Errorf("unsupported duration %q", s)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var res time.Duration
+	for i, unit := range units {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return 0, err
+		}
+		res += time.Duration(n) * unit
+	}
+	return res, nil
+}
+
+// GetPriceHistory returns the OHLC candles of a product from the charting
+// service, period and resolution being ISO 8601 durations such as P1Y and
+// P1D. Resolutions in months are not supported.
+func (c *Client) GetPriceHistory(productvwid string, period string, resolution string) ([]streaming.Candle, error) {
+	interval, err := parseIsoDuration(resolution)
+	if err != nil {
+		return nil, fmt.Errorf("parsing resolution: %v", err)
+	}
+	type priceHistoryResponse struct {
+		Start  string `json:"start"`
+		Series []struct {
+			Type string          `json:"type"`
+			Data [][]json.Number `json:"data"`
+		} `json:"series"`
+	}
+	response := &priceHistoryResponse{}
+	// the charting service is not part of the DEGIRO session: a 401 is not
+	// fixed by a relogin
+	resp, err := c.sling.New().
+		Get(priceHistoryUrl).
+		QueryStruct(&struct {
+			RequestId  int    `url:"requestid"`
+			Resolution string `url:"resolution"`
+			Culture    string `url:"culture"`
+			Period     string `url:"period"`
+			Series     string `url:"series"`
+			Format     string `url:"format"`
+			Timezone   string `url:"tz"`
+			UserToken  int    `url:"userToken"`
+		}{
+			RequestId:  1,
+			Resolution: resolution,
+			Culture:    "en-US",
+			Period:     period,
+			Series:     fmt.Sprintf("ohlc:issueid:%s", productvwid),
+			Format:     "json",
+			Timezone:   "Europe/Amsterdam",
+			UserToken:  c.clientId,
+		}).ReceiveSuccess(response)
+	if err != nil {
+		return nil, fmt.Errorf("requesting price history: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("requesting price history: %d - %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	loc, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		loc = time.UTC
+	}
+	start, err := time.ParseInLocation("2006-01-02T15:04:05", response.Start, loc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing start %q: %v", response.Start, err)
+	}
+	var res []streaming.Candle
+	for _, series := range response.Series {
+		if series.Type != "ohlc" {
+			continue
+		}
+		for _, point := range series.Data {
+			if len(point) < 5 {
+				continue
+			}
+			var values []decimal.Decimal
+			for _, n := range point {
+				if n == "" {
+					// null values of the periods without trades
+					break
+				}
+				value, err := decimal.NewFromString(n.String())
+				if err != nil {
+					return nil, fmt.Errorf("parsing price history value %s: %v", n, err)
+				}
+				values = append(values, value)
+			}
+			if len(values) < 5 {
+				continue
+			}
+			candleStart := start.Add(time.Duration(values[0].IntPart()) * interval)
+			if interval >= 24*time.Hour {
+				// days are counted in calendar days, whatever the DST changes
+				candleStart = start.AddDate(0, 0, int(values[0].IntPart()*int64(interval/(24*time.Hour))))
+			}
+			res = append(res, streaming.Candle{
+				IssueId:  productvwid,
+				Start:    candleStart,
+				Interval: interval,
+				Open:     values[1],
+				High:     values[2],
+				Low:      values[3],
+				Close:    values[4],
+			})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,73 @@
+package degiro
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_GetPriceHistory(t *testing.T) {
+	assert := assert.New(t)
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal("charting.vwdservices.com", req.URL.Host)
+		assert.Equal("ohlc:issueid:360148977", req.URL.Query().Get("series"))
+		assert.Equal("P1D", req.URL.Query().Get("resolution"))
+		assert.Equal("123", req.URL.Query().Get("userToken"))
+		return &http.Response{
+			StatusCode: 200,
+			Body: ioutil.NopCloser(bytes.NewBufferString(`{"requestid":"1","start":"2019-10-25T00:00:00","resolution":"P1D","series":[` +
+				`{"type":"ohlc","data":[[0,10.5,11,10.2,10.8],[1,null,null,null,null],[3,10.8,11.2,10.7,11.1]]}]}`)),
+			Header: getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.clientId = 123
+
+	candles, err := degiro.GetPriceHistory("360148977", "P1W", "P1D")
+	assert.Nil(err)
+	if assert.Equal(2, len(candles)) {
+		assert.Equal("10.5", candles[0].Open.String())
+		assert.Equal("11.1", candles[1].Close.String())
+		assert.Equal(24*time.Hour, candles[1].Interval)
+		// the DST change of the 27th does not shift the candles
+		assert.Equal("2019-10-28 00:00", candles[1].Start.Format("2006-01-02 15:04"))
+	}
+
+	_, err = degiro.GetPriceHistory("360148977", "P1Y", "P1M")
+	assert.NotNil(err)
+}
+
+func TestClient_GetPriceHistory_Unauthorized(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		requests++
+		assert.Equal("charting.vwdservices.com", req.URL.Host)
+		return &http.Response{
+			StatusCode: 401,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{}`)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+
+	_, err := degiro.GetPriceHistory("360148977", "P1W", "P1D")
+	assert.NotNil(err)
+	assert.Equal(1, requests)
+}
+
+func TestParseIsoDuration(t *testing.T) {
+	assert := assert.New(t)
+	d, err := parseIsoDuration("PT15M")
+	assert.Nil(err)
+	assert.Equal(15*time.Minute, d)
+	d, err = parseIsoDuration("P1W")
+	assert.Nil(err)
+	assert.Equal(7*24*time.Hour, d)
+	_, err = parseIsoDuration("P1Y")
+	assert.NotNil(err)
+}
@@ -2,11 +2,10 @@ package degiro
 
 import (
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/dghubble/sling"
 	"github.com/shopspring/decimal"
-	log "github.com/sirupsen/logrus"
 )
 
 type ProductType int
@@ -31,9 +30,14 @@ type productTime struct {
 }
 
 func (t *productTime) UnmarshalJSON(buf []byte) error {
-	tt, err := time.Parse("2006-01-02", strings.Trim(string(buf), `"`))
+	s := strings.Trim(string(buf), `"`)
+	if s == "" || s == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	tt, err := time.Parse("2006-01-02", s)
 	if err != nil {
-		tt, err = time.Parse("2-1-2006", strings.Trim(string(buf), `"`))
+		tt, err = time.Parse("2-1-2006", s)
 		if err != nil {
 			return err
 		}
@@ -42,141 +46,11 @@ func (t *productTime) UnmarshalJSON(buf []byte) error {
 	return nil
 }
 
-type ProductCacheItem struct {
-	Product    Product
-	LastUpdate time.Time
-}
-
-type ProductCache struct {
-	sync.RWMutex
-	cache                     []ProductCacheItem
-	client                    *Client
-	CacheInvalidationDuration time.Duration
-	productsToUpdate          map[string]bool
-	updateLock                sync.Mutex
-}
-
-func newProductCache(client *Client, invalidationDuration time.Duration) *ProductCache {
-	cache := &ProductCache{
-		RWMutex:                   sync.RWMutex{},
-		cache:                     []ProductCacheItem{},
-		client:                    client,
-		CacheInvalidationDuration: invalidationDuration,
-		updateLock:                sync.Mutex{},
-		productsToUpdate:          make(map[string]bool),
-	}
-	go cache.updateCache()
-	return cache
-
-}
-
-func (c *ProductCache) get(productid string) (ProductCacheItem, bool) {
-	c.RLock()
-	defer c.RUnlock()
-	for _, item := range c.cache {
-		if item.Product.Id == productid {
-			return item, true
-		}
-	}
-	return ProductCacheItem{}, false
-}
-func (c *ProductCache) remove(productid string) {
-	c.Lock()
-	defer c.Unlock()
-	for i := len(c.cache) - 1; i >= 0; i-- {
-		if c.cache[i].Product.Id == productid {
-			c.cache[i] = c.cache[len(c.cache)-1]
-			c.cache = c.cache[:len(c.cache)-1]
-		}
-	}
-}
-
-func (c *ProductCache) add(product Product) {
-	c.Lock()
-	defer c.Unlock()
-	found := false
-	for i, item := range c.cache {
-		if item.Product.Id == product.Id {
-			c.cache[i].Product = product
-			c.cache[i].LastUpdate = time.Now()
-			found = true
-		}
-	}
-	if !found {
-		c.cache = append(c.cache, ProductCacheItem{
-			Product:    product,
-			LastUpdate: time.Now(),
-		})
-	}
-}
-
-func (c *ProductCache) updateCache() {
-	ticker := time.NewTicker(time.Minute)
-	for {
-		select {
-		case <-ticker.C:
-			func() {
-				c.updateLock.Lock()
-				defer c.updateLock.Unlock()
-				if len(c.productsToUpdate) == 0 {
-					return
-				}
-				var ids []string
-				for s := range c.productsToUpdate {
-					ids = append(ids, s)
-				}
-				newProducts, err := c.client.getProducts(ids)
-				if err != nil {
-					log.Warnf("error while updating product infos: %v", err)
-				}
-				for _, product := range newProducts {
-					c.add(product)
-				}
-				c.productsToUpdate = make(map[string]bool)
-			}()
-		}
-
+func (t productTime) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
 	}
-}
-
-func (c *ProductCache) GetProducts(productids []string) []Product {
-	var productsInCache []Product
-	for i := len(productids) - 1; i >= 0; i-- {
-		item, ok := c.get(productids[i])
-		if !ok {
-			continue
-		}
-		if time.Now().Sub(item.LastUpdate) > c.CacheInvalidationDuration {
-			func() {
-				c.updateLock.Lock()
-				defer c.updateLock.Unlock()
-				c.productsToUpdate[productids[i]] = true
-			}()
-		}
-		productsInCache = append(productsInCache, item.Product)
-		productids[i] = productids[len(productids)-1]
-		productids = productids[:len(productids)-1]
-	}
-
-	if len(productids) == 0 {
-		return productsInCache
-	}
-	newProducts, err := c.client.getProducts(productids)
-	if err != nil {
-		log.Warnf("error while getting product infos: %v", err)
-	}
-	for _, product := range newProducts {
-		c.add(product)
-	}
-	return append(productsInCache, newProducts...)
-}
-
-func (c *ProductCache) GetProduct(productId string) (Product, bool) {
-	products := c.GetProducts([]string{productId})
-	if len(products) == 0 {
-		return Product{}, false
-	}
-	return products[0], true
+	return []byte(`"` + t.Format("2006-01-02") + `"`), nil
 }
 
 type Product struct {
@@ -225,6 +99,7 @@ type Product struct {
 type SearchProductsOptions struct {
 	SearchText  string
 	Limit       int
+	Offset      int
 	ProductType ProductType
 }
 
@@ -234,27 +109,45 @@ func (c *Client) SearchProducts(options SearchProductsOptions) ([]Product, error
 		Products []Product `json:"products"`
 	}
 	response := &searchProductResponse{}
-	_, err := c.ReceiveSuccessReloginOn401(c.sling.New().
-		Get("product_search/secure/v5/products/lookup").
-		QueryStruct(&struct {
-			AccountId   int64       `url:"intAccount"`
-			SessionId   string      `url:"sessionId"`
-			SearchText  string      `url:"searchText"`
-			Limit       int         `url:"limit"`
-			ProductType ProductType `url:"productTypeId,omitempty"`
-		}{
-			AccountId:   c.accountId,
-			SessionId:   c.sessionId,
-			SearchText:  options.SearchText,
-			Limit:       options.Limit,
-			ProductType: options.ProductType,
-		}), response)
+	_, err := c.receiveSuccessReloginOn401(func() *sling.Sling {
+		return c.sling.New().
+			Get("product_search/secure/v5/products/lookup").
+			QueryStruct(&struct {
+				AccountId   int64       `url:"intAccount"`
+				SessionId   string      `url:"sessionId"`
+				SearchText  string      `url:"searchText"`
+				Limit       int         `url:"limit"`
+				Offset      int         `url:"offset,omitempty"`
+				ProductType ProductType `url:"productTypeId,omitempty"`
+			}{
+				AccountId:   c.accountId,
+				SessionId:   c.session(),
+				SearchText:  options.SearchText,
+				Limit:       options.Limit,
+				Offset:      options.Offset,
+				ProductType: options.ProductType,
+			})
+	}, response)
 	if err != nil {
 		return nil, err
 	}
 	return response.Products, nil
 }
 
+// IterateProducts walks through all the pages of a free text search, Limit
+// being used as page size.
+func (c *Client) IterateProducts(options SearchProductsOptions) *SearchIterator {
+	return c.iterateProductSearch("products/lookup", &struct {
+		SearchPaging
+		SearchText  string      `url:"searchText"`
+		ProductType ProductType `url:"productTypeId,omitempty"`
+	}{
+		SearchPaging: SearchPaging{Offset: options.Offset, Limit: options.Limit},
+		SearchText:   options.SearchText,
+		ProductType:  options.ProductType,
+	})
+}
+
 func (c *Client) SearchProduct(searchtext string) (*Product, bool, error) {
 	products, err := c.SearchProducts(SearchProductsOptions{
 		SearchText: searchtext,
@@ -274,15 +167,17 @@ func (c *Client) getProducts(productIds []string) ([]Product, error) {
 		Products map[int64]Product `json:"data"`
 	}
 	response := &getProductResponse{}
-	_, err := c.ReceiveSuccessReloginOn401(c.sling.New().
-		Post("product_search/secure/v5/products/info").
-		QueryStruct(&struct {
-			AccountId int64  `url:"intAccount"`
-			SessionId string `url:"sessionId"`
-		}{
-			AccountId: c.accountId,
-			SessionId: c.sessionId,
-		}).BodyJSON(productIds), response)
+	_, err := c.receiveSuccessReloginOn401(func() *sling.Sling {
+		return c.sling.New().
+			Post("product_search/secure/v5/products/info").
+			QueryStruct(&struct {
+				AccountId int64  `url:"intAccount"`
+				SessionId string `url:"sessionId"`
+			}{
+				AccountId: c.accountId,
+				SessionId: c.session(),
+			}).BodyJSON(productIds)
+	}, response)
 	if err != nil {
 		return nil, err
 	}
@@ -300,3 +195,7 @@ func (c *Client) GetProducts(productIds []string) []Product {
 func (c *Client) GetProduct(productId string) (Product, bool) {
 	return c.products.GetProduct(productId)
 }
+
+func (c *Client) ProductCache() *ProductCache {
+	return c.products
+}
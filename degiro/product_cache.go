@@ -0,0 +1,304 @@
+package degiro
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ProductCacheItem struct {
+	Product    Product
+	LastUpdate time.Time
+}
+
+type ProductCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Fetches   uint64
+	Size      int
+}
+
+func (s ProductCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// productCall is a fetch in progress for a product, shared by all the
+// callers asking for it at the same time
+type productCall struct {
+	done    chan struct{}
+	product Product
+	found   bool
+}
+
+// ProductCache keeps product infos in memory. Products older than
+// CacheInvalidationDuration are still returned but refreshed in background,
+// and the least recently used products are evicted once MaxSize products
+// are cached.
+type ProductCache struct {
+	sync.RWMutex
+	CacheInvalidationDuration time.Duration
+	// MaxSize is the maximum number of cached products, 0 meaning no limit.
+	MaxSize int
+
+	items map[string]*list.Element
+	lru   *list.List
+	stats ProductCacheStats
+	fetch func(productIds []string) ([]Product, error)
+	store ProductStore
+
+	callsMu sync.Mutex
+	calls   map[string]*productCall
+
+	productsToUpdate map[string]bool
+	updateLock       sync.Mutex
+}
+
+func newProductCache(fetch func(productIds []string) ([]Product, error), invalidationDuration time.Duration) *ProductCache {
+	cache := &ProductCache{
+		RWMutex:                   sync.RWMutex{},
+		CacheInvalidationDuration: invalidationDuration,
+		items:                     make(map[string]*list.Element),
+		lru:                       list.New(),
+		fetch:                     fetch,
+		calls:                     make(map[string]*productCall),
+		updateLock:                sync.Mutex{},
+		productsToUpdate:          make(map[string]bool),
+	}
+	go cache.updateCache()
+	return cache
+}
+
+func (c *ProductCache) get(productid string) (ProductCacheItem, bool) {
+	c.Lock()
+	defer c.Unlock()
+	element, found := c.items[productid]
+	if !found {
+		c.stats.Misses++
+		return ProductCacheItem{}, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(element)
+	return element.Value.(ProductCacheItem), true
+}
+
+func (c *ProductCache) peek(productid string) (Product, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	element, found := c.items[productid]
+	if !found {
+		return Product{}, false
+	}
+	return element.Value.(ProductCacheItem).Product, true
+}
+
+func (c *ProductCache) remove(productid string) {
+	c.Lock()
+	defer c.Unlock()
+	if element, found := c.items[productid]; found {
+		c.lru.Remove(element)
+		delete(c.items, productid)
+	}
+}
+
+func (c *ProductCache) add(product Product) {
+	c.addItem(ProductCacheItem{
+		Product:    product,
+		LastUpdate: time.Now(),
+	})
+}
+
+func (c *ProductCache) addItem(item ProductCacheItem) {
+	c.Lock()
+	defer c.Unlock()
+	if element, found := c.items[item.Product.Id]; found {
+		element.Value = item
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.items[item.Product.Id] = c.lru.PushFront(item)
+	for c.MaxSize > 0 && c.lru.Len() > c.MaxSize {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(ProductCacheItem).Product.Id)
+		c.stats.Evictions++
+	}
+}
+
+func (c *ProductCache) Stats() ProductCacheStats {
+	c.RLock()
+	defer c.RUnlock()
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
+
+// SetStore loads the products persisted in store and writes the products
+// fetched afterwards through it. Loaded products keep their last update
+// date, so that the ones older than CacheInvalidationDuration are refreshed
+// when used.
+func (c *ProductCache) SetStore(store ProductStore) error {
+	items, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("loading products: %v", err)
+	}
+	for _, item := range items {
+		c.addItem(item)
+	}
+	c.Lock()
+	c.store = store
+	c.Unlock()
+	return nil
+}
+
+func (c *ProductCache) fetchProducts(productIds []string) []Product {
+	c.Lock()
+	c.stats.Fetches++
+	store := c.store
+	c.Unlock()
+	products, err := c.fetch(productIds)
+	if err != nil {
+		log.Warnf("error while getting product infos: %v", err)
+	}
+	var items []ProductCacheItem
+	for _, product := range products {
+		item := ProductCacheItem{
+			Product:    product,
+			LastUpdate: time.Now(),
+		}
+		c.addItem(item)
+		items = append(items, item)
+	}
+	if store != nil && len(items) > 0 {
+		if err := store.Put(items); err != nil {
+			log.Warnf("error while storing product infos: %v", err)
+		}
+	}
+	return products
+}
+
+func (c *ProductCache) updateCache() {
+	ticker := time.NewTicker(time.Minute)
+	for {
+		select {
+		case <-ticker.C:
+			func() {
+				c.updateLock.Lock()
+				defer c.updateLock.Unlock()
+				if len(c.productsToUpdate) == 0 {
+					return
+				}
+				var ids []string
+				for s := range c.productsToUpdate {
+					ids = append(ids, s)
+				}
+				c.fetchProducts(ids)
+				c.productsToUpdate = make(map[string]bool)
+			}()
+		}
+
+	}
+}
+
+// GetProducts returns the products found for productids, in the same order.
+// Products missing from the cache are fetched in a single request, callers
+// asking for the same products at the same time sharing that request.
+func (c *ProductCache) GetProducts(productids []string) []Product {
+	found := make(map[string]Product)
+	var missing []string
+	seen := make(map[string]bool)
+	for _, id := range productids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		item, ok := c.get(id)
+		if !ok {
+			missing = append(missing, id)
+			continue
+		}
+		if time.Now().Sub(item.LastUpdate) > c.CacheInvalidationDuration {
+			func() {
+				c.updateLock.Lock()
+				defer c.updateLock.Unlock()
+				c.productsToUpdate[id] = true
+			}()
+		}
+		found[id] = item.Product
+	}
+
+	if len(missing) > 0 {
+		for id, call := range c.fetchMissing(missing) {
+			<-call.done
+			if call.found {
+				found[id] = call.product
+			}
+		}
+	}
+
+	var res []Product
+	for _, id := range productids {
+		if product, ok := found[id]; ok {
+			res = append(res, product)
+			delete(found, id)
+		}
+	}
+	return res
+}
+
+// fetchMissing fetches the products no other caller is already fetching
+// and returns the calls to wait for all of them
+func (c *ProductCache) fetchMissing(productIds []string) map[string]*productCall {
+	calls := make(map[string]*productCall)
+	var owned []string
+	c.callsMu.Lock()
+	for _, id := range productIds {
+		call, inProgress := c.calls[id]
+		if !inProgress {
+			if product, cached := c.peek(id); cached {
+				// fetched by another caller since the cache was looked up
+				call = &productCall{done: make(chan struct{}), product: product, found: true}
+				close(call.done)
+				calls[id] = call
+				continue
+			}
+			call = &productCall{done: make(chan struct{})}
+			c.calls[id] = call
+			owned = append(owned, id)
+		}
+		calls[id] = call
+	}
+	c.callsMu.Unlock()
+
+	if len(owned) == 0 {
+		return calls
+	}
+	products := c.fetchProducts(owned)
+	for _, product := range products {
+		if call, ok := calls[product.Id]; ok {
+			call.product = product
+			call.found = true
+		}
+	}
+	c.callsMu.Lock()
+	for _, id := range owned {
+		delete(c.calls, id)
+		close(calls[id].done)
+	}
+	c.callsMu.Unlock()
+	return calls
+}
+
+func (c *ProductCache) GetProduct(productId string) (Product, bool) {
+	products := c.GetProducts([]string{productId})
+	if len(products) == 0 {
+		return Product{}, false
+	}
+	return products[0], true
+}
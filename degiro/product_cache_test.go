@@ -0,0 +1,111 @@
+package degiro
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductCache_GetProducts(t *testing.T) {
+	assert := assert.New(t)
+	var fetched [][]string
+	cache := newProductCache(func(productIds []string) ([]Product, error) {
+		fetched = append(fetched, append([]string(nil), productIds...))
+		var res []Product
+		for _, id := range productIds {
+			if id != "404" {
+				res = append(res, Product{Id: id})
+			}
+		}
+		return res, nil
+	}, time.Hour)
+
+	cache.add(Product{Id: "1"})
+	ids := []string{"3", "1", "404", "2"}
+	products := cache.GetProducts(ids)
+	assert.Equal([]string{"3", "1", "404", "2"}, ids)
+	if assert.Equal(3, len(products)) {
+		assert.Equal("3", products[0].Id)
+		assert.Equal("1", products[1].Id)
+		assert.Equal("2", products[2].Id)
+	}
+	assert.Equal([][]string{{"3", "404", "2"}}, fetched)
+
+	stats := cache.Stats()
+	assert.Equal(uint64(1), stats.Hits)
+	assert.Equal(uint64(3), stats.Misses)
+	assert.Equal(uint64(1), stats.Fetches)
+	assert.Equal(3, stats.Size)
+	assert.Equal(0.25, stats.HitRate())
+}
+
+func TestProductCache_Eviction(t *testing.T) {
+	assert := assert.New(t)
+	cache := newProductCache(func(productIds []string) ([]Product, error) {
+		return nil, nil
+	}, time.Hour)
+	cache.MaxSize = 2
+	cache.add(Product{Id: "1"})
+	cache.add(Product{Id: "2"})
+	_, found := cache.get("1")
+	assert.True(found)
+	cache.add(Product{Id: "3"})
+
+	_, found = cache.get("2")
+	assert.False(found)
+	_, found = cache.get("1")
+	assert.True(found)
+	_, found = cache.get("3")
+	assert.True(found)
+	assert.Equal(uint64(1), cache.Stats().Evictions)
+	assert.Equal(2, cache.Stats().Size)
+}
+
+func TestProductCache_ConcurrentFetches(t *testing.T) {
+	assert := assert.New(t)
+	var fetches int32
+	release := make(chan struct{})
+	cache := newProductCache(func(productIds []string) ([]Product, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return []Product{{Id: "1", Name: "APPLE"}}, nil
+	}, time.Hour)
+
+	var wg sync.WaitGroup
+	results := make(chan Product, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			product, found := cache.GetProduct("1")
+			if found {
+				results <- product
+			}
+		}()
+	}
+	// let all the callers wait for the first fetch
+	for {
+		cache.callsMu.Lock()
+		_, inProgress := cache.calls["1"]
+		cache.callsMu.Unlock()
+		if inProgress {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	count := 0
+	for product := range results {
+		assert.Equal("APPLE", product.Name)
+		count++
+	}
+	assert.Equal(10, count)
+	assert.Equal(int32(1), atomic.LoadInt32(&fetches))
+}
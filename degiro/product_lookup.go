@@ -0,0 +1,137 @@
+package degiro
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProductCriteria describes the listings looked for by FindProduct. All the
+// non empty criteria must match exactly, Symbol and Currency being compared
+// case-insensitively.
+type ProductCriteria struct {
+	// SearchText is the text sent to the search endpoint. It defaults to Isin
+	// or, if empty, to Symbol.
+	SearchText  string
+	Isin        string
+	Symbol      string
+	ExchangeId  string
+	Currency    string
+	ProductType ProductType
+	// Limit is the maximum number of candidates fetched, 50 by default.
+	Limit int
+}
+
+// ProductListings are all the listings of a same ISIN.
+type ProductListings struct {
+	Isin     string
+	Listings []Product
+}
+
+func (c ProductCriteria) searchText() string {
+	switch {
+	case c.SearchText != "":
+		return c.SearchText
+	case c.Isin != "":
+		return c.Isin
+	default:
+		return c.Symbol
+	}
+}
+
+func (c ProductCriteria) Match(product Product) bool {
+	if c.Isin != "" && !strings.EqualFold(c.Isin, product.Isin) {
+		return false
+	}
+	if c.Symbol != "" && !strings.EqualFold(c.Symbol, product.Symbol) {
+		return false
+	}
+	if c.ExchangeId != "" && c.ExchangeId != product.ExchangeId {
+		return false
+	}
+	if c.Currency != "" && !strings.EqualFold(c.Currency, product.Currency) {
+		return false
+	}
+	if c.ProductType != 0 && int(c.ProductType) != product.ProductTypeId {
+		return false
+	}
+	return true
+}
+
+// FindProduct searches the listings matching criteria, grouped by ISIN. The
+// groups and the listings in each group are ranked, best first: tradable
+// listings come first, then lower product ids.
+func (c *Client) FindProduct(criteria ProductCriteria) ([]ProductListings, error) {
+	text := criteria.searchText()
+	if text == "" {
+		return nil, fmt.Errorf("no search text, isin or symbol in criteria")
+	}
+	limit := criteria.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	products, err := c.SearchProducts(SearchProductsOptions{
+		SearchText:  text,
+		Limit:       limit,
+		ProductType: criteria.ProductType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("searching products: %v", err)
+	}
+	return GroupProductListings(products, criteria), nil
+}
+
+// FindBestProduct returns the best ranked listing matching criteria.
+func (c *Client) FindBestProduct(criteria ProductCriteria) (Product, bool, error) {
+	groups, err := c.FindProduct(criteria)
+	if err != nil {
+		return Product{}, false, err
+	}
+	if len(groups) == 0 {
+		return Product{}, false, nil
+	}
+	return groups[0].Listings[0], true, nil
+}
+
+// GroupProductListings filters the products matching criteria and groups
+// them by ISIN, ranked as in FindProduct.
+func GroupProductListings(products []Product, criteria ProductCriteria) []ProductListings {
+	var candidates []Product
+	seen := make(map[string]bool)
+	for _, product := range products {
+		if seen[product.Id] || !criteria.Match(product) {
+			continue
+		}
+		seen[product.Id] = true
+		candidates = append(candidates, product)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return listingLess(candidates[i], candidates[j])
+	})
+
+	var res []ProductListings
+	groupIndexes := make(map[string]int)
+	for _, product := range candidates {
+		i, found := groupIndexes[product.Isin]
+		if !found {
+			i = len(res)
+			groupIndexes[product.Isin] = i
+			res = append(res, ProductListings{Isin: product.Isin})
+		}
+		res[i].Listings = append(res[i].Listings, product)
+	}
+	return res
+}
+
+func listingLess(a Product, b Product) bool {
+	if a.Tradable != b.Tradable {
+		return a.Tradable
+	}
+	idA, errA := strconv.ParseInt(a.Id, 10, 64)
+	idB, errB := strconv.ParseInt(b.Id, 10, 64)
+	if errA == nil && errB == nil && idA != idB {
+		return idA < idB
+	}
+	return a.Id < b.Id
+}
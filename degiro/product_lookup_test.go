@@ -0,0 +1,63 @@
+package degiro
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupProductListings(t *testing.T) {
+	assert := assert.New(t)
+	products := []Product{
+		{Id: "1157", Isin: "NL0000235190", Symbol: "AIR", ExchangeId: "200", Currency: "EUR", Tradable: true},
+		{Id: "20", Isin: "NL0000235190", Symbol: "AIR", ExchangeId: "194", Currency: "EUR", Tradable: false},
+		{Id: "9", Isin: "NL0000235190", Symbol: "EADSY", ExchangeId: "676", Currency: "USD", Tradable: true},
+		{Id: "30", Isin: "US0000000001", Symbol: "AIR", ExchangeId: "663", Currency: "USD", Tradable: true},
+		{Id: "1157", Isin: "NL0000235190", Symbol: "AIR", ExchangeId: "200", Currency: "EUR", Tradable: true},
+	}
+
+	groups := GroupProductListings(products, ProductCriteria{Symbol: "air"})
+	if assert.Equal(2, len(groups)) {
+		assert.Equal("US0000000001", groups[0].Isin)
+		assert.Equal("NL0000235190", groups[1].Isin)
+		if assert.Equal(2, len(groups[1].Listings)) {
+			assert.Equal("1157", groups[1].Listings[0].Id)
+			assert.Equal("20", groups[1].Listings[1].Id)
+		}
+	}
+
+	groups = GroupProductListings(products, ProductCriteria{Isin: "NL0000235190", Currency: "usd"})
+	if assert.Equal(1, len(groups)) && assert.Equal(1, len(groups[0].Listings)) {
+		assert.Equal("9", groups[0].Listings[0].Id)
+	}
+
+	groups = GroupProductListings(products, ProductCriteria{Isin: "NL0000235190", ExchangeId: "999"})
+	assert.Equal(0, len(groups))
+}
+
+func TestFindBestProduct(t *testing.T) {
+	assert := assert.New(t)
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal("/product_search/secure/v5/products/lookup", req.URL.Path)
+		assert.Equal("NL0000235190", req.URL.Query().Get("searchText"))
+		assert.Equal("50", req.URL.Query().Get("limit"))
+		return &http.Response{
+			StatusCode: 200,
+			Body: ioutil.NopCloser(bytes.NewBufferString(`{"offset":0,"products":[` +
+				`{"id":"20","isin":"NL0000235190","symbol":"AIR","exchangeId":"194","currency":"EUR","tradable":true},` +
+				`{"id":"9","isin":"NL0000235190","symbol":"EADSY","exchangeId":"676","currency":"USD","tradable":true}]}`)),
+			Header: getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	product, found, err := degiro.FindBestProduct(ProductCriteria{Isin: "NL0000235190", Currency: "USD"})
+	assert.Nil(err)
+	assert.True(found)
+	assert.Equal("9", product.Id)
+
+	_, err = degiro.FindProduct(ProductCriteria{})
+	assert.NotNil(err)
+}
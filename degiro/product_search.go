@@ -0,0 +1,268 @@
+package degiro
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dghubble/sling"
+	"github.com/shopspring/decimal"
+)
+
+// SearchPaging holds the paging and sorting parameters shared by the type
+// specific product searches. SortColumns and SortTypes are comma separated
+// lists, e.g. "name,volume" and "asc,desc".
+type SearchPaging struct {
+	Offset      int    `url:"offset"`
+	Limit       int    `url:"limit,omitempty"`
+	SortColumns string `url:"sortColumns,omitempty"`
+	SortTypes   string `url:"sortTypes,omitempty"`
+}
+
+type StockSearchOptions struct {
+	SearchPaging
+	SearchText      string `url:"searchText,omitempty"`
+	ExchangeId      int    `url:"exchangeId,omitempty"`
+	StockCountryId  int    `url:"stockCountryId,omitempty"`
+	IndexId         int    `url:"indexId,omitempty"`
+	IsInUSGreenList bool   `url:"isInUSGreenList,omitempty"`
+}
+
+type EtfSearchOptions struct {
+	SearchPaging
+	SearchText           string `url:"searchText,omitempty"`
+	ExchangeId           int    `url:"exchangeId,omitempty"`
+	PopularOnly          bool   `url:"popularOnly,omitempty"`
+	FreeOnly             bool   `url:"freeEtfOnly,omitempty"`
+	InputAggregateTypes  string `url:"inputAggregateTypes,omitempty"`
+	InputAggregateValues string `url:"inputAggregateValues,omitempty"`
+}
+
+type BondSearchOptions struct {
+	SearchPaging
+	SearchText       string `url:"searchText,omitempty"`
+	BondExchangeId   int    `url:"bondExchangeId,omitempty"`
+	BondIssuerTypeId int    `url:"bondIssuerTypeId,omitempty"`
+}
+
+type OptionSearchOptions struct {
+	SearchPaging
+	SearchText           string `url:"searchText,omitempty"`
+	OptionExchangeId     int    `url:"optionExchangeId,omitempty"`
+	UnderlyingIsin       string `url:"underlyingIsin,omitempty"`
+	InputAggregateTypes  string `url:"inputAggregateTypes,omitempty"`
+	InputAggregateValues string `url:"inputAggregateValues,omitempty"`
+}
+
+type FutureSearchOptions struct {
+	SearchPaging
+	SearchText       string `url:"searchText,omitempty"`
+	FutureExchangeId int    `url:"futureExchangeId,omitempty"`
+	UnderlyingIsin   string `url:"underlyingIsin,omitempty"`
+}
+
+type LeveragedSearchOptions struct {
+	SearchPaging
+	SearchText           string `url:"searchText,omitempty"`
+	PopularOnly          bool   `url:"popularOnly,omitempty"`
+	IssuerId             int    `url:"issuerId,omitempty"`
+	UnderlyingProductId  int    `url:"underlyingProductId,omitempty"`
+	ShortLong            int    `url:"shortLong,omitempty"`
+	InputAggregateTypes  string `url:"inputAggregateTypes,omitempty"`
+	InputAggregateValues string `url:"inputAggregateValues,omitempty"`
+}
+
+type StockProduct struct {
+	Product
+}
+
+type EtfProduct struct {
+	Product
+	TotalExpenseRatio decimal.Decimal `json:"totalExpenseRatio"`
+	FeeFree           bool            `json:"feeFree"`
+}
+
+type BondProduct struct {
+	Product
+	Coupon       decimal.Decimal `json:"coupon"`
+	MaturityDate productTime     `json:"maturityDate"`
+}
+
+type OptionProduct struct {
+	Product
+	PutCall             string `json:"putCall"`
+	UnderlyingIsin      string `json:"underlyingIsin"`
+	UnderlyingProductId int    `json:"underlyingProductId"`
+}
+
+// optionNameRight matches the right in option names such as "AEX C500.00 18OCT19"
+var optionNameRight = regexp.MustCompile(`\s([CP])\d`)
+
+func (p OptionProduct) right() string {
+	switch strings.ToUpper(p.PutCall) {
+	case "C", "CALL":
+		return "C"
+	case "P", "PUT":
+		return "P"
+	}
+	if match := optionNameRight.FindStringSubmatch(p.Name); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+func (p OptionProduct) IsCall() bool {
+	return p.right() == "C"
+}
+
+func (p OptionProduct) IsPut() bool {
+	return p.right() == "P"
+}
+
+type FutureProduct struct {
+	Product
+	UnderlyingIsin      string `json:"underlyingIsin"`
+	UnderlyingProductId int    `json:"underlyingProductId"`
+}
+
+type LeveragedProduct struct {
+	Product
+	IssuerId            int    `json:"issuerId"`
+	IssuerName          string `json:"issuerName"`
+	UnderlyingProductId int    `json:"underlyingProductId"`
+}
+
+// searchPaging gives access to the embedded SearchPaging of the search
+// options, so that a single iterator can walk through any type of search.
+func (p *SearchPaging) searchPaging() *SearchPaging {
+	return p
+}
+
+type searchOptions interface {
+	searchPaging() *SearchPaging
+}
+
+// searchProductPage fetches one page of a product search, decoding the
+// products into the slice pointed to by products.
+func (c *Client) searchProductPage(path string, options interface{}, products interface{}) (int, error) {
+	type searchProductPageResponse struct {
+		Offset   int         `json:"offset"`
+		Total    int         `json:"total"`
+		Products interface{} `json:"products"`
+	}
+	response := &searchProductPageResponse{Products: products}
+	_, err := c.receiveSuccessReloginOn401(func() *sling.Sling {
+		return c.sling.New().
+			Get(fmt.Sprintf("product_search/secure/v5/%s", path)).
+			QueryStruct(&struct {
+				AccountId    int64  `url:"intAccount"`
+				SessionId    string `url:"sessionId"`
+				RequireTotal bool   `url:"requireTotal"`
+			}{
+				AccountId:    c.accountId,
+				SessionId:    c.session(),
+				RequireTotal: true,
+			}).
+			QueryStruct(options)
+	}, response)
+	if err != nil {
+		return 0, err
+	}
+	return response.Total, nil
+}
+
+func (c *Client) iterateProductSearch(path string, options searchOptions) *SearchIterator {
+	paging := options.searchPaging()
+	return newSearchIterator(*paging, func(next SearchPaging) ([]json.RawMessage, int, error) {
+		*paging = next
+		var page []json.RawMessage
+		total, err := c.searchProductPage(path, options, &page)
+		return page, total, err
+	})
+}
+
+// SearchStocks returns one page of stocks and the total number of results.
+func (c *Client) SearchStocks(options StockSearchOptions) ([]StockProduct, int, error) {
+	var res []StockProduct
+	total, err := c.searchProductPage("stocks", options, &res)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+func (c *Client) IterateStocks(options StockSearchOptions) *SearchIterator {
+	return c.iterateProductSearch("stocks", &options)
+}
+
+// SearchEtfs returns one page of ETFs and the total number of results.
+func (c *Client) SearchEtfs(options EtfSearchOptions) ([]EtfProduct, int, error) {
+	var res []EtfProduct
+	total, err := c.searchProductPage("etfs", options, &res)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+func (c *Client) IterateEtfs(options EtfSearchOptions) *SearchIterator {
+	return c.iterateProductSearch("etfs", &options)
+}
+
+// SearchBonds returns one page of bonds and the total number of results.
+func (c *Client) SearchBonds(options BondSearchOptions) ([]BondProduct, int, error) {
+	var res []BondProduct
+	total, err := c.searchProductPage("bonds", options, &res)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+func (c *Client) IterateBonds(options BondSearchOptions) *SearchIterator {
+	return c.iterateProductSearch("bonds", &options)
+}
+
+// SearchOptions returns one page of options and the total number of results.
+func (c *Client) SearchOptions(options OptionSearchOptions) ([]OptionProduct, int, error) {
+	var res []OptionProduct
+	total, err := c.searchProductPage("options", options, &res)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+func (c *Client) IterateOptions(options OptionSearchOptions) *SearchIterator {
+	return c.iterateProductSearch("options", &options)
+}
+
+// SearchFutures returns one page of futures and the total number of results.
+func (c *Client) SearchFutures(options FutureSearchOptions) ([]FutureProduct, int, error) {
+	var res []FutureProduct
+	total, err := c.searchProductPage("futures", options, &res)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+func (c *Client) IterateFutures(options FutureSearchOptions) *SearchIterator {
+	return c.iterateProductSearch("futures", &options)
+}
+
+// SearchLeverageds returns one page of leveraged products and the total
+// number of results.
+func (c *Client) SearchLeverageds(options LeveragedSearchOptions) ([]LeveragedProduct, int, error) {
+	var res []LeveragedProduct
+	total, err := c.searchProductPage("leverageds", options, &res)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+func (c *Client) IterateLeverageds(options LeveragedSearchOptions) *SearchIterator {
+	return c.iterateProductSearch("leverageds", &options)
+}
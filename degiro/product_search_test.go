@@ -0,0 +1,141 @@
+package degiro
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchEtfs(t *testing.T) {
+	assert := assert.New(t)
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal("/product_search/secure/v5/etfs", req.URL.Path)
+		query := req.URL.Query()
+		assert.Equal("MSCI", query.Get("searchText"))
+		assert.Equal("true", query.Get("freeEtfOnly"))
+		assert.Equal("true", query.Get("requireTotal"))
+		assert.Equal("0", query.Get("offset"))
+		assert.Equal("10", query.Get("limit"))
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"offset":0,"total":1,"products":[{"id":"4586985","name":"ISHARES MSCI WORLD","isin":"IE00B4L5Y983","totalExpenseRatio":0.2,"feeFree":true}]}`)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	etfs, total, err := degiro.SearchEtfs(EtfSearchOptions{
+		SearchPaging: SearchPaging{Limit: 10},
+		SearchText:   "MSCI",
+		FreeOnly:     true,
+	})
+	assert.Nil(err)
+	assert.Equal(1, total)
+	if assert.Equal(1, len(etfs)) {
+		assert.Equal("4586985", etfs[0].Id)
+		assert.Equal("IE00B4L5Y983", etfs[0].Isin)
+		assert.True(etfs[0].FeeFree)
+		assert.True(decimal.NewFromFloat(0.2).Equal(etfs[0].TotalExpenseRatio))
+	}
+}
+
+func TestSearchIterator(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		requests++
+		assert.Equal("/product_search/secure/v5/stocks", req.URL.Path)
+		offset, _ := strconv.Atoi(req.URL.Query().Get("offset"))
+		assert.Equal("2", req.URL.Query().Get("limit"))
+		var products []string
+		for i := offset; i < offset+2 && i < 5; i++ {
+			products = append(products, fmt.Sprintf(`{"id":"%d"}`, i))
+		}
+		body := fmt.Sprintf(`{"offset":%d,"total":5,"products":[%s]}`, offset, strings.Join(products, ","))
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	it := degiro.IterateStocks(StockSearchOptions{SearchPaging: SearchPaging{Limit: 2}})
+	it.RequestInterval = 0
+	var ids []string
+	for it.Next() {
+		var stock StockProduct
+		assert.Nil(it.Scan(&stock))
+		ids = append(ids, stock.Id)
+	}
+	assert.Nil(it.Err())
+	assert.Equal([]string{"0", "1", "2", "3", "4"}, ids)
+	assert.Equal(5, it.Total())
+	assert.Equal(3, requests)
+}
+
+func TestSearchIterator_OffsetIgnored(t *testing.T) {
+	assert := assert.New(t)
+	requests := 0
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		requests++
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"products":[{"id":"1"},{"id":"2"}]}`)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	it := degiro.IterateStocks(StockSearchOptions{SearchPaging: SearchPaging{Limit: 2}})
+	it.RequestInterval = 0
+	results := 0
+	for it.Next() {
+		results++
+	}
+	assert.Nil(it.Err())
+	assert.Equal(2, results)
+	assert.Equal(2, requests)
+}
+
+func TestIterateProducts(t *testing.T) {
+	assert := assert.New(t)
+	var offsets []string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		assert.Equal("/product_search/secure/v5/products/lookup", req.URL.Path)
+		assert.Equal("APPLE", req.URL.Query().Get("searchText"))
+		offsets = append(offsets, req.URL.Query().Get("offset"))
+		// the second page overlaps the first one
+		body := `{"offset":0,"products":[{"id":"1","closePriceDate":"2019-10-10"},{"id":"2"},{"id":"3"}]}`
+		if req.URL.Query().Get("offset") != "0" {
+			body = `{"offset":3,"products":[{"id":"3"},{"id":"4"},{"id":"5"}]}`
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	it := degiro.IterateProducts(SearchProductsOptions{SearchText: "APPLE", Limit: 3})
+	it.MaxResults = 4
+	var waits []time.Duration
+	it.sleep = func(d time.Duration) {
+		waits = append(waits, d)
+	}
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Product().Id)
+	}
+	assert.Nil(it.Err())
+	assert.Equal([]string{"1", "2", "3", "4"}, ids)
+	assert.Equal([]string{"0", "3"}, offsets)
+	if assert.Equal(1, len(waits)) {
+		assert.True(waits[0] > 0 && waits[0] <= defaultSearchRequestInterval)
+	}
+}
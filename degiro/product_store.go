@@ -0,0 +1,114 @@
+package degiro
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ProductStore persists product infos between restarts. Put receives the
+// products each time they are fetched from the server.
+type ProductStore interface {
+	Load() ([]ProductCacheItem, error)
+	Put(items []ProductCacheItem) error
+}
+
+type FileFormat int
+
+const (
+	JSONFormat FileFormat = iota
+	GobFormat
+)
+
+// FileProductStore is a ProductStore keeping all the products in a single
+// file, rewritten on each Put.
+type FileProductStore struct {
+	path   string
+	format FileFormat
+
+	mu    sync.Mutex
+	items map[string]ProductCacheItem
+}
+
+func NewFileProductStore(path string, format FileFormat) *FileProductStore {
+	return &FileProductStore{
+		path:   path,
+		format: format,
+	}
+}
+
+func (s *FileProductStore) Load() ([]ProductCacheItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	var res []ProductCacheItem
+	for _, item := range s.items {
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+func (s *FileProductStore) load() error {
+	if s.items != nil {
+		return nil
+	}
+	s.items = make(map[string]ProductCacheItem)
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("opening product store: %v", err)
+	}
+	defer f.Close()
+	var items []ProductCacheItem
+	switch s.format {
+	case GobFormat:
+		err = gob.NewDecoder(f).Decode(&items)
+	default:
+		err = json.NewDecoder(f).Decode(&items)
+	}
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("decoding product store: %v", err)
+	}
+	for _, item := range items {
+		s.items[item.Product.Id] = item
+	}
+	return nil
+}
+
+func (s *FileProductStore) Put(items []ProductCacheItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	for _, item := range items {
+		s.items[item.Product.Id] = item
+	}
+	return s.write()
+}
+
+func (s *FileProductStore) write() error {
+	var items []ProductCacheItem
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	err := writeFileAtomic(s.path, func(w io.Writer) error {
+		switch s.format {
+		case GobFormat:
+			return gob.NewEncoder(w).Encode(items)
+		default:
+			return json.NewEncoder(w).Encode(items)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("writing product store: %v", err)
+	}
+	return nil
+}
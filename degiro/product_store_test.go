@@ -0,0 +1,76 @@
+package degiro
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileProductStore(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "degiro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, format := range []FileFormat{JSONFormat, GobFormat} {
+		path := filepath.Join(dir, "products")
+		os.Remove(path)
+		lastUpdate := time.Date(2019, 10, 10, 12, 0, 0, 0, time.UTC)
+		product := Product{
+			Id:             "331868",
+			Name:           "APPLE INC",
+			ClosePrice:     decimal.NewFromFloat(230.09),
+			ClosePriceDate: productTime{time.Date(2019, 10, 9, 0, 0, 0, 0, time.UTC)},
+		}
+
+		store := NewFileProductStore(path, format)
+		items, err := store.Load()
+		assert.Nil(err)
+		assert.Equal(0, len(items))
+		assert.Nil(store.Put([]ProductCacheItem{{Product: product, LastUpdate: lastUpdate}}))
+
+		items, err = NewFileProductStore(path, format).Load()
+		assert.Nil(err)
+		if assert.Equal(1, len(items), "format %d", format) {
+			assert.Equal("APPLE INC", items[0].Product.Name)
+			assert.True(product.ClosePrice.Equal(items[0].Product.ClosePrice))
+			assert.True(product.ClosePriceDate.Equal(items[0].Product.ClosePriceDate.Time))
+			assert.True(lastUpdate.Equal(items[0].LastUpdate))
+		}
+	}
+}
+
+func TestProductCache_SetStore(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "degiro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "products.json")
+	assert.Nil(NewFileProductStore(path, JSONFormat).Put([]ProductCacheItem{
+		{Product: Product{Id: "1", Name: "STORED"}, LastUpdate: time.Now()},
+	}))
+
+	var fetched []string
+	cache := newProductCache(func(productIds []string) ([]Product, error) {
+		fetched = append(fetched, productIds...)
+		return []Product{{Id: "2", Name: "FETCHED"}}, nil
+	}, time.Hour)
+	assert.Nil(cache.SetStore(NewFileProductStore(path, JSONFormat)))
+
+	products := cache.GetProducts([]string{"1", "2"})
+	assert.Equal(2, len(products))
+	assert.Equal([]string{"2"}, fetched)
+
+	items, err := NewFileProductStore(path, JSONFormat).Load()
+	assert.Nil(err)
+	assert.Equal(2, len(items))
+}
@@ -0,0 +1,293 @@
+package degiro
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type RiskRule string
+
+const (
+	RuleOrderNotional RiskRule = "maxOrderNotional"
+	RulePositionSize  RiskRule = "maxPositionSize"
+	RuleDailyLoss     RiskRule = "dailyLossLimit"
+	RuleOpenOrders    RiskRule = "maxOpenOrders"
+	RuleProductType   RiskRule = "allowedProductTypes"
+	RuleBlackout      RiskRule = "blackoutWindows"
+)
+
+// RiskRejection is returned when an order breaks a rule of the RiskPolicy.
+// No request is sent for a rejected order.
+type RiskRejection struct {
+	Rule   RiskRule
+	Reason string
+}
+
+func (r *RiskRejection) Error() string {
+	return fmt.Sprintf("order rejected by risk policy (%s): %s", r.Rule, r.Reason)
+}
+
+// BlackoutWindow is a daily period during which no order is placed, Start
+// and End being durations since midnight. A window whose End is before its
+// Start crosses midnight, Weekdays being the days it starts. An empty
+// Weekdays means every day.
+type BlackoutWindow struct {
+	Weekdays []time.Weekday
+	Start    time.Duration
+	End      time.Duration
+}
+
+func (w BlackoutWindow) Contains(t time.Time) bool {
+	sinceMidnight := clock(t.Hour(), t.Minute()) + time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+	switch {
+	case w.Start <= w.End:
+		if sinceMidnight < w.Start || sinceMidnight >= w.End {
+			return false
+		}
+	case sinceMidnight >= w.Start:
+	case sinceMidnight < w.End:
+		// the window started the day before
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, weekday := range w.Weekdays {
+		if weekday == day {
+			return true
+		}
+	}
+	return false
+}
+
+// RiskContext is the state an order is checked against.
+type RiskContext struct {
+	Product Product
+	// Price is the reference price of the product, used for the notional of
+	// market orders.
+	Price        decimal.Decimal
+	Balance      Balance
+	PositionSize int
+	// PendingBuys and PendingSells are the sizes of the pending buy and sell
+	// orders on the product, which may add to the position.
+	PendingBuys  int
+	PendingSells int
+	OpenOrders   int
+	Now          time.Time
+}
+
+// RiskPolicy are the limits checked before an order is sent, the zero value
+// of a limit disabling it. Orders reducing a position without reversing it,
+// such as stop losses, are only checked against AllowedProductTypes.
+type RiskPolicy struct {
+	// MaxOrderNotional is the maximum value of an order, in the currency of
+	// the product.
+	MaxOrderNotional decimal.Decimal
+	// MaxPositionSize is the maximum absolute size of a position, by product
+	// id in MaxPositionSizes or for all products. The pending orders in the
+	// direction of an order are counted as filled.
+	MaxPositionSize  int
+	MaxPositionSizes map[string]int
+	// DailyLossLimit is the maximum decrease of the net liquidation value of
+	// the account since the first balance seen of the day, given to
+	// ObserveBalance or to Check. Orders are rejected while no balance is
+	// known for the day.
+	DailyLossLimit      decimal.Decimal
+	MaxOpenOrders       int
+	AllowedProductTypes []ProductType
+	BlackoutWindows     []BlackoutWindow
+	// Location is used for the blackout windows and the day boundaries, local
+	// time by default.
+	Location *time.Location
+
+	mu            sync.Mutex
+	day           string
+	dayStartValue decimal.Decimal
+}
+
+// orderNotional returns the value of input, false if it is unknown
+func orderNotional(input PlaceOrderInput, price decimal.Decimal) (decimal.Decimal, bool) {
+	if input.OrderType == StandardAmount {
+		return input.Amount, true
+	}
+	switch {
+	case !input.Price.IsZero():
+		price = input.Price
+	case !input.StopPrice.IsZero():
+		price = input.StopPrice
+	}
+	if !price.IsPositive() {
+		return decimal.Zero, false
+	}
+	return price.Mul(decimal.New(int64(input.Quantity), 0)), true
+}
+
+// orderQuantity returns the number of shares of input, amount orders being
+// converted with the reference price, false if it is unknown
+func orderQuantity(input PlaceOrderInput, price decimal.Decimal) (int, bool) {
+	if input.OrderType != StandardAmount {
+		return input.Quantity, true
+	}
+	if !price.IsPositive() {
+		return 0, false
+	}
+	return int(input.Amount.Div(price).IntPart()), true
+}
+
+// reducesPosition returns whether input decreases the size of a position
+// without reversing it, once the pending orders on the same side are filled
+func reducesPosition(input PlaceOrderInput, ctx RiskContext) bool {
+	quantity, known := orderQuantity(input, ctx.Price)
+	if !known || quantity <= 0 {
+		return false
+	}
+	if input.BuySell == Sell {
+		return ctx.PositionSize > 0 && ctx.PendingSells+quantity <= ctx.PositionSize
+	}
+	return ctx.PositionSize < 0 && ctx.PendingBuys+quantity <= -ctx.PositionSize
+}
+
+func (p *RiskPolicy) now(t time.Time) time.Time {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	if p.Location != nil {
+		t = t.In(p.Location)
+	}
+	return t
+}
+
+// ObserveBalance records the balance of the account, the first one with a
+// net liquidation value of each day being the base of DailyLossLimit. It
+// should be called on each balance update, as Client does, so that losses
+// made before the first order of the day are counted.
+func (p *RiskPolicy) ObserveBalance(balance Balance, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.observeBalance(balance, p.now(now))
+}
+
+func (p *RiskPolicy) observeBalance(balance Balance, now time.Time) {
+	day := now.Format("2006-01-02")
+	if p.day != day {
+		p.day = day
+		p.dayStartValue = decimal.Zero
+	}
+	if p.dayStartValue.IsZero() && !balance.ReportNetliq.IsZero() {
+		p.dayStartValue = balance.ReportNetliq
+	}
+}
+
+// Check returns a *RiskRejection if input breaks one of the limits.
+func (p *RiskPolicy) Check(input PlaceOrderInput, ctx RiskContext) error {
+	now := p.now(ctx.Now)
+	reducing := reducesPosition(input, ctx)
+
+	for _, window := range p.BlackoutWindows {
+		if !reducing && window.Contains(now) {
+			return &RiskRejection{Rule: RuleBlackout, Reason: fmt.Sprintf("%s is in a blackout window", now.Format("Mon 15:04"))}
+		}
+	}
+
+	if len(p.AllowedProductTypes) > 0 {
+		allowed := false
+		for _, productType := range p.AllowedProductTypes {
+			if int(productType) == ctx.Product.ProductTypeId {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &RiskRejection{Rule: RuleProductType, Reason: fmt.Sprintf("product type %d is not allowed", ctx.Product.ProductTypeId)}
+		}
+	}
+
+	if reducing {
+		return nil
+	}
+
+	if p.MaxOpenOrders > 0 && ctx.OpenOrders >= p.MaxOpenOrders {
+		return &RiskRejection{Rule: RuleOpenOrders, Reason: fmt.Sprintf("%d orders already open", ctx.OpenOrders)}
+	}
+
+	if p.MaxOrderNotional.IsPositive() {
+		notional, known := orderNotional(input, ctx.Price)
+		if !known {
+			return &RiskRejection{Rule: RuleOrderNotional, Reason: "no reference price for the order value"}
+		}
+		if notional.GreaterThan(p.MaxOrderNotional) {
+			return &RiskRejection{Rule: RuleOrderNotional, Reason: fmt.Sprintf("order value %s is above %s", notional, p.MaxOrderNotional)}
+		}
+	}
+
+	maxSize := p.MaxPositionSize
+	if size, found := p.MaxPositionSizes[input.ProductId]; found {
+		maxSize = size
+	}
+	if maxSize > 0 {
+		quantity, known := orderQuantity(input, ctx.Price)
+		if !known {
+			return &RiskRejection{Rule: RulePositionSize, Reason: "no reference price for the order size"}
+		}
+		size := ctx.PositionSize
+		if input.BuySell == Buy {
+			size += ctx.PendingBuys + quantity
+		} else {
+			size -= ctx.PendingSells + quantity
+		}
+		if abs(size) > maxSize && abs(size) > abs(ctx.PositionSize) {
+			return &RiskRejection{Rule: RulePositionSize, Reason: fmt.Sprintf("position size %d is above %d", size, maxSize)}
+		}
+	}
+
+	if p.DailyLossLimit.IsPositive() {
+		p.mu.Lock()
+		p.observeBalance(ctx.Balance, now)
+		start := p.dayStartValue
+		p.mu.Unlock()
+		if start.IsZero() || ctx.Balance.ReportNetliq.IsZero() {
+			return &RiskRejection{Rule: RuleDailyLoss, Reason: "no balance known for the day"}
+		}
+		loss := start.Sub(ctx.Balance.ReportNetliq)
+		if loss.GreaterThanOrEqual(p.DailyLossLimit) {
+			return &RiskRejection{Rule: RuleDailyLoss, Reason: fmt.Sprintf("daily loss %s reached the limit %s", loss, p.DailyLossLimit)}
+		}
+	}
+	return nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func (c *Client) riskContext(product Product) RiskContext {
+	position, _ := c.GetOpenedPositionForProduct(product.Id)
+	ctx := RiskContext{
+		Product:      product,
+		Price:        c.GetQuote(product.VwdId).LastPrice,
+		Balance:      c.GetBalance(),
+		PositionSize: position.Size,
+		OpenOrders:   len(c.GetAllPendingOrders()),
+		Now:          time.Now(),
+	}
+	if productId, err := strconv.Atoi(product.Id); err == nil {
+		for _, order := range c.GetPendingOrders(productId) {
+			if order.BuySell == Buy {
+				ctx.PendingBuys += order.Size
+			} else {
+				ctx.PendingSells += order.Size
+			}
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,186 @@
+package degiro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func riskRule(err error) RiskRule {
+	if rejection, ok := err.(*RiskRejection); ok {
+		return rejection.Rule
+	}
+	return ""
+}
+
+func TestRiskPolicy_Check(t *testing.T) {
+	assert := assert.New(t)
+	monday := time.Date(2019, 10, 14, 10, 0, 0, 0, time.UTC)
+	policy := &RiskPolicy{
+		MaxOrderNotional:    decimal.New(1000, 0),
+		MaxPositionSize:     100,
+		MaxPositionSizes:    map[string]int{"2": 10},
+		DailyLossLimit:      decimal.New(500, 0),
+		MaxOpenOrders:       3,
+		AllowedProductTypes: []ProductType{Stock, Etf},
+		BlackoutWindows:     []BlackoutWindow{{Weekdays: []time.Weekday{time.Friday}, Start: clock(17, 0), End: clock(17, 30)}},
+		Location:            time.UTC,
+	}
+	ctx := RiskContext{
+		Product: Product{Id: "1", ProductTypeId: int(Stock)},
+		Price:   decimal.New(10, 0),
+		Balance: Balance{ReportNetliq: decimal.New(10000, 0)},
+		Now:     monday,
+	}
+	buy := PlaceOrderInput{BuySell: Buy, OrderType: MarketOrder, ProductId: "1", Quantity: 50, TimeType: Day}
+	assert.Nil(policy.Check(buy, ctx))
+
+	input := buy
+	input.OrderType = Limited
+	input.Price = decimal.New(25, 0)
+	assert.Equal(RuleOrderNotional, riskRule(policy.Check(input, ctx)))
+	noPrice := ctx
+	noPrice.Price = decimal.Zero
+	assert.Equal(RuleOrderNotional, riskRule(policy.Check(buy, noPrice)))
+
+	position := ctx
+	position.PositionSize = 60
+	assert.Equal(RulePositionSize, riskRule(policy.Check(buy, position)))
+	position.PositionSize = 150
+	sell := buy
+	sell.BuySell = Sell
+	assert.Nil(policy.Check(sell, position))
+	input = buy
+	input.ProductId = "2"
+	input.Quantity = 11
+	assert.Equal(RulePositionSize, riskRule(policy.Check(input, ctx)))
+	// working buys count towards the position, working sells do not offset
+	// them
+	pending := ctx
+	pending.PendingBuys = 60
+	pending.PendingSells = 100
+	assert.Equal(RulePositionSize, riskRule(policy.Check(buy, pending)))
+	pending.PendingBuys = 50
+	assert.Nil(policy.Check(buy, pending))
+
+	orders := ctx
+	orders.OpenOrders = 3
+	assert.Equal(RuleOpenOrders, riskRule(policy.Check(buy, orders)))
+
+	bond := ctx
+	bond.Product.ProductTypeId = int(Bond)
+	assert.Equal(RuleProductType, riskRule(policy.Check(buy, bond)))
+
+	friday := ctx
+	friday.Now = time.Date(2019, 10, 18, 17, 10, 0, 0, time.UTC)
+	assert.Equal(RuleBlackout, riskRule(policy.Check(buy, friday)))
+
+	loss := ctx
+	loss.Balance.ReportNetliq = decimal.New(9500, 0)
+	assert.Equal(RuleDailyLoss, riskRule(policy.Check(buy, loss)))
+	// a new day starts from the current value
+	loss.Now = monday.Add(24 * time.Hour)
+	assert.Nil(policy.Check(buy, loss))
+}
+
+func TestRiskPolicy_ReducingOrders(t *testing.T) {
+	assert := assert.New(t)
+	policy := &RiskPolicy{
+		MaxOrderNotional: decimal.New(100, 0),
+		DailyLossLimit:   decimal.New(500, 0),
+		MaxOpenOrders:    1,
+		BlackoutWindows:  []BlackoutWindow{{Start: clock(0, 0), End: clock(24, 0)}},
+		Location:         time.UTC,
+	}
+	ctx := RiskContext{
+		Product:      Product{Id: "1"},
+		Price:        decimal.New(10, 0),
+		Balance:      Balance{ReportNetliq: decimal.New(9000, 0)},
+		PositionSize: 50,
+		OpenOrders:   1,
+		Now:          time.Date(2019, 10, 14, 10, 0, 0, 0, time.UTC),
+	}
+	policy.ObserveBalance(Balance{ReportNetliq: decimal.New(10000, 0)}, ctx.Now.Add(-time.Hour))
+
+	stopLoss := PlaceOrderInput{BuySell: Sell, OrderType: StopLoss, ProductId: "1", Quantity: 50, TimeType: Day, StopPrice: decimal.New(9, 0)}
+	assert.Nil(policy.Check(stopLoss, ctx))
+	amount := PlaceOrderInput{BuySell: Sell, OrderType: StandardAmount, ProductId: "1", TimeType: Day, Amount: decimal.New(200, 0)}
+	assert.Nil(policy.Check(amount, ctx))
+
+	// reversing the position is not reducing it
+	reverse := stopLoss
+	reverse.Quantity = 60
+	assert.Equal(RuleBlackout, riskRule(policy.Check(reverse, ctx)))
+
+	// a second stop on top of a pending one would reverse the position
+	ctx.PendingSells = 50
+	assert.Equal(RuleBlackout, riskRule(policy.Check(stopLoss, ctx)))
+	partial := stopLoss
+	partial.Quantity = 20
+	ctx.PendingSells = 30
+	assert.Nil(policy.Check(partial, ctx))
+	ctx.PendingSells = 0
+
+	policy.BlackoutWindows = nil
+	ctx.OpenOrders = 0
+	reverse.Quantity = 51
+	reverse.StopPrice = decimal.New(1, 0)
+	assert.Equal(RuleDailyLoss, riskRule(policy.Check(reverse, ctx)))
+}
+
+func TestRiskPolicy_AmountPositionSize(t *testing.T) {
+	assert := assert.New(t)
+	policy := &RiskPolicy{MaxPositionSize: 10}
+	ctx := RiskContext{Product: Product{Id: "1"}, Price: decimal.New(10, 0)}
+	amount := PlaceOrderInput{BuySell: Buy, OrderType: StandardAmount, ProductId: "1", TimeType: Day, Amount: decimal.New(100, 0)}
+	assert.Nil(policy.Check(amount, ctx))
+	amount.Amount = decimal.New(110, 0)
+	assert.Equal(RulePositionSize, riskRule(policy.Check(amount, ctx)))
+	ctx.Price = decimal.Zero
+	assert.Equal(RulePositionSize, riskRule(policy.Check(amount, ctx)))
+}
+
+func TestRiskPolicy_DailyLossBaseline(t *testing.T) {
+	assert := assert.New(t)
+	policy := &RiskPolicy{DailyLossLimit: decimal.New(500, 0), Location: time.UTC}
+	morning := time.Date(2019, 10, 14, 9, 0, 0, 0, time.UTC)
+	buy := PlaceOrderInput{BuySell: Buy, OrderType: Limited, ProductId: "1", Quantity: 1, TimeType: Day, Price: decimal.New(10, 0)}
+
+	// no balance known yet
+	assert.Equal(RuleDailyLoss, riskRule(policy.Check(buy, RiskContext{Now: morning})))
+
+	// the loss made before the first order is counted
+	policy.ObserveBalance(Balance{}, morning)
+	policy.ObserveBalance(Balance{ReportNetliq: decimal.New(10000, 0)}, morning)
+	policy.ObserveBalance(Balance{ReportNetliq: decimal.New(9800, 0)}, morning.Add(time.Hour))
+	ctx := RiskContext{Balance: Balance{ReportNetliq: decimal.New(9400, 0)}, Now: morning.Add(2 * time.Hour)}
+	assert.Equal(RuleDailyLoss, riskRule(policy.Check(buy, ctx)))
+}
+
+func TestBlackoutWindow_Contains(t *testing.T) {
+	assert := assert.New(t)
+	at := func(day int, h, m int) time.Time {
+		// 2019-10-14 is a Monday
+		return time.Date(2019, 10, 14+day, h, m, 0, 0, time.UTC)
+	}
+	window := BlackoutWindow{Start: clock(9, 0), End: clock(9, 30)}
+	assert.True(window.Contains(at(0, 9, 0)))
+	assert.False(window.Contains(at(0, 9, 30)))
+
+	// from Friday 22:00 to Saturday 06:00
+	overnight := BlackoutWindow{Weekdays: []time.Weekday{time.Friday}, Start: clock(22, 0), End: clock(6, 0)}
+	assert.True(overnight.Contains(at(4, 22, 0)))
+	assert.True(overnight.Contains(at(5, 5, 59)))
+	assert.False(overnight.Contains(at(5, 6, 0)))
+	assert.False(overnight.Contains(at(4, 12, 0)))
+	assert.False(overnight.Contains(at(4, 5, 0)))
+	assert.False(overnight.Contains(at(3, 23, 0)))
+
+	// every night, including the Sunday to Monday one
+	overnight.Weekdays = nil
+	assert.True(overnight.Contains(at(0, 1, 0)))
+	assert.True(overnight.Contains(at(0, 23, 0)))
+	assert.False(overnight.Contains(at(0, 12, 0)))
+}
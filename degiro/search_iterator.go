@@ -0,0 +1,154 @@
+package degiro
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	defaultSearchPageSize        = 100
+	defaultSearchRequestInterval = 250 * time.Millisecond
+)
+
+// SearchIterator walks through all the pages of a product search, skipping
+// the products already returned by a previous page:
+//
+//	it := client.IterateEtfs(EtfSearchOptions{SearchText: "MSCI"})
+//	for it.Next() {
+//		var etf EtfProduct
+//		if err := it.Scan(&etf); err != nil {
+//			...
+//		}
+//	}
+//	if it.Err() != nil {
+//		...
+//	}
+type SearchIterator struct {
+	// MaxResults stops the iteration after that many results, 0 meaning no
+	// limit.
+	MaxResults int
+	// RequestInterval is the minimum delay between two page requests.
+	RequestInterval time.Duration
+
+	fetch  func(paging SearchPaging) ([]json.RawMessage, int, error)
+	paging SearchPaging
+	sleep  func(time.Duration)
+
+	lastRequest time.Time
+	seen        map[string]bool
+	count       int
+	total       int
+	page        []json.RawMessage
+	index       int
+	current     json.RawMessage
+	done        bool
+	err         error
+}
+
+func newSearchIterator(paging SearchPaging, fetch func(paging SearchPaging) ([]json.RawMessage, int, error)) *SearchIterator {
+	if paging.Limit <= 0 {
+		paging.Limit = defaultSearchPageSize
+	}
+	return &SearchIterator{
+		RequestInterval: defaultSearchRequestInterval,
+		fetch:           fetch,
+		paging:          paging,
+		sleep:           time.Sleep,
+		seen:            make(map[string]bool),
+		total:           -1,
+	}
+}
+
+// Next advances to the next result, fetching the next page if needed. It
+// returns false when there is no more result or an error occurred.
+func (it *SearchIterator) Next() bool {
+	if it.MaxResults > 0 && it.count >= it.MaxResults {
+		return false
+	}
+	for {
+		for it.index >= len(it.page) {
+			if it.done || it.err != nil {
+				return false
+			}
+			it.fetchPage()
+		}
+		current := it.page[it.index]
+		it.index++
+		if id := searchResultId(current); id != "" {
+			if it.seen[id] {
+				continue
+			}
+			it.seen[id] = true
+		}
+		it.current = current
+		it.count++
+		return true
+	}
+}
+
+func (it *SearchIterator) fetchPage() {
+	if wait := it.RequestInterval - time.Now().Sub(it.lastRequest); !it.lastRequest.IsZero() && wait > 0 {
+		it.sleep(wait)
+	}
+	it.lastRequest = time.Now()
+	page, total, err := it.fetch(it.paging)
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.page = page
+	it.index = 0
+	it.total = total
+	it.paging.Offset += len(page)
+	if len(page) < it.paging.Limit || (total > 0 && it.paging.Offset >= total) {
+		it.done = true
+	}
+	// a server ignoring the offset keeps returning the same results
+	if !it.hasNewResult(page) {
+		it.done = true
+	}
+}
+
+func (it *SearchIterator) hasNewResult(page []json.RawMessage) bool {
+	for _, result := range page {
+		if id := searchResultId(result); id == "" || !it.seen[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func searchResultId(result json.RawMessage) string {
+	id := struct {
+		Id string `json:"id"`
+	}{}
+	if err := json.Unmarshal(result, &id); err != nil {
+		return ""
+	}
+	return id.Id
+}
+
+// Scan decodes the current result into v, which can be a *Product or one
+// of the type specific products.
+func (it *SearchIterator) Scan(v interface{}) error {
+	return json.Unmarshal(it.current, v)
+}
+
+// Product returns the current result decoded as a Product.
+func (it *SearchIterator) Product() Product {
+	product := Product{}
+	if err := it.Scan(&product); err != nil && it.err == nil {
+		it.err = err
+	}
+	return product
+}
+
+func (it *SearchIterator) Err() error {
+	return it.err
+}
+
+// Total returns the total number of results announced by the server, or -1
+// before the first page is fetched.
+func (it *SearchIterator) Total() int {
+	return it.total
+}
@@ -0,0 +1,318 @@
+package streaming
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Candle struct {
+	IssueId  string
+	Start    time.Time
+	Interval time.Duration
+	Open     decimal.Decimal
+	High     decimal.Decimal
+	Low      decimal.Decimal
+	Close    decimal.Decimal
+	Volume   decimal.Decimal
+	// Trades is the number of trades aggregated in the candle, zero for a
+	// candle filling a gap.
+	Trades int
+}
+
+func (c Candle) End() time.Time {
+	return c.Start.Add(c.Interval)
+}
+
+func (c *Candle) add(price decimal.Decimal, volume decimal.Decimal) {
+	if c.Trades == 0 && c.Open.IsZero() {
+		c.Open = price
+		c.High = price
+		c.Low = price
+	}
+	if price.GreaterThan(c.High) {
+		c.High = price
+	}
+	if price.LessThan(c.Low) {
+		c.Low = price
+	}
+	c.Close = price
+	c.Volume = c.Volume.Add(volume)
+	c.Trades++
+}
+
+type candleState struct {
+	current    *Candle
+	lastClosed *Candle
+	// seeded is set while lastClosed comes from Seed and has not been
+	// emitted, so that live trades can complete it.
+	seeded bool
+
+	lastTime         time.Time
+	lastPrice        decimal.Decimal
+	cumulativeVolume decimal.Decimal
+}
+
+// CandleAggregator builds OHLC candles from trades. Candles are aligned on
+// the local midnight of Location, so that a 5 minutes candle starts at
+// 09:00, 09:05, ... in exchange time whatever the time zone offset.
+type CandleAggregator struct {
+	Interval time.Duration
+	Location *time.Location
+	// FillGaps emits flat candles without volume for the intervals without
+	// trade, within the same day only.
+	FillGaps bool
+	// OnCandle is called for each closed candle, in chronological order for a
+	// given issue.
+	OnCandle func(Candle)
+
+	mu     sync.Mutex
+	issues map[string]*candleState
+	// pending holds the closed candles not yet passed to OnCandle, which is
+	// called without mu held so that it can use the aggregator.
+	pending    []Candle
+	delivering bool
+}
+
+func NewCandleAggregator(interval time.Duration, location *time.Location, onCandle func(Candle)) (*CandleAggregator, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid candle interval %v", interval)
+	}
+	if location == nil {
+		location = time.UTC
+	}
+	return &CandleAggregator{
+		Interval: interval,
+		Location: location,
+		OnCandle: onCandle,
+		issues:   make(map[string]*candleState),
+	}, nil
+}
+
+// Attach feeds the aggregator with the quote updates of a streaming client.
+// Issues subscribed with DefaultFields carry neither trade time nor volume:
+// their candles are timed on reception and have no volume. Subscribe them
+// with TradeFields as well to get both.
+func (a *CandleAggregator) Attach(client *Client) {
+	client.OnQuoteUpdate(a.AddQuote)
+}
+
+func (a *CandleAggregator) bucketStart(t time.Time) time.Time {
+	t = t.In(a.Location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, a.Location)
+	return midnight.Add(t.Sub(midnight) / a.Interval * a.Interval)
+}
+
+func (a *CandleAggregator) state(issueId string) *candleState {
+	state, found := a.issues[issueId]
+	if !found {
+		state = &candleState{}
+		a.issues[issueId] = state
+	}
+	return state
+}
+
+// Seed initializes an issue with historical candles so that gaps are filled
+// from the last known close and a partial last candle is completed by live
+// trades.
+func (a *CandleAggregator) Seed(issueId string, history []Candle) {
+	if len(history) == 0 {
+		return
+	}
+	sorted := append([]Candle(nil), history...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+	last := sorted[len(sorted)-1]
+	last.IssueId = issueId
+	last.Interval = a.Interval
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state := a.state(issueId)
+	state.current = nil
+	state.lastClosed = &last
+	state.seeded = true
+	state.lastPrice = last.Close
+}
+
+// AddQuote adds the last trade of a quote. Quotes that do not carry a new
+// trade, such as bid or ask updates, are ignored. Volume is taken from the
+// CumulativeVolume difference when available and from LastVolume otherwise.
+func (a *CandleAggregator) AddQuote(quote ProductQuote) {
+	if quote.LastPrice.IsZero() {
+		return
+	}
+	a.mu.Lock()
+	defer a.unlockAndDeliver()
+	state := a.state(quote.IssueId)
+
+	newTrade := false
+	if !quote.LastTime.IsZero() {
+		newTrade = !quote.LastTime.Equal(state.lastTime)
+	} else {
+		newTrade = !quote.LastPrice.Equal(state.lastPrice)
+	}
+	if !quote.CumulativeVolume.Equal(state.cumulativeVolume) {
+		newTrade = true
+	}
+	if !newTrade {
+		return
+	}
+
+	volume := quote.LastVolume
+	if quote.CumulativeVolume.IsPositive() {
+		// the first cumulative volume seen is only used as a baseline
+		if state.cumulativeVolume.IsPositive() {
+			volume = quote.CumulativeVolume.Sub(state.cumulativeVolume)
+		}
+		if volume.IsNegative() {
+			// the cumulative volume was reset for a new session
+			volume = quote.CumulativeVolume
+		}
+		state.cumulativeVolume = quote.CumulativeVolume
+	}
+	t := quote.LastTime
+	if t.IsZero() {
+		t = time.Now()
+	}
+	state.lastTime = quote.LastTime
+	state.lastPrice = quote.LastPrice
+	a.addTrade(quote.IssueId, state, t, quote.LastPrice, volume)
+}
+
+// AddTrade adds a single trade.
+func (a *CandleAggregator) AddTrade(issueId string, t time.Time, price decimal.Decimal, volume decimal.Decimal) {
+	a.mu.Lock()
+	defer a.unlockAndDeliver()
+	state := a.state(issueId)
+	state.lastTime = t
+	state.lastPrice = price
+	a.addTrade(issueId, state, t, price, volume)
+}
+
+func (a *CandleAggregator) addTrade(issueId string, state *candleState, t time.Time, price decimal.Decimal, volume decimal.Decimal) {
+	start := a.bucketStart(t)
+	if state.current != nil && start.Before(state.current.Start) {
+		// late trade for a candle already closed
+		return
+	}
+	if state.current != nil && !start.Equal(state.current.Start) {
+		a.close(state)
+	}
+	if state.current == nil {
+		if state.lastClosed != nil && state.seeded && start.Equal(state.lastClosed.Start) {
+			reopened := *state.lastClosed
+			state.current = &reopened
+			state.lastClosed = nil
+			state.seeded = false
+		} else if state.lastClosed != nil && !start.After(state.lastClosed.Start) {
+			// late trade for a candle already emitted
+			return
+		} else {
+			a.fillGaps(issueId, state, start)
+			state.current = &Candle{
+				IssueId:  issueId,
+				Start:    start,
+				Interval: a.Interval,
+			}
+		}
+	}
+	state.current.add(price, volume)
+}
+
+// Flush closes the candles whose interval ended before now. Without it a
+// candle is only closed when the first trade of the next interval arrives.
+func (a *CandleAggregator) Flush(now time.Time) {
+	a.mu.Lock()
+	defer a.unlockAndDeliver()
+	start := a.bucketStart(now)
+	for issueId, state := range a.issues {
+		if state.current != nil && !state.current.End().After(now) {
+			a.close(state)
+		}
+		if state.current == nil {
+			a.fillGaps(issueId, state, start)
+		}
+	}
+}
+
+func (a *CandleAggregator) close(state *candleState) {
+	closed := *state.current
+	state.current = nil
+	state.lastClosed = &closed
+	state.seeded = false
+	a.emit(closed)
+}
+
+// fillGaps emits flat candles from the last closed candle up to start,
+// excluded, when both are on the same day.
+func (a *CandleAggregator) fillGaps(issueId string, state *candleState, start time.Time) {
+	if !a.FillGaps || state.lastClosed == nil {
+		return
+	}
+	y1, m1, d1 := state.lastClosed.Start.In(a.Location).Date()
+	y2, m2, d2 := start.In(a.Location).Date()
+	if y1 != y2 || m1 != m2 || d1 != d2 {
+		return
+	}
+	for next := state.lastClosed.End(); next.Before(start); next = next.Add(a.Interval) {
+		price := state.lastClosed.Close
+		flat := Candle{
+			IssueId:  issueId,
+			Start:    next,
+			Interval: a.Interval,
+			Open:     price,
+			High:     price,
+			Low:      price,
+			Close:    price,
+		}
+		state.lastClosed = &flat
+		state.seeded = false
+		a.emit(flat)
+	}
+}
+
+func (a *CandleAggregator) emit(candle Candle) {
+	a.pending = append(a.pending, candle)
+}
+
+// unlockAndDeliver releases mu and passes the pending candles to OnCandle.
+// A single caller delivers at a time so that candles keep their order; the
+// candles emitted meanwhile, including from OnCandle itself, are delivered by
+// that caller.
+func (a *CandleAggregator) unlockAndDeliver() {
+	if a.delivering {
+		a.mu.Unlock()
+		return
+	}
+	a.delivering = true
+	for len(a.pending) > 0 {
+		candles := a.pending
+		a.pending = nil
+		onCandle := a.OnCandle
+		a.mu.Unlock()
+		if onCandle != nil {
+			for _, candle := range candles {
+				onCandle(candle)
+			}
+		}
+		a.mu.Lock()
+	}
+	a.delivering = false
+	a.mu.Unlock()
+}
+
+// Current returns the candle being built for an issue.
+func (a *CandleAggregator) Current(issueId string) (Candle, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, found := a.issues[issueId]
+	if !found || state.current == nil {
+		return Candle{}, false
+	}
+	return *state.current, true
+}
@@ -0,0 +1,167 @@
+package streaming
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCandleAggregator_AddTrade(t *testing.T) {
+	assert := assert.New(t)
+	loc := time.FixedZone("CET", 3600)
+	var candles []Candle
+	aggregator, err := NewCandleAggregator(5*time.Minute, loc, func(c Candle) {
+		candles = append(candles, c)
+	})
+	assert.Nil(err)
+
+	at := func(h, m, s int) time.Time {
+		return time.Date(2019, 10, 10, h, m, s, 0, loc)
+	}
+	aggregator.AddTrade("1", at(9, 0, 10), decimal.New(10, 0), decimal.New(100, 0))
+	aggregator.AddTrade("1", at(9, 2, 0), decimal.New(12, 0), decimal.New(50, 0))
+	aggregator.AddTrade("1", at(9, 4, 59), decimal.New(9, 0), decimal.New(10, 0))
+	aggregator.AddTrade("1", at(9, 5, 0), decimal.New(11, 0), decimal.New(1, 0))
+	assert.Equal(1, len(candles))
+
+	first := candles[0]
+	assert.Equal(at(9, 0, 0), first.Start)
+	assert.Equal(at(9, 5, 0), first.End())
+	assert.True(decimal.New(10, 0).Equal(first.Open))
+	assert.True(decimal.New(12, 0).Equal(first.High))
+	assert.True(decimal.New(9, 0).Equal(first.Low))
+	assert.True(decimal.New(9, 0).Equal(first.Close))
+	assert.True(decimal.New(160, 0).Equal(first.Volume))
+	assert.Equal(3, first.Trades)
+
+	aggregator.Flush(at(9, 9, 0))
+	assert.Equal(1, len(candles))
+	aggregator.Flush(at(9, 10, 0))
+	if assert.Equal(2, len(candles)) {
+		assert.Equal(at(9, 5, 0), candles[1].Start)
+	}
+	_, ok := aggregator.Current("1")
+	assert.False(ok)
+}
+
+func TestCandleAggregator_FillGaps(t *testing.T) {
+	assert := assert.New(t)
+	var candles []Candle
+	aggregator, err := NewCandleAggregator(time.Minute, time.UTC, func(c Candle) {
+		candles = append(candles, c)
+	})
+	assert.Nil(err)
+	aggregator.FillGaps = true
+
+	at := func(d, h, m int) time.Time {
+		return time.Date(2019, 10, d, h, m, 0, 0, time.UTC)
+	}
+	aggregator.AddTrade("1", at(10, 9, 0), decimal.New(10, 0), decimal.New(1, 0))
+	aggregator.AddTrade("1", at(10, 9, 3), decimal.New(11, 0), decimal.New(1, 0))
+	if assert.Equal(3, len(candles)) {
+		assert.Equal(at(10, 9, 1), candles[1].Start)
+		assert.Equal(at(10, 9, 2), candles[2].Start)
+		assert.True(decimal.New(10, 0).Equal(candles[2].Open))
+		assert.True(decimal.New(10, 0).Equal(candles[2].Close))
+		assert.Equal(0, candles[2].Trades)
+	}
+
+	// no gap filling over night
+	aggregator.AddTrade("1", at(11, 9, 0), decimal.New(12, 0), decimal.New(1, 0))
+	assert.Equal(4, len(candles))
+}
+
+func TestCandleAggregator_SeedAndQuotes(t *testing.T) {
+	assert := assert.New(t)
+	var candles []Candle
+	aggregator, err := NewCandleAggregator(time.Minute, time.UTC, func(c Candle) {
+		candles = append(candles, c)
+	})
+	assert.Nil(err)
+	at := func(m, s int) time.Time {
+		return time.Date(2019, 10, 10, 9, m, s, 0, time.UTC)
+	}
+	aggregator.Seed("1", []Candle{
+		{Start: at(0, 0), Open: decimal.New(10, 0), High: decimal.New(10, 0), Low: decimal.New(10, 0), Close: decimal.New(10, 0), Volume: decimal.New(5, 0)},
+		{Start: at(1, 0), Open: decimal.New(10, 0), High: decimal.New(11, 0), Low: decimal.New(10, 0), Close: decimal.New(11, 0), Volume: decimal.New(5, 0)},
+	})
+
+	quote := ProductQuote{IssueId: "1", LastPrice: decimal.New(12, 0), LastTime: at(1, 30), CumulativeVolume: decimal.New(1000, 0)}
+	aggregator.AddQuote(quote)
+	quote.BidPrice = decimal.New(11, 0)
+	aggregator.AddQuote(quote)
+	quote.LastPrice = decimal.New(9, 0)
+	quote.LastTime = at(1, 40)
+	quote.CumulativeVolume = decimal.New(1020, 0)
+	aggregator.AddQuote(quote)
+
+	current, ok := aggregator.Current("1")
+	if assert.True(ok) {
+		assert.Equal(at(1, 0), current.Start)
+		assert.True(decimal.New(10, 0).Equal(current.Open))
+		assert.True(decimal.New(12, 0).Equal(current.High))
+		assert.True(decimal.New(9, 0).Equal(current.Low))
+		assert.True(decimal.New(25, 0).Equal(current.Volume), current.Volume.String())
+		assert.Equal(2, current.Trades)
+	}
+	assert.Equal(0, len(candles))
+}
+
+func TestCandleAggregator_LateTradeAfterFlush(t *testing.T) {
+	assert := assert.New(t)
+	var candles []Candle
+	aggregator, err := NewCandleAggregator(time.Minute, time.UTC, func(c Candle) {
+		candles = append(candles, c)
+	})
+	assert.Nil(err)
+	at := func(m, s int) time.Time {
+		return time.Date(2019, 10, 10, 9, m, s, 0, time.UTC)
+	}
+	aggregator.AddTrade("1", at(0, 10), decimal.New(10, 0), decimal.New(1, 0))
+	aggregator.Flush(at(1, 1))
+	assert.Equal(1, len(candles))
+
+	aggregator.AddTrade("1", at(0, 50), decimal.New(11, 0), decimal.New(1, 0))
+	aggregator.AddTrade("1", at(1, 5), decimal.New(12, 0), decimal.New(1, 0))
+	aggregator.Flush(at(2, 0))
+	if assert.Equal(2, len(candles)) {
+		assert.Equal(at(1, 0), candles[1].Start)
+		assert.Equal(1, candles[1].Trades)
+	}
+}
+
+func TestCandleAggregator_CallbackUsesAggregator(t *testing.T) {
+	assert := assert.New(t)
+	var candles []Candle
+	var aggregator *CandleAggregator
+	var err error
+	aggregator, err = NewCandleAggregator(time.Minute, time.UTC, func(c Candle) {
+		candles = append(candles, c)
+		_, ok := aggregator.Current(c.IssueId)
+		assert.True(ok)
+		if len(candles) == 1 {
+			aggregator.AddTrade(c.IssueId, c.End().Add(time.Minute), c.Close, decimal.New(1, 0))
+		}
+	})
+	assert.Nil(err)
+
+	at := func(m int) time.Time {
+		return time.Date(2019, 10, 10, 9, m, 0, 0, time.UTC)
+	}
+	aggregator.AddTrade("1", at(0), decimal.New(10, 0), decimal.New(1, 0))
+	aggregator.AddTrade("1", at(1), decimal.New(11, 0), decimal.New(1, 0))
+	if assert.Equal(2, len(candles)) {
+		assert.Equal(at(0), candles[0].Start)
+		assert.Equal(at(1), candles[1].Start)
+	}
+}
+
+func TestNewCandleAggregator_InvalidInterval(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewCandleAggregator(0, time.UTC, nil)
+	assert.NotNil(err)
+	_, err = NewCandleAggregator(-time.Minute, time.UTC, nil)
+	assert.NotNil(err)
+}
@@ -6,11 +6,91 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+type SubscriptionMap struct {
+	sync.RWMutex
+	items map[string][]Field
+}
+
+func NewSubscriptionMap() *SubscriptionMap {
+	return &SubscriptionMap{
+		items: make(map[string][]Field),
+	}
+}
+
+// Add merges fields into the subscribed fields of an issue
+func (m *SubscriptionMap) Add(issueId string, fields []Field) {
+	m.Lock()
+	defer m.Unlock()
+	current := m.items[issueId]
+fieldloop:
+	for _, field := range fields {
+		for _, f := range current {
+			if f == field {
+				continue fieldloop
+			}
+		}
+		current = append(current, field)
+	}
+	m.items[issueId] = current
+}
+
+// Remove deletes an issue and returns the fields it was subscribed to
+func (m *SubscriptionMap) Remove(issueId string) ([]Field, bool) {
+	m.Lock()
+	defer m.Unlock()
+	fields, ok := m.items[issueId]
+	delete(m.items, issueId)
+	return fields, ok
+}
+
+// Get retrieves the subscribed fields of an issue
+func (m *SubscriptionMap) Get(issueId string) ([]Field, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	fields, ok := m.items[issueId]
+	return append([]Field(nil), fields...), ok
+}
+
+// RemoveFields removes fields from the subscribed fields of an issue, the
+// issue itself being removed once it has no field left
+func (m *SubscriptionMap) RemoveFields(issueId string, fields []Field) {
+	m.Lock()
+	defer m.Unlock()
+	var remaining []Field
+fieldloop:
+	for _, f := range m.items[issueId] {
+		for _, field := range fields {
+			if f == field {
+				continue fieldloop
+			}
+		}
+		remaining = append(remaining, f)
+	}
+	if len(remaining) == 0 {
+		delete(m.items, issueId)
+		return
+	}
+	m.items[issueId] = remaining
+}
+
+// All returns a copy of all the subscriptions
+func (m *SubscriptionMap) All() map[string][]Field {
+	m.RLock()
+	defer m.RUnlock()
+	res := make(map[string][]Field, len(m.items))
+	for issueId, fields := range m.items {
+		res[issueId] = append([]Field(nil), fields...)
+	}
+	return res
+}
+
+// Deprecated: IndexMap is no longer used by the streaming client.
 type IndexMap struct {
 	sync.RWMutex
 	items map[string]int64
 }
 
+// Deprecated: IndexMap is no longer used by the streaming client.
 func NewIndexMap() *IndexMap {
 	return &IndexMap{
 		items: make(map[string]int64),
@@ -32,11 +112,13 @@ func (m *IndexMap) Get(key string) (int64, bool) {
 	return value, ok
 }
 
+// Deprecated: StringValueMap is no longer used by the streaming client.
 type StringValueMap struct {
 	sync.RWMutex
 	items map[int64]string
 }
 
+// Deprecated: StringValueMap is no longer used by the streaming client.
 func NewStringValueMap() *StringValueMap {
 	return &StringValueMap{
 		items: make(map[int64]string),
@@ -58,11 +140,13 @@ func (m *StringValueMap) Get(key int64) (string, bool) {
 	return value, ok
 }
 
+// Deprecated: DecimalValueMap is no longer used by the streaming client.
 type DecimalValueMap struct {
 	sync.RWMutex
 	items map[int64]decimal.Decimal
 }
 
+// Deprecated: DecimalValueMap is no longer used by the streaming client.
 func NewDecimalValueMap() *DecimalValueMap {
 	return &DecimalValueMap{
 		items: make(map[int64]decimal.Decimal),
@@ -0,0 +1,106 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var errSessionExpired = errors.New("streaming session expired")
+
+type ConnectionState int
+
+const (
+	Disconnected ConnectionState = iota
+	Connected
+	Reconnecting
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case Disconnected:
+		return "disconnected"
+	case Connected:
+		return "connected"
+	case Reconnecting:
+		return "reconnecting"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+type ConnectionEvent struct {
+	State ConnectionState
+	Time  time.Time
+	Err   error
+}
+
+// ConnectionEvents returns the channel on which connection state changes are
+// published. Events are dropped when the channel is full.
+func (c *Client) ConnectionEvents() <-chan ConnectionEvent {
+	return c.events
+}
+
+func (c *Client) emitConnectionEvent(state ConnectionState, err error) {
+	select {
+	case c.events <- ConnectionEvent{
+		State: state,
+		Time:  time.Now(),
+		Err:   err,
+	}:
+	default:
+	}
+}
+
+// reconnect requests a new session until it succeeds, waiting between
+// attempts with an exponential backoff, then subscribes again to all the
+// active subscriptions. It gives up when the client is closed.
+func (c *Client) reconnect(cause error) {
+	c.emitConnectionEvent(Reconnecting, cause)
+	backoff := c.ReconnectMinBackoff
+	for {
+		err := c.resubscribe()
+		if err == nil {
+			c.emitConnectionEvent(Connected, nil)
+			return
+		}
+		log.Warnf("reconnecting streaming session: %v, retrying in %s", err, backoff)
+		c.emitConnectionEvent(Disconnected, err)
+		select {
+		case <-c.closed:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > c.ReconnectMaxBackoff {
+			backoff = c.ReconnectMaxBackoff
+		}
+	}
+}
+
+// resubscribe opens a new session and posts the recorded subscriptions. As
+// subscriptions are recorded before being posted, one made concurrently is
+// either included here or posted to the new session.
+func (c *Client) resubscribe() error {
+	err := c.getNewSessionId()
+	if err != nil {
+		return err
+	}
+	// vwd indexes are only valid for the session that created them
+	c.quotes.clear()
+
+	controlData := ""
+	for issueId, fields := range c.subscriptions.All() {
+		controlData += GetControlDataFromIssueIdListWithFields([]string{issueId}, fields, true)
+	}
+	if controlData == "" {
+		return nil
+	}
+	err = c.postControlData(controlData)
+	if err != nil {
+		return fmt.Errorf("resubscribing: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package streaming
+
+import (
+	"strings"
+	"time"
+)
+
+// Field is a vwd data field that can be requested for an issue.
+type Field string
+
+const (
+	FullName           Field = "FullName"
+	LastPrice          Field = "LastPrice"
+	LastTime           Field = "LastTime"
+	LastVolume         Field = "LastVolume"
+	CumulativeVolume   Field = "CumulativeVolume"
+	BidPrice           Field = "BidPrice"
+	BidTime            Field = "BidTime"
+	BidVolume          Field = "BidVolume"
+	AskPrice           Field = "AskPrice"
+	AskTime            Field = "AskTime"
+	AskVolume          Field = "AskVolume"
+	OpenPrice          Field = "OpenPrice"
+	HighPrice          Field = "HighPrice"
+	LowPrice           Field = "LowPrice"
+	ClosePrice         Field = "ClosePrice"
+	PreviousClosePrice Field = "PreviousClosePrice"
+)
+
+// DefaultFields are the fields requested by SubscribeQuotes.
+var DefaultFields = []Field{
+	BidPrice,
+	AskPrice,
+	LastPrice,
+	BidVolume,
+	AskVolume,
+	OpenPrice,
+	HighPrice,
+	LowPrice,
+	FullName,
+}
+
+// TradeFields are the fields giving the time and volume of the last trade,
+// used by CandleAggregator.
+var TradeFields = []Field{
+	LastPrice,
+	LastTime,
+	LastVolume,
+	CumulativeVolume,
+}
+
+// AllQuoteFields are all the fields used to fill a ProductQuote.
+var AllQuoteFields = []Field{
+	FullName,
+	LastPrice,
+	LastTime,
+	LastVolume,
+	CumulativeVolume,
+	BidPrice,
+	BidTime,
+	BidVolume,
+	AskPrice,
+	AskTime,
+	AskVolume,
+	OpenPrice,
+	HighPrice,
+	LowPrice,
+	ClosePrice,
+	PreviousClosePrice,
+}
+
+var vwdTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"15:04:05",
+	"15:04",
+}
+
+// vwdClockSkew is how far in the future a time of day may be before it is
+// considered to belong to the previous session.
+const vwdClockSkew = time.Minute
+
+// parseVwdTime parses a vwd time value. Values holding only a time of day are
+// considered to be on the same day as now, or on the previous weekday when
+// that would put them in the future, like the close of the previous session
+// seen before the market opens.
+func parseVwdTime(value string, now time.Time, loc *time.Location) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+	now = now.In(loc)
+	for _, layout := range vwdTimeLayouts {
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			continue
+		}
+		if t.Year() == 0 {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+			for t.After(now.Add(vwdClockSkew)) || t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+				t = t.AddDate(0, 0, -1)
+			}
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func loadVwdLocation() *time.Location {
+	loc, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,125 @@
+package streaming
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+type Side int
+
+const (
+	BidSide Side = iota
+	AskSide
+)
+
+type OrderBookLevel struct {
+	Price  decimal.Decimal
+	Volume decimal.Decimal
+}
+
+// OrderBook is a snapshot of the order book of an issue. Bids and Asks are
+// ordered from the best level to the worst one.
+type OrderBook struct {
+	IssueId string
+	Bids    []OrderBookLevel
+	Asks    []OrderBookLevel
+}
+
+// OrderBookFields returns the vwd fields needed to follow an order book of
+// the given depth.
+func OrderBookFields(depth int) []Field {
+	var res []Field
+	for i := 1; i <= depth; i++ {
+		res = append(res,
+			Field(fmt.Sprintf("%s%d", BidPrice, i)),
+			Field(fmt.Sprintf("%s%d", BidVolume, i)),
+			Field(fmt.Sprintf("%s%d", AskPrice, i)),
+			Field(fmt.Sprintf("%s%d", AskVolume, i)),
+		)
+	}
+	return res
+}
+
+func (b OrderBook) levels(side Side) []OrderBookLevel {
+	if side == AskSide {
+		return b.Asks
+	}
+	return b.Bids
+}
+
+func (b OrderBook) BestBid() (OrderBookLevel, bool) {
+	if len(b.Bids) == 0 {
+		return OrderBookLevel{}, false
+	}
+	return b.Bids[0], true
+}
+
+func (b OrderBook) BestAsk() (OrderBookLevel, bool) {
+	if len(b.Asks) == 0 {
+		return OrderBookLevel{}, false
+	}
+	return b.Asks[0], true
+}
+
+func (b OrderBook) Spread() (decimal.Decimal, bool) {
+	bid, okBid := b.BestBid()
+	ask, okAsk := b.BestAsk()
+	if !okBid || !okAsk {
+		return decimal.Decimal{}, false
+	}
+	return ask.Price.Sub(bid.Price), true
+}
+
+func (b OrderBook) Mid() (decimal.Decimal, bool) {
+	bid, okBid := b.BestBid()
+	ask, okAsk := b.BestAsk()
+	if !okBid || !okAsk {
+		return decimal.Decimal{}, false
+	}
+	return ask.Price.Add(bid.Price).Div(decimal.New(2, 0)), true
+}
+
+// DepthWeightedPrice returns the average price obtained by consuming volume
+// on the given side of the book. It returns false if the book is not deep
+// enough to absorb the whole volume.
+func (b OrderBook) DepthWeightedPrice(side Side, volume decimal.Decimal) (decimal.Decimal, bool) {
+	if !volume.IsPositive() {
+		return decimal.Decimal{}, false
+	}
+	remaining := volume
+	var total decimal.Decimal
+	for _, level := range b.levels(side) {
+		taken := decimal.Min(remaining, level.Volume)
+		total = total.Add(taken.Mul(level.Price))
+		remaining = remaining.Sub(taken)
+		if !remaining.IsPositive() {
+			return total.Div(volume), true
+		}
+	}
+	return decimal.Decimal{}, false
+}
+
+// Imbalance returns (bid volume - ask volume) / (bid volume + ask volume) over
+// the first levels of the book, from -1 (only asks) to 1 (only bids). A zero
+// or negative levels count uses the whole book.
+func (b OrderBook) Imbalance(levels int) decimal.Decimal {
+	bidVolume := sumVolume(b.Bids, levels)
+	askVolume := sumVolume(b.Asks, levels)
+	total := bidVolume.Add(askVolume)
+	if total.IsZero() {
+		return decimal.Decimal{}
+	}
+	return bidVolume.Sub(askVolume).Div(total)
+}
+
+func sumVolume(levels []OrderBookLevel, count int) decimal.Decimal {
+	var res decimal.Decimal
+	for i, level := range levels {
+		if count > 0 && i >= count {
+			break
+		}
+		res = res.Add(level.Volume)
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+package streaming
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestOrderBook() OrderBook {
+	return OrderBook{
+		IssueId: "123",
+		Bids: []OrderBookLevel{
+			{Price: decimal.New(99, 0), Volume: decimal.New(100, 0)},
+			{Price: decimal.New(98, 0), Volume: decimal.New(350, 0)},
+		},
+		Asks: []OrderBookLevel{
+			{Price: decimal.New(101, 0), Volume: decimal.New(50, 0)},
+			{Price: decimal.New(102, 0), Volume: decimal.New(150, 0)},
+		},
+	}
+}
+
+func TestOrderBook_SpreadAndMid(t *testing.T) {
+	book := newTestOrderBook()
+	spread, ok := book.Spread()
+	assert.True(t, ok)
+	assert.True(t, decimal.New(2, 0).Equal(spread))
+	mid, ok := book.Mid()
+	assert.True(t, ok)
+	assert.True(t, decimal.New(100, 0).Equal(mid))
+
+	_, ok = OrderBook{}.Mid()
+	assert.False(t, ok)
+}
+
+func TestOrderBook_DepthWeightedPrice(t *testing.T) {
+	book := newTestOrderBook()
+	price, ok := book.DepthWeightedPrice(AskSide, decimal.New(100, 0))
+	assert.True(t, ok)
+	assert.True(t, decimal.NewFromFloat(101.5).Equal(price), price.String())
+
+	price, ok = book.DepthWeightedPrice(BidSide, decimal.New(50, 0))
+	assert.True(t, ok)
+	assert.True(t, decimal.New(99, 0).Equal(price))
+
+	_, ok = book.DepthWeightedPrice(AskSide, decimal.New(1000, 0))
+	assert.False(t, ok)
+}
+
+func TestOrderBook_Imbalance(t *testing.T) {
+	book := newTestOrderBook()
+	assert.True(t, decimal.New(250, 0).Div(decimal.New(650, 0)).Equal(book.Imbalance(0)))
+	assert.True(t, decimal.New(1, 0).Div(decimal.New(3, 0)).Equal(book.Imbalance(1)))
+	assert.True(t, decimal.Zero.Equal(OrderBook{}.Imbalance(0)))
+}
+
+func TestOrderBookFields(t *testing.T) {
+	assert.Equal(t, []Field{"BidPrice1", "BidVolume1", "AskPrice1", "AskVolume1", "BidPrice2", "BidVolume2", "AskPrice2", "AskVolume2"}, OrderBookFields(2))
+}
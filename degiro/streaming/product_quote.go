@@ -1,16 +1,27 @@
 package streaming
 
-import "github.com/shopspring/decimal"
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type ProductQuote struct {
-	IssueId   string
-	FullName  string
-	LastPrice decimal.Decimal
-	BidPrice  decimal.Decimal
-	AskPrice  decimal.Decimal
-	OpenPrice decimal.Decimal
-	LowPrice  decimal.Decimal
-	HighPrice decimal.Decimal
-	BidVolume decimal.Decimal
-	AskVolume decimal.Decimal
+	IssueId            string
+	FullName           string
+	LastPrice          decimal.Decimal
+	LastTime           time.Time
+	LastVolume         decimal.Decimal
+	CumulativeVolume   decimal.Decimal
+	BidPrice           decimal.Decimal
+	BidTime            time.Time
+	AskPrice           decimal.Decimal
+	AskTime            time.Time
+	OpenPrice          decimal.Decimal
+	LowPrice           decimal.Decimal
+	HighPrice          decimal.Decimal
+	ClosePrice         decimal.Decimal
+	PreviousClosePrice decimal.Decimal
+	BidVolume          decimal.Decimal
+	AskVolume          decimal.Decimal
 }
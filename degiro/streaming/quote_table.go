@@ -0,0 +1,277 @@
+package streaming
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type vwdMessage struct {
+	Name  string        `json:"m"`
+	Value []interface{} `json:"v"`
+}
+
+type fieldRef struct {
+	issueId string
+	field   Field
+}
+
+type issueState struct {
+	quote  ProductQuote
+	levels map[Field]decimal.Decimal
+	fields int
+}
+
+// quoteTable maps vwd indexes to the issue and field they stand for and
+// keeps one quote per issue, updated in place as values arrive.
+type quoteTable struct {
+	sync.RWMutex
+	indexes map[string]int64
+	refs    map[int64]fieldRef
+	issues  map[string]*issueState
+}
+
+func newQuoteTable() *quoteTable {
+	return &quoteTable{
+		indexes: make(map[string]int64),
+		refs:    make(map[int64]fieldRef),
+		issues:  make(map[string]*issueState),
+	}
+}
+
+func splitVwdName(name string) (string, Field, bool) {
+	i := strings.LastIndex(name, ".")
+	if i <= 0 || i == len(name)-1 {
+		return "", "", false
+	}
+	return name[:i], Field(name[i+1:]), true
+}
+
+func (t *quoteTable) clear() {
+	t.Lock()
+	defer t.Unlock()
+	t.indexes = make(map[string]int64)
+	t.refs = make(map[int64]fieldRef)
+	t.issues = make(map[string]*issueState)
+}
+
+// apply applies a batch of vwd messages, parsing time values in location,
+// and returns the ids of the issues whose quote changed. It returns
+// errSessionExpired if the batch tells that the session is no longer valid.
+func (t *quoteTable) apply(messages []vwdMessage, location *time.Location) ([]string, error) {
+	t.Lock()
+	defer t.Unlock()
+	now := time.Now()
+	var updated []string
+	seen := make(map[string]bool)
+	touch := func(issueId string) {
+		if !seen[issueId] {
+			seen[issueId] = true
+			updated = append(updated, issueId)
+		}
+	}
+	for _, message := range messages {
+		switch message.Name {
+		case "a_req":
+			if len(message.Value) < 2 {
+				continue
+			}
+			name, okName := message.Value[0].(string)
+			index, okIndex := message.Value[1].(float64)
+			if !okName || !okIndex {
+				continue
+			}
+			t.request(name, int64(index))
+		case "a_rel", "rel":
+			if len(message.Value) < 1 {
+				continue
+			}
+			switch v := message.Value[0].(type) {
+			case string:
+				t.release(v)
+			case float64:
+				if ref, ok := t.refs[int64(v)]; ok {
+					t.release(fmt.Sprintf("%s.%s", ref.issueId, ref.field))
+				}
+			}
+		case "un":
+			if len(message.Value) < 2 {
+				continue
+			}
+			index, okIndex := message.Value[0].(float64)
+			value, okValue := message.Value[1].(float64)
+			if !okIndex || !okValue {
+				continue
+			}
+			if ref, state, ok := t.lookup(int64(index)); ok {
+				state.setDecimal(ref.field, decimal.NewFromFloat(value))
+				touch(ref.issueId)
+			}
+		case "us":
+			if len(message.Value) < 2 {
+				continue
+			}
+			index, okIndex := message.Value[0].(float64)
+			value, okValue := message.Value[1].(string)
+			if !okIndex || !okValue {
+				continue
+			}
+			if ref, state, ok := t.lookup(int64(index)); ok {
+				state.setString(ref.field, value, now, location)
+				touch(ref.issueId)
+			}
+		case "sr":
+			return updated, errSessionExpired
+		}
+	}
+	return updated, nil
+}
+
+func (t *quoteTable) request(name string, index int64) {
+	issueId, field, ok := splitVwdName(name)
+	if !ok {
+		return
+	}
+	if previous, found := t.indexes[name]; found {
+		delete(t.refs, previous)
+	} else {
+		state, found := t.issues[issueId]
+		if !found {
+			state = &issueState{
+				quote:  ProductQuote{IssueId: issueId},
+				levels: make(map[Field]decimal.Decimal),
+			}
+			t.issues[issueId] = state
+		}
+		state.fields++
+	}
+	t.indexes[name] = index
+	t.refs[index] = fieldRef{issueId: issueId, field: field}
+}
+
+func (t *quoteTable) release(name string) {
+	index, found := t.indexes[name]
+	if !found {
+		return
+	}
+	ref := t.refs[index]
+	delete(t.indexes, name)
+	delete(t.refs, index)
+	state, found := t.issues[ref.issueId]
+	if !found {
+		return
+	}
+	state.fields--
+	if state.fields <= 0 {
+		delete(t.issues, ref.issueId)
+	}
+}
+
+// releaseFields drops the mappings of fields that were unsubscribed
+func (t *quoteTable) releaseFields(issueId string, fields []Field) {
+	t.Lock()
+	defer t.Unlock()
+	for _, field := range fields {
+		t.release(fmt.Sprintf("%s.%s", issueId, field))
+	}
+}
+
+func (t *quoteTable) lookup(index int64) (fieldRef, *issueState, bool) {
+	ref, found := t.refs[index]
+	if !found {
+		return fieldRef{}, nil, false
+	}
+	state, found := t.issues[ref.issueId]
+	return ref, state, found
+}
+
+func (t *quoteTable) quote(issueId string) ProductQuote {
+	t.RLock()
+	defer t.RUnlock()
+	state, found := t.issues[issueId]
+	if !found {
+		return ProductQuote{IssueId: issueId}
+	}
+	return state.quote
+}
+
+func (t *quoteTable) orderBook(issueId string, depth int) OrderBook {
+	t.RLock()
+	defer t.RUnlock()
+	book := OrderBook{
+		IssueId: issueId,
+	}
+	state, found := t.issues[issueId]
+	if !found {
+		return book
+	}
+	for i := 1; i <= depth; i++ {
+		if level, ok := state.orderBookLevel(BidPrice, BidVolume, i); ok {
+			book.Bids = append(book.Bids, level)
+		}
+		if level, ok := state.orderBookLevel(AskPrice, AskVolume, i); ok {
+			book.Asks = append(book.Asks, level)
+		}
+	}
+	return book
+}
+
+func (s *issueState) orderBookLevel(priceField Field, volumeField Field, level int) (OrderBookLevel, bool) {
+	price := s.levels[Field(fmt.Sprintf("%s%d", priceField, level))]
+	if price.IsZero() {
+		return OrderBookLevel{}, false
+	}
+	return OrderBookLevel{
+		Price:  price,
+		Volume: s.levels[Field(fmt.Sprintf("%s%d", volumeField, level))],
+	}, true
+}
+
+func (s *issueState) setDecimal(field Field, value decimal.Decimal) {
+	q := &s.quote
+	switch field {
+	case LastPrice:
+		q.LastPrice = value
+	case LastVolume:
+		q.LastVolume = value
+	case CumulativeVolume:
+		q.CumulativeVolume = value
+	case BidPrice:
+		q.BidPrice = value
+	case BidVolume:
+		q.BidVolume = value
+	case AskPrice:
+		q.AskPrice = value
+	case AskVolume:
+		q.AskVolume = value
+	case OpenPrice:
+		q.OpenPrice = value
+	case HighPrice:
+		q.HighPrice = value
+	case LowPrice:
+		q.LowPrice = value
+	case ClosePrice:
+		q.ClosePrice = value
+	case PreviousClosePrice:
+		q.PreviousClosePrice = value
+	default:
+		s.levels[field] = value
+	}
+}
+
+func (s *issueState) setString(field Field, value string, now time.Time, location *time.Location) {
+	q := &s.quote
+	switch field {
+	case FullName:
+		q.FullName = value
+	case LastTime:
+		q.LastTime, _ = parseVwdTime(value, now, location)
+	case BidTime:
+		q.BidTime, _ = parseVwdTime(value, now, location)
+	case AskTime:
+		q.AskTime, _ = parseVwdTime(value, now, location)
+	}
+}
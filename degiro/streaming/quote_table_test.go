@@ -0,0 +1,117 @@
+package streaming
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteTable_Apply(t *testing.T) {
+	assert := assert.New(t)
+	loc := time.FixedZone("CET", 3600)
+	table := newQuoteTable()
+	updated, err := table.apply([]vwdMessage{
+		{Name: "a_req", Value: []interface{}{"360015751.LastPrice", float64(1)}},
+		{Name: "a_req", Value: []interface{}{"360015751.FullName", float64(2)}},
+		{Name: "a_req", Value: []interface{}{"360015751.LastTime", float64(3)}},
+		{Name: "a_req", Value: []interface{}{"360015751.BidPrice1", float64(4)}},
+		{Name: "a_req", Value: []interface{}{"360015751.BidVolume1", float64(5)}},
+		{Name: "un", Value: []interface{}{float64(1), 52.3}},
+		{Name: "us", Value: []interface{}{float64(2), "ROYAL DUTCH SHELL"}},
+		{Name: "us", Value: []interface{}{float64(3), "2019-10-10 17:35:19"}},
+		{Name: "un", Value: []interface{}{float64(4), 52.2}},
+		{Name: "un", Value: []interface{}{float64(5), float64(1500)}},
+		{Name: "un", Value: []interface{}{float64(42), float64(1)}},
+		{Name: "h"},
+	}, loc)
+	assert.Nil(err)
+	assert.Equal([]string{"360015751"}, updated)
+
+	quote := table.quote("360015751")
+	assert.Equal("360015751", quote.IssueId)
+	assert.Equal("ROYAL DUTCH SHELL", quote.FullName)
+	assert.True(decimal.NewFromFloat(52.3).Equal(quote.LastPrice))
+	assert.Equal(time.Date(2019, 10, 10, 17, 35, 19, 0, loc), quote.LastTime)
+
+	book := table.orderBook("360015751", 2)
+	if assert.Equal(1, len(book.Bids)) {
+		assert.True(decimal.NewFromFloat(52.2).Equal(book.Bids[0].Price))
+		assert.True(decimal.New(1500, 0).Equal(book.Bids[0].Volume))
+	}
+	assert.Equal(0, len(book.Asks))
+}
+
+func TestQuoteTable_Release(t *testing.T) {
+	assert := assert.New(t)
+	table := newQuoteTable()
+	_, err := table.apply([]vwdMessage{
+		{Name: "a_req", Value: []interface{}{"123.LastPrice", float64(1)}},
+		{Name: "a_req", Value: []interface{}{"123.BidPrice", float64(2)}},
+		{Name: "un", Value: []interface{}{float64(1), float64(10)}},
+		{Name: "a_rel", Value: []interface{}{"123.BidPrice"}},
+	}, time.UTC)
+	assert.Nil(err)
+	assert.Equal(1, len(table.refs))
+	assert.True(decimal.New(10, 0).Equal(table.quote("123").LastPrice))
+
+	table.releaseFields("123", []Field{LastPrice})
+	assert.Equal(0, len(table.refs))
+	assert.Equal(0, len(table.indexes))
+	assert.Equal(0, len(table.issues))
+
+	_, err = table.apply([]vwdMessage{{Name: "sr"}}, time.UTC)
+	assert.Equal(errSessionExpired, err)
+}
+
+func BenchmarkQuoteTable_Apply(b *testing.B) {
+	const issueCount = 5000
+	table := newQuoteTable()
+	var requests []vwdMessage
+	var updates []vwdMessage
+	index := 0
+	for i := 0; i < issueCount; i++ {
+		for _, field := range DefaultFields {
+			index++
+			requests = append(requests, vwdMessage{Name: "a_req", Value: []interface{}{fmt.Sprintf("%d.%s", 100000+i, field), float64(index)}})
+			if field == FullName {
+				updates = append(updates, vwdMessage{Name: "us", Value: []interface{}{float64(index), "NAME"}})
+				continue
+			}
+			updates = append(updates, vwdMessage{Name: "un", Value: []interface{}{float64(index), 12.34}})
+		}
+	}
+	if _, err := table.apply(requests, time.UTC); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		if _, err := table.apply(updates, time.UTC); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(len(updates)*b.N)/time.Since(start).Seconds(), "updates/s")
+}
+
+func BenchmarkQuoteTable_Quote(b *testing.B) {
+	const issueCount = 5000
+	table := newQuoteTable()
+	var requests []vwdMessage
+	for i := 0; i < issueCount; i++ {
+		for j, field := range DefaultFields {
+			requests = append(requests, vwdMessage{Name: "a_req", Value: []interface{}{fmt.Sprintf("%d.%s", 100000+i, field), float64(i*len(DefaultFields) + j)}})
+		}
+	}
+	if _, err := table.apply(requests, time.UTC); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		table.quote(fmt.Sprintf("%d", 100000+i%issueCount))
+	}
+}
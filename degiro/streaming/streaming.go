@@ -2,14 +2,15 @@ package streaming
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/dghubble/sling"
-	"github.com/shopspring/decimal"
 )
 
 const (
@@ -17,32 +18,40 @@ const (
 	streamingApiVersion = "1.0.20180305"
 )
 
-var vwdDatas = []string{
-	"BidPrice",
-	"AskPrice",
-	"LastPrice",
-	//"LastTime",
-	"BidVolume",
-	"AskVolume",
-	//"CumulativeVolume",
-	"OpenPrice",
-	"HighPrice",
-	"LowPrice",
-	"FullName",
-}
-
 type Client struct {
+	// Location is the time zone used to parse vwd time values.
+	Location *time.Location
+	// ReconnectMinBackoff and ReconnectMaxBackoff bound the delay between
+	// two attempts to open a new session after the current one expired.
+	ReconnectMinBackoff time.Duration
+	ReconnectMaxBackoff time.Duration
+	// MaxConsecutiveFailures is the number of failed polls after which the
+	// session is considered lost.
+	MaxConsecutiveFailures int
+
 	httpclient *http.Client
 	sling      *sling.Sling
 	baseURL    *url.URL
 
 	clientId          int
 	quoteUpdatePeriod time.Duration
-	sessionId         string
 
-	indexes       *IndexMap
-	stringValues  *StringValueMap
-	decimalValues *DecimalValueMap
+	sessionMu sync.RWMutex
+	sessionId string
+
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	quotes        *quoteTable
+	subscriptions *SubscriptionMap
+
+	orderBooksMu    sync.RWMutex
+	orderBookDepths map[string]int
+
+	events chan ConnectionEvent
+
+	handlersMu    sync.RWMutex
+	quoteHandlers []func(ProductQuote)
 }
 
 func NewStreamingClient(httpClient *http.Client, clientId int, updatePeriod time.Duration) *Client {
@@ -55,14 +64,20 @@ func NewStreamingClient(httpClient *http.Client, clientId int, updatePeriod time
 		Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:69.0) Gecko/20100101 Firefox/69.0")
 
 	client := &Client{
-		sling:             base,
-		httpclient:        httpClient,
-		baseURL:           baseURL,
-		clientId:          clientId,
-		quoteUpdatePeriod: updatePeriod,
-		indexes:           NewIndexMap(),
-		stringValues:      NewStringValueMap(),
-		decimalValues:     NewDecimalValueMap(),
+		Location:               loadVwdLocation(),
+		ReconnectMinBackoff:    1 * time.Second,
+		ReconnectMaxBackoff:    1 * time.Minute,
+		MaxConsecutiveFailures: 5,
+		sling:                  base,
+		httpclient:             httpClient,
+		baseURL:                baseURL,
+		clientId:               clientId,
+		quoteUpdatePeriod:      updatePeriod,
+		quotes:                 newQuoteTable(),
+		subscriptions:          NewSubscriptionMap(),
+		orderBookDepths:        make(map[string]int),
+		events:                 make(chan ConnectionEvent, 16),
+		closed:                 make(chan struct{}),
 	}
 	return client
 }
@@ -72,6 +87,7 @@ func (c *Client) Start() error {
 	if err != nil {
 		return fmt.Errorf("setting new session Id: %v", err)
 	}
+	c.emitConnectionEvent(Connected, nil)
 	go c.loopUpdateQuotes()
 	return nil
 }
@@ -81,76 +97,129 @@ func (c *Client) getNewSessionId() error {
 	if err != nil {
 		return fmt.Errorf("requesting session id: %v", err)
 	}
+	c.sessionMu.Lock()
 	c.sessionId = newsessionId
+	c.sessionMu.Unlock()
 	return nil
 }
 
+func (c *Client) session() string {
+	c.sessionMu.RLock()
+	defer c.sessionMu.RUnlock()
+	return c.sessionId
+}
+
+// Close stops polling quote updates and reconnecting.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.emitConnectionEvent(Disconnected, nil)
+	})
+}
+
 func (c *Client) SubscribeQuotes(idlist []string) error {
-	err := c.subscribeProductQuotes(idlist)
-	if err != nil {
-		return err
+	return c.SubscribeQuotesWithFields(idlist, DefaultFields)
+}
+
+// SubscribeQuotesWithFields subscribes to the given fields only, merging them
+// with the fields already subscribed for each issue. The subscription is
+// recorded even if posting it fails, and is sent again on reconnection.
+func (c *Client) SubscribeQuotesWithFields(idlist []string, fields []Field) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("no field to subscribe")
 	}
-	return nil
+	for _, id := range idlist {
+		c.subscriptions.Add(id, fields)
+	}
+	return c.postControlData(GetControlDataFromIssueIdListWithFields(idlist, fields, true))
 }
 
 func (c *Client) UnSubscribeQuotes(idlist []string) error {
-	err := c.unSubscribeProductQuotes(idlist)
+	controlData := ""
+	for _, id := range idlist {
+		fields, ok := c.subscriptions.Get(id)
+		if !ok {
+			fields = DefaultFields
+		}
+		controlData += GetControlDataFromIssueIdListWithFields([]string{id}, fields, false)
+	}
+	c.orderBooksMu.Lock()
+	for _, id := range idlist {
+		fields, _ := c.subscriptions.Remove(id)
+		c.quotes.releaseFields(id, fields)
+		delete(c.orderBookDepths, id)
+	}
+	c.orderBooksMu.Unlock()
+	return c.postControlData(controlData)
+}
+
+// SubscribeOrderBook subscribes to the first depth levels of the order book
+// of an issue.
+func (c *Client) SubscribeOrderBook(issueId string, depth int) error {
+	if depth <= 0 {
+		return fmt.Errorf("invalid order book depth: %d", depth)
+	}
+	err := c.SubscribeQuotesWithFields([]string{issueId}, OrderBookFields(depth))
 	if err != nil {
 		return err
 	}
+	c.orderBooksMu.Lock()
+	defer c.orderBooksMu.Unlock()
+	if depth > c.orderBookDepths[issueId] {
+		c.orderBookDepths[issueId] = depth
+	}
 	return nil
 }
 
+func (c *Client) UnSubscribeOrderBook(issueId string) error {
+	c.orderBooksMu.Lock()
+	depth, ok := c.orderBookDepths[issueId]
+	delete(c.orderBookDepths, issueId)
+	c.orderBooksMu.Unlock()
+	if !ok {
+		return nil
+	}
+	fields := OrderBookFields(depth)
+	c.subscriptions.RemoveFields(issueId, fields)
+	c.quotes.releaseFields(issueId, fields)
+	return c.postControlData(GetControlDataFromIssueIdListWithFields([]string{issueId}, fields, false))
+}
+
+// GetOrderBook returns a snapshot of the order book of an issue subscribed
+// with SubscribeOrderBook. Levels without price are not included.
+func (c *Client) GetOrderBook(issueId string) OrderBook {
+	c.orderBooksMu.RLock()
+	depth := c.orderBookDepths[issueId]
+	c.orderBooksMu.RUnlock()
+	return c.quotes.orderBook(issueId, depth)
+}
+
 func (c *Client) loopUpdateQuotes() {
 	ticker := time.NewTicker(c.quoteUpdatePeriod)
+	defer ticker.Stop()
+	failures := 0
 	for {
 		select {
+		case <-c.closed:
+			return
 		case <-ticker.C:
 			err := c.getQuoteUpdates()
-			if err != nil {
-				log.Errorf("retrieving quote updates: %v", err)
+			if err == nil {
+				failures = 0
+				continue
+			}
+			log.Errorf("retrieving quote updates: %v", err)
+			failures++
+			if err == errSessionExpired || failures >= c.MaxConsecutiveFailures {
+				c.reconnect(err)
+				failures = 0
 			}
 		}
 	}
 }
 
-func (c *Client) getQuoteStringValue(name string) string {
-	id, exist := c.indexes.Get(name)
-	if !exist {
-		return ""
-	}
-	value, exist := c.stringValues.Get(id)
-	if !exist {
-		return ""
-	}
-	return value
-}
-func (c *Client) getQuoteDecimalValue(name string) decimal.Decimal {
-	id, exist := c.indexes.Get(name)
-	if !exist {
-		return decimal.Decimal{}
-	}
-	value, exist := c.decimalValues.Get(id)
-	if !exist {
-		return decimal.Decimal{}
-	}
-	return value
-}
-
 func (c *Client) GetQuote(issueid string) ProductQuote {
-	quote := ProductQuote{
-		IssueId: issueid,
-	}
-	quote.FullName = c.getQuoteStringValue(fmt.Sprintf("%s.FullName", issueid))
-	quote.AskPrice = c.getQuoteDecimalValue(fmt.Sprintf("%s.AskPrice", issueid))
-	quote.BidPrice = c.getQuoteDecimalValue(fmt.Sprintf("%s.BidPrice", issueid))
-	quote.LastPrice = c.getQuoteDecimalValue(fmt.Sprintf("%s.LastPrice", issueid))
-	quote.BidVolume = c.getQuoteDecimalValue(fmt.Sprintf("%s.BidVolume", issueid))
-	quote.AskVolume = c.getQuoteDecimalValue(fmt.Sprintf("%s.AskVolume", issueid))
-	quote.OpenPrice = c.getQuoteDecimalValue(fmt.Sprintf("%s.OpenPrice", issueid))
-	quote.HighPrice = c.getQuoteDecimalValue(fmt.Sprintf("%s.HighPrice", issueid))
-	quote.LowPrice = c.getQuoteDecimalValue(fmt.Sprintf("%s.LowPrice", issueid))
-	return quote
+	return c.quotes.quote(issueid)
 }
 
 func (c *Client) requestSession() (string, error) {
@@ -172,20 +241,12 @@ func (c *Client) requestSession() (string, error) {
 	return response.SessionId, nil
 }
 
-func (c *Client) subscribeProductQuotes(issueIdList []string) error {
-	return c.postProductQuotes(issueIdList, true)
-}
-
-func (c *Client) unSubscribeProductQuotes(issueIdList []string) error {
-	return c.postProductQuotes(issueIdList, false)
-}
-
-func (c *Client) postProductQuotes(issueIdList []string, subscribe bool) error {
-	resp, err := c.sling.New().Post(fmt.Sprintf("%s", c.sessionId)).
+func (c *Client) postControlData(controlData string) error {
+	resp, err := c.sling.New().Post(c.session()).
 		BodyJSON(&struct {
 			Data string `json:"controlData"`
 		}{
-			Data: GetControlDataFromIssueIdList(issueIdList, subscribe),
+			Data: controlData,
 		}).ReceiveSuccess(nil)
 	if err != nil {
 		return fmt.Errorf("posting product quotes: %v", err)
@@ -197,9 +258,13 @@ func (c *Client) postProductQuotes(issueIdList []string, subscribe bool) error {
 }
 
 func GetControlDataFromIssueIdList(issueIdList []string, subscribe bool) string {
+	return GetControlDataFromIssueIdListWithFields(issueIdList, DefaultFields, subscribe)
+}
+
+func GetControlDataFromIssueIdListWithFields(issueIdList []string, fields []Field, subscribe bool) string {
 	res := ""
 	for _, issueId := range issueIdList {
-		for _, data := range vwdDatas {
+		for _, data := range fields {
 			if subscribe {
 				res += fmt.Sprintf("req(%s.%s);", issueId, data)
 			} else {
@@ -211,32 +276,49 @@ func GetControlDataFromIssueIdList(issueIdList []string, subscribe bool) string
 }
 
 func (c *Client) getQuoteUpdates() error {
-	response := &[]struct {
-		Name  string        `json:"m"`
-		Value []interface{} `json:"v"`
-	}{}
-	resp, err := c.sling.New().Get(fmt.Sprintf("%s", c.sessionId)).ReceiveSuccess(response)
+	response := &[]vwdMessage{}
+	resp, err := c.sling.New().Get(c.session()).ReceiveSuccess(response)
+	if err == io.EOF {
+		return errSessionExpired
+	}
 	if err != nil {
 		return fmt.Errorf("requesting quote updates: %v", err)
 	}
+	if resp.StatusCode == http.StatusNotFound {
+		return errSessionExpired
+	}
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		return fmt.Errorf("not 2xx status: %d - %s", resp.StatusCode, http.StatusText(resp.StatusCode))
 	}
+	if len(*response) == 0 {
+		return errSessionExpired
+	}
 
-	for _, entry := range *response {
-		switch entry.Name {
-		case "a_req":
-			c.indexes.Set(entry.Value[0].(string), int64(entry.Value[1].(float64)))
-		case "un":
-			c.decimalValues.Set(int64(entry.Value[0].(float64)), decimal.NewFromFloat(entry.Value[1].(float64)))
-		case "us":
-			c.stringValues.Set(int64(entry.Value[0].(float64)), entry.Value[1].(string))
-		case "sr":
-			err := c.getNewSessionId()
-			if err != nil {
-				return fmt.Errorf("getting new sessionid: %v", err)
-			}
+	updated, err := c.quotes.apply(*response, c.Location)
+	c.notifyQuoteHandlers(updated)
+	return err
+}
+
+// OnQuoteUpdate registers a handler called with the new quote of an issue
+// each time one of its values changes. Handlers are called sequentially from
+// the polling goroutine and should not block.
+func (c *Client) OnQuoteUpdate(handler func(ProductQuote)) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.quoteHandlers = append(c.quoteHandlers, handler)
+}
+
+func (c *Client) notifyQuoteHandlers(issueIds []string) {
+	c.handlersMu.RLock()
+	handlers := c.quoteHandlers
+	c.handlersMu.RUnlock()
+	if len(handlers) == 0 {
+		return
+	}
+	for _, issueId := range issueIds {
+		quote := c.quotes.quote(issueId)
+		for _, handler := range handlers {
+			handler(quote)
 		}
 	}
-	return nil
 }
@@ -88,8 +88,183 @@ func TestSubscribeProductQuotes(t *testing.T) {
 
 	streaming := NewStreamingClient(client, 0, 10*time.Second)
 	streaming.sessionId = sessionId
-	err := streaming.subscribeProductQuotes(issueList)
+	err := streaming.SubscribeQuotes(issueList)
 
 	assert.Nil(err)
 
 }
+
+func TestSubscribeQuotesWithFields(t *testing.T) {
+	assert := assert.New(t)
+	sessionId := "fdba16eb-d421-46a0-af14-1667394629e9"
+	var bodies []string
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		buf := new(bytes.Buffer)
+		_, err := buf.ReadFrom(req.Body)
+		assert.Nil(err)
+		bodies = append(bodies, buf.String())
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(``)),
+			Header:     getCommonStreamingHeaders(),
+		}
+	})
+
+	streaming := NewStreamingClient(client, 0, 10*time.Second)
+	streaming.sessionId = sessionId
+	assert.Nil(streaming.SubscribeQuotesWithFields([]string{"123"}, []Field{LastPrice, LastTime}))
+	assert.Nil(streaming.SubscribeQuotesWithFields([]string{"123"}, []Field{LastPrice, CumulativeVolume}))
+	assert.Nil(streaming.UnSubscribeQuotes([]string{"123"}))
+
+	if assert.Equal(3, len(bodies)) {
+		assert.Equal("{\"controlData\":\"req(123.LastPrice);req(123.LastTime);\"}\n", bodies[0])
+		assert.Equal("{\"controlData\":\"rel(123.LastPrice);rel(123.LastTime);rel(123.CumulativeVolume);\"}\n", bodies[2])
+	}
+	_, ok := streaming.subscriptions.Get("123")
+	assert.False(ok)
+}
+
+func TestParseVwdTime(t *testing.T) {
+	assert := assert.New(t)
+	loc := time.FixedZone("CET", 3600)
+	now := time.Date(2019, 10, 10, 12, 0, 0, 0, loc)
+
+	parsed, ok := parseVwdTime("11:35:19", now, loc)
+	assert.True(ok)
+	assert.Equal(time.Date(2019, 10, 10, 11, 35, 19, 0, loc), parsed)
+
+	parsed, ok = parseVwdTime("17:35:19", now, loc)
+	assert.True(ok)
+	assert.Equal(time.Date(2019, 10, 9, 17, 35, 19, 0, loc), parsed)
+
+	monday := time.Date(2019, 10, 14, 8, 0, 0, 0, loc)
+	parsed, ok = parseVwdTime("17:35:19", monday, loc)
+	assert.True(ok)
+	assert.Equal(time.Date(2019, 10, 11, 17, 35, 19, 0, loc), parsed)
+
+	parsed, ok = parseVwdTime("2019-10-09 09:00:01", now, loc)
+	assert.True(ok)
+	assert.Equal(time.Date(2019, 10, 9, 9, 0, 1, 0, loc), parsed)
+
+	_, ok = parseVwdTime("", now, loc)
+	assert.False(ok)
+	_, ok = parseVwdTime("not a time", now, loc)
+	assert.False(ok)
+}
+
+func TestGetQuoteUpdates_SessionExpired(t *testing.T) {
+	assert := assert.New(t)
+	responses := []struct {
+		status int
+		body   string
+	}{
+		{200, `[{"m":"sr"}]`},
+		{404, ``},
+		{200, `[]`},
+		{200, ``},
+	}
+	for _, r := range responses {
+		client := NewTestClient(func(req *http.Request) *http.Response {
+			return &http.Response{
+				StatusCode: r.status,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(r.body)),
+				Header:     getCommonStreamingHeaders(),
+			}
+		})
+		streaming := NewStreamingClient(client, 0, 10*time.Second)
+		assert.Equal(errSessionExpired, streaming.getQuoteUpdates(), "status %d, body %s", r.status, r.body)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	assert := assert.New(t)
+	var controlData []string
+	sessionRequests := 0
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		headers := getCommonStreamingHeaders()
+		if req.URL.Path == "/CORS/request_session" {
+			sessionRequests++
+			if sessionRequests == 1 {
+				return &http.Response{
+					StatusCode: 503,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(``)),
+					Header:     headers,
+				}
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"sessionId":"new-session"}`)),
+				Header:     headers,
+			}
+		}
+		assert.Equal("/CORS/new-session", req.URL.Path)
+		buf := new(bytes.Buffer)
+		_, err := buf.ReadFrom(req.Body)
+		assert.Nil(err)
+		controlData = append(controlData, buf.String())
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(``)),
+			Header:     headers,
+		}
+	})
+
+	streaming := NewStreamingClient(client, 0, 10*time.Second)
+	streaming.ReconnectMinBackoff = time.Millisecond
+	_, err := streaming.quotes.apply([]vwdMessage{{Name: "a_req", Value: []interface{}{"123.LastPrice", float64(1)}}}, time.UTC)
+	assert.Nil(err)
+	streaming.subscriptions.Add("123", []Field{LastPrice})
+	streaming.reconnect(errSessionExpired)
+
+	assert.Equal(2, sessionRequests)
+	assert.Equal("new-session", streaming.sessionId)
+	assert.Equal([]string{"{\"controlData\":\"req(123.LastPrice);\"}\n"}, controlData)
+	_, found := streaming.quotes.indexes["123.LastPrice"]
+	assert.False(found)
+
+	var states []ConnectionState
+	for len(streaming.ConnectionEvents()) > 0 {
+		states = append(states, (<-streaming.ConnectionEvents()).State)
+	}
+	assert.Equal([]ConnectionState{Reconnecting, Disconnected, Connected}, states)
+}
+
+func TestSubscribeQuotes_RecordedBeforePosting(t *testing.T) {
+	assert := assert.New(t)
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: 404,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(``)),
+			Header:     getCommonStreamingHeaders(),
+		}
+	})
+	streaming := NewStreamingClient(client, 0, 10*time.Second)
+	assert.NotNil(streaming.SubscribeQuotesWithFields([]string{"123"}, []Field{LastPrice}))
+	fields, ok := streaming.subscriptions.Get("123")
+	assert.True(ok)
+	assert.Equal([]Field{LastPrice}, fields)
+}
+
+func TestClose_StopsReconnect(t *testing.T) {
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: 503,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(``)),
+			Header:     getCommonStreamingHeaders(),
+		}
+	})
+	streaming := NewStreamingClient(client, 0, 10*time.Second)
+	streaming.ReconnectMinBackoff = time.Hour
+	streaming.ReconnectMaxBackoff = time.Hour
+	done := make(chan struct{})
+	go func() {
+		streaming.reconnect(errSessionExpired)
+		close(done)
+	}()
+	streaming.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reconnect did not stop after Close")
+	}
+}
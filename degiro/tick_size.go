@@ -0,0 +1,187 @@
+package degiro
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+)
+
+type RoundingDirection int
+
+const (
+	RoundNearest RoundingDirection = iota
+	RoundDown
+	RoundUp
+)
+
+// TickBand is the tick size used from a price up to the next band.
+type TickBand struct {
+	From decimal.Decimal `json:"from"`
+	Tick decimal.Decimal `json:"tick"`
+}
+
+// TickSizeTable is a list of price bands, sorted by From.
+type TickSizeTable []TickBand
+
+// usTickSizes follow SEC rule 612: 0.0001 below 1 and 0.01 above.
+var usTickSizes = TickSizeTable{
+	{From: decimal.Zero, Tick: decimal.New(1, -4)},
+	{From: decimal.New(1, 0), Tick: decimal.New(1, -2)},
+}
+
+// MifidLiquidityBands is the number of liquidity bands of the MiFID II tick
+// size regime, band 1 being the least liquid.
+const MifidLiquidityBands = 6
+
+// oneTwoFive returns the i-th value of the 1, 2, 5, 10, 20, ... sequence
+// starting at 10^exp.
+func oneTwoFive(i int, exp int32) decimal.Decimal {
+	return decimal.New([]int64{1, 2, 5}[i%3], exp+int32(i/3))
+}
+
+// MifidTickSizes returns the tick size table of the MiFID II regime (RTS 11)
+// for a liquidity band from 1 to MifidLiquidityBands, or nil for another
+// band. The price ranges start at 0, 0.1, 0.2, 0.5, 1, ... up to 50000, and
+// each band uses a tick one step finer than the band below it on the 0.0001,
+// 0.0002, 0.0005, 0.001, ... sequence, e.g. 0.01 in band 1 and 0.0002 in band
+// 6 from 1 to 2.
+func MifidTickSizes(band int) TickSizeTable {
+	if band < 1 || band > MifidLiquidityBands {
+		return nil
+	}
+	const ranges = 19
+	table := make(TickSizeTable, ranges)
+	for i := range table {
+		from := decimal.Zero
+		if i > 0 {
+			from = oneTwoFive(i-1, -1)
+		}
+		step := i + 3 - band
+		if step < 0 {
+			step = 0
+		}
+		table[i] = TickBand{From: from, Tick: oneTwoFive(step, -4)}
+	}
+	return table
+}
+
+// DefaultTickSizes are the tick size tables by MIC code, used when
+// Client.TickSizes has no table for an exchange. European venues are not
+// listed: under MiFID II the tick size depends on the liquidity band of each
+// share, so their tables go in Client.ProductTickSizes, e.g. with
+// MifidTickSizes(band).
+var DefaultTickSizes = map[string]TickSizeTable{
+	"XNYS": usTickSizes,
+	"XNAS": usTickSizes,
+	"ARCX": usTickSizes,
+	"BATS": usTickSizes,
+}
+
+// LoadTickSizeTables reads tick size tables from a JSON object keyed by
+// exchange id or MIC code, e.g. {"XAMS": [{"from": "0", "tick": "0.001"}]}.
+func LoadTickSizeTables(r io.Reader) (map[string]TickSizeTable, error) {
+	tables := make(map[string]TickSizeTable)
+	if err := json.NewDecoder(r).Decode(&tables); err != nil {
+		return nil, fmt.Errorf("decoding tick size tables: %v", err)
+	}
+	for key, table := range tables {
+		sort.SliceStable(table, func(i, j int) bool {
+			return table[i].From.LessThan(table[j].From)
+		})
+		for _, band := range table {
+			if !band.Tick.IsPositive() {
+				return nil, fmt.Errorf("tick size table %s: tick must be positive", key)
+			}
+		}
+	}
+	return tables, nil
+}
+
+// TickSize returns the tick size for price, zero if the table is empty.
+func (t TickSizeTable) TickSize(price decimal.Decimal) decimal.Decimal {
+	tick := decimal.Zero
+	for _, band := range t {
+		if price.LessThan(band.From) {
+			break
+		}
+		tick = band.Tick
+	}
+	if tick.IsZero() && len(t) > 0 {
+		tick = t[0].Tick
+	}
+	return tick
+}
+
+// Round snaps price to the tick grid of its band.
+func (t TickSizeTable) Round(price decimal.Decimal, direction RoundingDirection) decimal.Decimal {
+	tick := t.TickSize(price)
+	if !tick.IsPositive() {
+		return price
+	}
+	ticks := price.Div(tick)
+	switch direction {
+	case RoundDown:
+		ticks = ticks.Floor()
+	case RoundUp:
+		ticks = ticks.Ceil()
+	default:
+		ticks = ticks.Round(0)
+	}
+	return ticks.Mul(tick)
+}
+
+// TickSizeTable returns the tick size table of product, looked up in
+// ProductTickSizes, then in TickSizes by exchange id then by MIC code, then
+// in DefaultTickSizes.
+func (c *Client) TickSizeTable(product Product) (TickSizeTable, bool) {
+	if table, found := c.ProductTickSizes[product.Id]; found {
+		return table, true
+	}
+	if table, found := c.TickSizes[product.ExchangeId]; found {
+		return table, true
+	}
+	exchange, found, err := c.GetExchange(product.ExchangeId)
+	if err != nil || !found || exchange.MicCode == "" {
+		return nil, false
+	}
+	if table, found := c.TickSizes[exchange.MicCode]; found {
+		return table, true
+	}
+	table, found := DefaultTickSizes[exchange.MicCode]
+	return table, found
+}
+
+func (c *Client) RoundPrice(product Product, price decimal.Decimal, direction RoundingDirection) (decimal.Decimal, error) {
+	table, found := c.TickSizeTable(product)
+	if !found {
+		return price, fmt.Errorf("no tick size table for product %s on exchange %s", product.Id, product.ExchangeId)
+	}
+	return table.Round(price, direction), nil
+}
+
+// SnapOrderPrices rounds the price and stop price of input to the tick grid
+// of product, never to a worse price: buy limits are rounded down and sell
+// limits up, buy stops down and sell stops up so they trigger no later.
+// Prices are left unchanged when product has no table.
+func (c *Client) SnapOrderPrices(input PlaceOrderInput, product Product) (PlaceOrderInput, error) {
+	table, found := c.TickSizeTable(product)
+	if !found {
+		log.Debugf("no tick size table for product %s on exchange %s, not snapping prices", product.Id, product.ExchangeId)
+		return input, nil
+	}
+	direction := RoundDown
+	if input.BuySell == Sell {
+		direction = RoundUp
+	}
+	if !input.Price.IsZero() {
+		input.Price = table.Round(input.Price, direction)
+	}
+	if !input.StopPrice.IsZero() {
+		input.StopPrice = table.Round(input.StopPrice, direction)
+	}
+	return input, nil
+}
@@ -0,0 +1,115 @@
+package degiro
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTickSizeTable_Round(t *testing.T) {
+	assert := assert.New(t)
+	tables, err := LoadTickSizeTables(strings.NewReader(`{"XAMS":[{"from":"10","tick":"0.01"},{"from":"0","tick":"0.005"}]}`))
+	assert.Nil(err)
+	table := tables["XAMS"]
+	assert.Equal("0.005", table.TickSize(decimal.RequireFromString("9.99")).String())
+	assert.Equal("0.01", table.TickSize(decimal.RequireFromString("10")).String())
+
+	assert.Equal("9.985", table.Round(decimal.RequireFromString("9.987"), RoundDown).String())
+	assert.Equal("9.99", table.Round(decimal.RequireFromString("9.987"), RoundUp).String())
+	assert.Equal("9.985", table.Round(decimal.RequireFromString("9.987"), RoundNearest).String())
+	assert.Equal("12.34", table.Round(decimal.RequireFromString("12.345"), RoundDown).String())
+	assert.Equal("12.35", table.Round(decimal.RequireFromString("12.341"), RoundUp).String())
+
+	_, err = LoadTickSizeTables(strings.NewReader(`{"XAMS":[{"from":"0","tick":"0"}]}`))
+	assert.NotNil(err)
+}
+
+func TestClient_SnapOrderPrices(t *testing.T) {
+	assert := assert.New(t)
+	client := NewTestClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(`{"exchanges":[{"id":663,"micCode":"XNAS"},{"id":200,"micCode":"XAMS"}]}`)),
+			Header:     getCommonHeaders(),
+		}
+	})
+	degiro := NewClient(client)
+	degiro.configuration = &Configuration{DictionaryUrl: "https://trader.degiro.nl/product_search/config/dictionary/"}
+
+	product := Product{Id: "331868", ExchangeId: "663"}
+	input, err := degiro.SnapOrderPrices(PlaceOrderInput{
+		BuySell:   Buy,
+		OrderType: StopLimited,
+		Price:     decimal.RequireFromString("101.237"),
+		StopPrice: decimal.RequireFromString("101.001"),
+	}, product)
+	assert.Nil(err)
+	assert.Equal("101.23", input.Price.String())
+	assert.Equal("101", input.StopPrice.String())
+
+	input.BuySell = Sell
+	input.Price = decimal.RequireFromString("0.12341")
+	input, err = degiro.SnapOrderPrices(input, product)
+	assert.Nil(err)
+	assert.Equal("0.1235", input.Price.String())
+
+	_, err = degiro.RoundPrice(Product{ExchangeId: "300"}, decimal.RequireFromString("1.234"), RoundNearest)
+	assert.NotNil(err)
+	unsnapped := PlaceOrderInput{BuySell: Buy, OrderType: Limited, Price: decimal.RequireFromString("1.23456")}
+	input, err = degiro.SnapOrderPrices(unsnapped, Product{ExchangeId: "300"})
+	assert.Nil(err)
+	assert.Equal("1.23456", input.Price.String())
+
+	// no default for the MiFID II venues, the band depends on the share
+	input, err = degiro.SnapOrderPrices(unsnapped, Product{ExchangeId: "200"})
+	assert.Nil(err)
+	assert.Equal("1.23456", input.Price.String())
+	degiro.ProductTickSizes = map[string]TickSizeTable{"1153605": MifidTickSizes(MifidLiquidityBands)}
+	input, err = degiro.SnapOrderPrices(unsnapped, Product{Id: "1153605", ExchangeId: "200"})
+	assert.Nil(err)
+	assert.Equal("1.2344", input.Price.String())
+	degiro.TickSizes = map[string]TickSizeTable{"XAMS": {{From: decimal.Zero, Tick: decimal.New(5, -2)}}}
+	price, err := degiro.RoundPrice(Product{ExchangeId: "200"}, decimal.RequireFromString("1.234"), RoundNearest)
+	assert.Nil(err)
+	assert.Equal("1.25", price.String())
+}
+
+func TestMifidTickSizes(t *testing.T) {
+	assert := assert.New(t)
+	assert.Nil(MifidTickSizes(0))
+	assert.Nil(MifidTickSizes(7))
+
+	// values of the RTS 11 annex table
+	for _, tt := range []struct {
+		band  int
+		price string
+		tick  string
+	}{
+		{1, "0.05", "0.0005"},
+		{1, "0.15", "0.001"},
+		{1, "1.5", "0.01"},
+		{1, "7", "0.05"},
+		{1, "60000", "500"},
+		{2, "0.05", "0.0002"},
+		{2, "7", "0.02"},
+		{3, "0.3", "0.0005"},
+		{3, "150", "0.2"},
+		{4, "0.15", "0.0001"},
+		{4, "25", "0.02"},
+		{5, "3", "0.001"},
+		{5, "1500", "0.5"},
+		{6, "0.05", "0.0001"},
+		{6, "1.5", "0.0002"},
+		{6, "75", "0.01"},
+		{6, "60000", "10"},
+	} {
+		table := MifidTickSizes(tt.band)
+		assert.Equal(19, len(table))
+		assert.Equal(tt.tick, table.TickSize(decimal.RequireFromString(tt.price)).String(), "band %d price %s", tt.band, tt.price)
+	}
+}
@@ -0,0 +1,88 @@
+package degiro
+
+import (
+	"time"
+)
+
+// TradingHours are the regular continuous trading hours of an exchange, Open
+// and Close being durations since local midnight. Holidays are not known.
+type TradingHours struct {
+	Timezone string
+	Open     time.Duration
+	Close    time.Duration
+}
+
+func clock(hour int, minute int) time.Duration {
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute
+}
+
+// DefaultTradingHours are the trading hours of the main exchanges, by MIC
+// code. They are used to fill Exchange.TradingHours and can be modified.
+var DefaultTradingHours = map[string]TradingHours{
+	"XAMS": {Timezone: "Europe/Amsterdam", Open: clock(9, 0), Close: clock(17, 30)},
+	"XPAR": {Timezone: "Europe/Paris", Open: clock(9, 0), Close: clock(17, 30)},
+	"XBRU": {Timezone: "Europe/Brussels", Open: clock(9, 0), Close: clock(17, 30)},
+	"XLIS": {Timezone: "Europe/Lisbon", Open: clock(8, 0), Close: clock(16, 30)},
+	"XETR": {Timezone: "Europe/Berlin", Open: clock(9, 0), Close: clock(17, 30)},
+	"XFRA": {Timezone: "Europe/Berlin", Open: clock(8, 0), Close: clock(20, 0)},
+	"XMIL": {Timezone: "Europe/Rome", Open: clock(9, 0), Close: clock(17, 30)},
+	"XMAD": {Timezone: "Europe/Madrid", Open: clock(9, 0), Close: clock(17, 30)},
+	"XSWX": {Timezone: "Europe/Zurich", Open: clock(9, 0), Close: clock(17, 30)},
+	"XWBO": {Timezone: "Europe/Vienna", Open: clock(9, 0), Close: clock(17, 30)},
+	"XLON": {Timezone: "Europe/London", Open: clock(8, 0), Close: clock(16, 30)},
+	"XDUB": {Timezone: "Europe/Dublin", Open: clock(8, 0), Close: clock(16, 30)},
+	"XSTO": {Timezone: "Europe/Stockholm", Open: clock(9, 0), Close: clock(17, 30)},
+	"XHEL": {Timezone: "Europe/Helsinki", Open: clock(10, 0), Close: clock(18, 30)},
+	"XCSE": {Timezone: "Europe/Copenhagen", Open: clock(9, 0), Close: clock(17, 0)},
+	"XOSL": {Timezone: "Europe/Oslo", Open: clock(9, 0), Close: clock(16, 20)},
+	"XWAR": {Timezone: "Europe/Warsaw", Open: clock(9, 0), Close: clock(17, 0)},
+	"XPRA": {Timezone: "Europe/Prague", Open: clock(9, 0), Close: clock(16, 25)},
+	"XATH": {Timezone: "Europe/Athens", Open: clock(10, 15), Close: clock(17, 20)},
+	"XBUD": {Timezone: "Europe/Budapest", Open: clock(9, 0), Close: clock(17, 0)},
+	"XNYS": {Timezone: "America/New_York", Open: clock(9, 30), Close: clock(16, 0)},
+	"XNAS": {Timezone: "America/New_York", Open: clock(9, 30), Close: clock(16, 0)},
+	"ARCX": {Timezone: "America/New_York", Open: clock(9, 30), Close: clock(16, 0)},
+	"BATS": {Timezone: "America/New_York", Open: clock(9, 30), Close: clock(16, 0)},
+	"XTSE": {Timezone: "America/Toronto", Open: clock(9, 30), Close: clock(16, 0)},
+	"XTKS": {Timezone: "Asia/Tokyo", Open: clock(9, 0), Close: clock(15, 0)},
+	"XHKG": {Timezone: "Asia/Hong_Kong", Open: clock(9, 30), Close: clock(16, 0)},
+	"XSES": {Timezone: "Asia/Singapore", Open: clock(9, 0), Close: clock(17, 0)},
+	"XASX": {Timezone: "Australia/Sydney", Open: clock(10, 0), Close: clock(16, 0)},
+}
+
+func (h TradingHours) IsZero() bool {
+	return h.Timezone == "" && h.Open == 0 && h.Close == 0
+}
+
+func (h TradingHours) location() *time.Location {
+	loc, err := time.LoadLocation(h.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// IsOpen tells whether t is within the trading hours of a week day.
+func (h TradingHours) IsOpen(t time.Time) bool {
+	if h.IsZero() {
+		return false
+	}
+	t = t.In(h.location())
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	sinceMidnight := clock(t.Hour(), t.Minute()) + time.Duration(t.Second())*time.Second
+	return sinceMidnight >= h.Open && sinceMidnight < h.Close
+}
+
+// OpenAt returns the opening time of the trading day of t.
+func (h TradingHours) OpenAt(t time.Time) time.Time {
+	t = t.In(h.location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(h.Open)
+}
+
+// CloseAt returns the closing time of the trading day of t.
+func (h TradingHours) CloseAt(t time.Time) time.Time {
+	t = t.In(h.location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(h.Close)
+}
@@ -5,6 +5,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/dghubble/sling"
 	"github.com/shopspring/decimal"
 )
 
@@ -36,19 +37,21 @@ func (c *Client) GetTransactions(fromDate time.Time, toDate time.Time) ([]Transa
 		Transactions []Transaction `json:"data"`
 	}
 	response := &getTransactionsResponse{}
-	_, err := c.ReceiveSuccessReloginOn401(c.sling.New().
-		Get("reporting/secure/v4/transactions").
-		QueryStruct(&struct {
-			FromDate  shortDateTime `url:"fromDate"`
-			ToDate    shortDateTime `url:"toDate"`
-			AccountId int64         `url:"intAccount"`
-			SessionId string        `url:"sessionId"`
-		}{
-			FromDate:  shortDateTime(fromDate),
-			ToDate:    shortDateTime(toDate),
-			AccountId: c.accountId,
-			SessionId: c.sessionId,
-		}), response)
+	_, err := c.receiveSuccessReloginOn401(func() *sling.Sling {
+		return c.sling.New().
+			Get("reporting/secure/v4/transactions").
+			QueryStruct(&struct {
+				FromDate  shortDateTime `url:"fromDate"`
+				ToDate    shortDateTime `url:"toDate"`
+				AccountId int64         `url:"intAccount"`
+				SessionId string        `url:"sessionId"`
+			}{
+				FromDate:  shortDateTime(fromDate),
+				ToDate:    shortDateTime(toDate),
+				AccountId: c.accountId,
+				SessionId: c.session(),
+			})
+	}, response)
 	if err != nil {
 		return nil, err
 	}
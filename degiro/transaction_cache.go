@@ -34,7 +34,7 @@ func (c *TransactionCache) Merge(transactions []Transaction) {
 			c.transactions = append(c.transactions, transaction)
 		}
 	}
-	c.positions = getHistoricalPositionsFromTransactions(c.transactions)
+	c.positions = HistoricalPositionsFromTransactions(c.transactions)
 }
 
 func (c *TransactionCache) GetOpenedHistoricalPositionForProduct(productid string) (HistoricalPosition, bool) {
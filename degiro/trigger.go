@@ -0,0 +1,426 @@
+package degiro
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+	log "github.com/sirupsen/logrus"
+)
+
+type TriggerCondition string
+
+const (
+	CrossAbove TriggerCondition = "crossAbove"
+	CrossBelow TriggerCondition = "crossBelow"
+)
+
+type TriggerState string
+
+const (
+	TriggerArmed     TriggerState = "armed"
+	TriggerFired     TriggerState = "fired"
+	TriggerFailed    TriggerState = "failed"
+	TriggerCancelled TriggerState = "cancelled"
+	// TriggerInterrupted triggers were fired but the engine stopped before
+	// knowing whether their order was placed.
+	TriggerInterrupted TriggerState = "interrupted"
+)
+
+// Trigger places Order once, the first time the last price of IssueId
+// reaches Level, including when the first price seen is already beyond it,
+// e.g. after a gap.
+//
+// With RequireCross, a cross is only counted once the price has been seen on
+// the other side of Level by at least Hysteresis, so that a price already
+// beyond Level when the trigger is added, or oscillating around it, does not
+// fire.
+type Trigger struct {
+	Id           string           `json:"id"`
+	IssueId      string           `json:"issueId"`
+	Condition    TriggerCondition `json:"condition"`
+	Level        decimal.Decimal  `json:"level"`
+	Hysteresis   decimal.Decimal  `json:"hysteresis"`
+	RequireCross bool             `json:"requireCross,omitempty"`
+	Order        PlaceOrderInput  `json:"order"`
+
+	State   TriggerState `json:"state"`
+	Primed  bool         `json:"primed"`
+	Created time.Time    `json:"created"`
+	Fired   time.Time    `json:"fired"`
+	OrderId string       `json:"orderId,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// update primes or fires the trigger with a new price, returning whether it
+// changed
+func (t *Trigger) update(price decimal.Decimal) (changed bool, fire bool) {
+	if t.State != TriggerArmed {
+		return false, false
+	}
+	var beyond, before bool
+	switch t.Condition {
+	case CrossAbove:
+		beyond = price.GreaterThanOrEqual(t.Level)
+		before = price.LessThanOrEqual(t.Level.Sub(t.Hysteresis)) && !beyond
+	case CrossBelow:
+		beyond = price.LessThanOrEqual(t.Level)
+		before = price.GreaterThanOrEqual(t.Level.Add(t.Hysteresis)) && !beyond
+	default:
+		return false, false
+	}
+	if beyond && t.Primed {
+		t.State = TriggerFired
+		t.Primed = false
+		return true, true
+	}
+	if before && !t.Primed {
+		t.Primed = true
+		return true, false
+	}
+	return false, false
+}
+
+func (t *Trigger) placing() bool {
+	return t.State == TriggerFired && t.OrderId == "" && t.Error == ""
+}
+
+// TriggerStore persists the triggers so that armed triggers survive
+// restarts.
+type TriggerStore interface {
+	Load() ([]Trigger, error)
+	Save(triggers []Trigger) error
+}
+
+type FileTriggerStore struct {
+	path string
+}
+
+func NewFileTriggerStore(path string) *FileTriggerStore {
+	return &FileTriggerStore{path: path}
+}
+
+func (s *FileTriggerStore) Load() ([]Trigger, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening trigger store: %v", err)
+	}
+	defer f.Close()
+	var triggers []Trigger
+	if err := json.NewDecoder(f).Decode(&triggers); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("decoding trigger store: %v", err)
+	}
+	return triggers, nil
+}
+
+func (s *FileTriggerStore) Save(triggers []Trigger) error {
+	err := writeFileAtomic(s.path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(triggers)
+	})
+	if err != nil {
+		return fmt.Errorf("writing trigger store: %v", err)
+	}
+	return nil
+}
+
+// TriggerEngine evaluates triggers against the streaming quotes, emulating
+// orders not supported by an exchange, e.g. stop losses on products where
+// StopLossAllowed is false.
+type TriggerEngine struct {
+	// OnFire is called once the order of a fired trigger is placed or failed.
+	OnFire func(Trigger)
+
+	mu         sync.Mutex
+	placeOrder func(input PlaceOrderInput) (string, error)
+	subscribe  func(issueIds []string) error
+	store      TriggerStore
+	triggers   map[string]*Trigger
+	order      []string
+	lastId     int64
+	placing    sync.WaitGroup
+	closed     bool
+}
+
+// NewTriggerEngine creates a trigger engine restoring the triggers of
+// store, which can be nil, placing the orders with trader and subscribing to
+// the quotes of the armed ones, a Client being both. Triggers whose order was
+// being placed when the engine stopped are restored as interrupted, see
+// Retry.
+func NewTriggerEngine(trader Trader, quotes QuoteSource, store TriggerStore) (*TriggerEngine, error) {
+	e, err := newTriggerEngine(trader.PlaceOrder, func(issueIds []string) error {
+		return quotes.SubscribeQuotesWithFields(issueIds, []streaming.Field{streaming.LastPrice})
+	}, store)
+	if err != nil {
+		return nil, err
+	}
+	if err := quotes.OnQuoteUpdate(e.handleQuote); err != nil {
+		return nil, err
+	}
+	var issueIds []string
+	for _, trigger := range e.Triggers() {
+		if trigger.State == TriggerArmed {
+			issueIds = append(issueIds, trigger.IssueId)
+		}
+	}
+	if len(issueIds) > 0 {
+		if err := e.subscribe(issueIds); err != nil {
+			return nil, fmt.Errorf("subscribing to quotes: %v", err)
+		}
+	}
+	return e, nil
+}
+
+func newTriggerEngine(placeOrder func(input PlaceOrderInput) (string, error), subscribe func(issueIds []string) error, store TriggerStore) (*TriggerEngine, error) {
+	e := &TriggerEngine{
+		placeOrder: placeOrder,
+		subscribe:  subscribe,
+		store:      store,
+		triggers:   make(map[string]*Trigger),
+	}
+	if store == nil {
+		return e, nil
+	}
+	triggers, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading triggers: %v", err)
+	}
+	for i := range triggers {
+		trigger := triggers[i]
+		if trigger.placing() {
+			log.Warnf("trigger %s was interrupted while placing its order", trigger.Id)
+			trigger.State = TriggerInterrupted
+		}
+		e.triggers[trigger.Id] = &trigger
+		e.order = append(e.order, trigger.Id)
+	}
+	return e, nil
+}
+
+// Add arms a trigger and subscribes to the quotes of its issue. Id, State,
+// Primed and Created are set by the engine, Primed unless RequireCross is
+// set.
+func (e *TriggerEngine) Add(trigger Trigger) (Trigger, error) {
+	if trigger.IssueId == "" {
+		return Trigger{}, fmt.Errorf("no issue id")
+	}
+	if trigger.Condition != CrossAbove && trigger.Condition != CrossBelow {
+		return Trigger{}, fmt.Errorf("unknown trigger condition %q", trigger.Condition)
+	}
+	if trigger.Hysteresis.IsNegative() {
+		return Trigger{}, fmt.Errorf("negative hysteresis")
+	}
+	if e.subscribe != nil {
+		if err := e.subscribe([]string{trigger.IssueId}); err != nil {
+			return Trigger{}, fmt.Errorf("subscribing to quotes: %v", err)
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastId++
+	if now := time.Now().UnixNano(); now > e.lastId {
+		e.lastId = now
+	}
+	trigger.Id = strconv.FormatInt(e.lastId, 36)
+	trigger.State = TriggerArmed
+	trigger.Primed = !trigger.RequireCross
+	trigger.Created = time.Now()
+	e.triggers[trigger.Id] = &trigger
+	e.order = append(e.order, trigger.Id)
+	e.save()
+	return trigger, nil
+}
+
+// Cancel cancels an armed trigger, or dismisses an interrupted one whose
+// order turned out to be placed.
+func (e *TriggerEngine) Cancel(triggerId string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	trigger, found := e.triggers[triggerId]
+	if !found {
+		return fmt.Errorf("unknown trigger %s", triggerId)
+	}
+	if trigger.State != TriggerArmed && trigger.State != TriggerInterrupted {
+		return fmt.Errorf("trigger %s is %s", triggerId, trigger.State)
+	}
+	trigger.State = TriggerCancelled
+	e.save()
+	return nil
+}
+
+// Retry places again the order of an interrupted or failed trigger. The
+// pending orders should be checked first, as the order of an interrupted
+// trigger may have been placed.
+func (e *TriggerEngine) Retry(triggerId string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	trigger, found := e.triggers[triggerId]
+	if !found {
+		return fmt.Errorf("unknown trigger %s", triggerId)
+	}
+	if e.closed {
+		return fmt.Errorf("trigger engine closed")
+	}
+	if trigger.State != TriggerInterrupted && trigger.State != TriggerFailed {
+		return fmt.Errorf("trigger %s is %s", triggerId, trigger.State)
+	}
+	previous := *trigger
+	trigger.State = TriggerFired
+	trigger.Error = ""
+	trigger.Fired = time.Now()
+	if err := e.save(); err != nil {
+		*trigger = previous
+		return fmt.Errorf("saving trigger %s: %v", triggerId, err)
+	}
+	e.placing.Add(1)
+	go e.fire(trigger.Id, trigger.Order)
+	return nil
+}
+
+func (e *TriggerEngine) Trigger(triggerId string) (Trigger, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	trigger, found := e.triggers[triggerId]
+	if !found {
+		return Trigger{}, false
+	}
+	return *trigger, true
+}
+
+// Triggers returns the triggers in creation order.
+func (e *TriggerEngine) Triggers() []Trigger {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var res []Trigger
+	for _, id := range e.order {
+		res = append(res, *e.triggers[id])
+	}
+	return res
+}
+
+// RemoveDone removes the triggers which are not armed anymore and whose
+// order is not being placed, interrupted triggers being kept until retried
+// or cancelled.
+func (e *TriggerEngine) RemoveDone() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var order []string
+	for _, id := range e.order {
+		if trigger := e.triggers[id]; trigger.State != TriggerArmed && trigger.State != TriggerInterrupted && !trigger.placing() {
+			delete(e.triggers, id)
+			continue
+		}
+		order = append(order, id)
+	}
+	e.order = order
+	e.save()
+}
+
+// handleQuote is called from the streaming polling goroutine, orders are
+// placed from another goroutine so as not to block it. The fired state is
+// saved before the orders are placed, so that a trigger is never placed
+// twice after a restart: the triggers whose fired state could not be saved
+// fail without placing their order, and can be retried.
+func (e *TriggerEngine) handleQuote(quote streaming.ProductQuote) {
+	if quote.LastPrice.IsZero() {
+		return
+	}
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	changed := false
+	var fired []*Trigger
+	for _, id := range e.order {
+		trigger := e.triggers[id]
+		if trigger.IssueId != quote.IssueId {
+			continue
+		}
+		triggerChanged, fire := trigger.update(quote.LastPrice)
+		changed = changed || triggerChanged
+		if fire {
+			trigger.Fired = time.Now()
+			fired = append(fired, trigger)
+		}
+	}
+	if !changed {
+		e.mu.Unlock()
+		return
+	}
+	if err := e.save(); err != nil && len(fired) > 0 {
+		var failed []Trigger
+		for _, trigger := range fired {
+			trigger.State = TriggerFailed
+			trigger.Error = fmt.Sprintf("saving fired state: %v", err)
+			failed = append(failed, *trigger)
+		}
+		e.save()
+		onFire := e.OnFire
+		e.mu.Unlock()
+		if onFire != nil {
+			for _, trigger := range failed {
+				onFire(trigger)
+			}
+		}
+		return
+	}
+	for _, trigger := range fired {
+		e.placing.Add(1)
+		go e.fire(trigger.Id, trigger.Order)
+	}
+	e.mu.Unlock()
+}
+
+// Close stops firing triggers and waits for the orders being placed, so
+// that their outcome is saved before the program exits.
+func (e *TriggerEngine) Close() {
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
+	e.placing.Wait()
+}
+
+func (e *TriggerEngine) fire(triggerId string, input PlaceOrderInput) {
+	defer e.placing.Done()
+	orderId, err := e.placeOrder(input)
+	e.mu.Lock()
+	trigger := e.triggers[triggerId]
+	if err != nil {
+		log.Errorf("placing order of trigger %s: %v", triggerId, err)
+		trigger.State = TriggerFailed
+		trigger.Error = err.Error()
+	} else {
+		trigger.OrderId = orderId
+	}
+	fired := *trigger
+	e.save()
+	onFire := e.OnFire
+	e.mu.Unlock()
+	if onFire != nil {
+		onFire(fired)
+	}
+}
+
+func (e *TriggerEngine) save() error {
+	if e.store == nil {
+		return nil
+	}
+	triggers := make([]Trigger, 0, len(e.order))
+	for _, id := range e.order {
+		triggers = append(triggers, *e.triggers[id])
+	}
+	if err := e.store.Save(triggers); err != nil {
+		log.Errorf("saving triggers: %v", err)
+		return err
+	}
+	return nil
+}
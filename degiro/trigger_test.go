@@ -0,0 +1,208 @@
+package degiro
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/llehouerou/go-degiro/degiro/streaming"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrigger_update(t *testing.T) {
+	assert := assert.New(t)
+	trigger := Trigger{Condition: CrossBelow, Level: decimal.New(100, 0), Hysteresis: decimal.New(1, 0), RequireCross: true, State: TriggerArmed}
+	// already below the level: not a cross
+	_, fire := trigger.update(decimal.New(95, 0))
+	assert.False(fire)
+	// above the level but within the hysteresis
+	changed, _ := trigger.update(decimal.RequireFromString("100.5"))
+	assert.False(changed)
+	_, fire = trigger.update(decimal.New(99, 0))
+	assert.False(fire)
+
+	changed, _ = trigger.update(decimal.New(101, 0))
+	assert.True(changed)
+	assert.True(trigger.Primed)
+	changed, fire = trigger.update(decimal.New(100, 0))
+	assert.True(changed)
+	assert.True(fire)
+	assert.Equal(TriggerFired, trigger.State)
+
+	// one shot
+	trigger.update(decimal.New(105, 0))
+	_, fire = trigger.update(decimal.New(90, 0))
+	assert.False(fire)
+}
+
+func TestTriggerEngine(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "triggers")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	store := NewFileTriggerStore(filepath.Join(dir, "triggers.json"))
+
+	var mu sync.Mutex
+	var placed []PlaceOrderInput
+	placeOrder := func(input PlaceOrderInput) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		placed = append(placed, input)
+		return "order-1", nil
+	}
+	var subscribed []string
+	subscribe := func(issueIds []string) error {
+		subscribed = append(subscribed, issueIds...)
+		return nil
+	}
+	engine, err := newTriggerEngine(placeOrder, subscribe, store)
+	assert.Nil(err)
+	stop := PlaceOrderInput{BuySell: Sell, OrderType: MarketOrder, ProductId: "1", Quantity: 10, TimeType: Day}
+	trigger, err := engine.Add(Trigger{IssueId: "350015372", Condition: CrossBelow, Level: decimal.New(100, 0), Order: stop})
+	assert.Nil(err)
+	assert.Equal([]string{"350015372"}, subscribed)
+	_, err = engine.Add(Trigger{IssueId: "350015372", Condition: "crossing"})
+	assert.NotNil(err)
+
+	engine.handleQuote(streaming.ProductQuote{IssueId: "350015372", LastPrice: decimal.New(102, 0)})
+	engine.handleQuote(streaming.ProductQuote{IssueId: "1", LastPrice: decimal.New(90, 0)})
+
+	// restart: the primed trigger is restored
+	engine, err = newTriggerEngine(placeOrder, subscribe, store)
+	assert.Nil(err)
+	var fired []Trigger
+	engine.OnFire = func(trigger Trigger) {
+		fired = append(fired, trigger)
+	}
+	restored, found := engine.Trigger(trigger.Id)
+	assert.True(found)
+	assert.True(restored.Primed)
+
+	engine.handleQuote(streaming.ProductQuote{IssueId: "350015372", LastPrice: decimal.New(99, 0)})
+	engine.handleQuote(streaming.ProductQuote{IssueId: "350015372", LastPrice: decimal.New(98, 0)})
+	engine.placing.Wait()
+	assert.Equal([]PlaceOrderInput{stop}, placed)
+	if assert.Equal(1, len(fired)) {
+		assert.Equal("order-1", fired[0].OrderId)
+		assert.Equal(TriggerFired, fired[0].State)
+	}
+	assert.NotNil(engine.Cancel(trigger.Id))
+
+	engine.RemoveDone()
+	assert.Equal(0, len(engine.Triggers()))
+}
+
+func TestTriggerEngine_AlreadyBeyond(t *testing.T) {
+	assert := assert.New(t)
+	var placed []PlaceOrderInput
+	placeOrder := func(input PlaceOrderInput) (string, error) {
+		placed = append(placed, input)
+		return "order-1", nil
+	}
+	engine, err := newTriggerEngine(placeOrder, nil, nil)
+	assert.Nil(err)
+	stop := PlaceOrderInput{BuySell: Sell, OrderType: MarketOrder, ProductId: "1", Quantity: 10, TimeType: Day}
+	gap, err := engine.Add(Trigger{IssueId: "1", Condition: CrossBelow, Level: decimal.New(100, 0), Order: stop})
+	assert.Nil(err)
+	cross, err := engine.Add(Trigger{IssueId: "1", Condition: CrossBelow, Level: decimal.New(100, 0), RequireCross: true, Order: stop})
+	assert.Nil(err)
+
+	// the price opens below the level
+	engine.handleQuote(streaming.ProductQuote{IssueId: "1", LastPrice: decimal.New(95, 0)})
+	engine.placing.Wait()
+	assert.Equal(1, len(placed))
+	gap, _ = engine.Trigger(gap.Id)
+	assert.Equal(TriggerFired, gap.State)
+	cross, _ = engine.Trigger(cross.Id)
+	assert.Equal(TriggerArmed, cross.State)
+}
+
+func TestTriggerEngine_Interrupted(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "triggers")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	store := NewFileTriggerStore(filepath.Join(dir, "triggers.json"))
+	stop := PlaceOrderInput{BuySell: Sell, OrderType: MarketOrder, ProductId: "1", Quantity: 10, TimeType: Day}
+	assert.Nil(store.Save([]Trigger{{Id: "a", IssueId: "1", Condition: CrossBelow, Level: decimal.New(100, 0), Order: stop, State: TriggerFired}}))
+
+	var placed []PlaceOrderInput
+	placeOrder := func(input PlaceOrderInput) (string, error) {
+		placed = append(placed, input)
+		return "order-1", nil
+	}
+	engine, err := newTriggerEngine(placeOrder, nil, store)
+	assert.Nil(err)
+	trigger, _ := engine.Trigger("a")
+	assert.Equal(TriggerInterrupted, trigger.State)
+	engine.RemoveDone()
+	assert.Equal(1, len(engine.Triggers()))
+
+	assert.Nil(engine.Retry("a"))
+	engine.placing.Wait()
+	assert.Equal([]PlaceOrderInput{stop}, placed)
+	trigger, _ = engine.Trigger("a")
+	assert.Equal(TriggerFired, trigger.State)
+	assert.Equal("order-1", trigger.OrderId)
+	assert.NotNil(engine.Retry("a"))
+}
+
+type failingTriggerStore struct {
+	fail bool
+}
+
+func (s *failingTriggerStore) Load() ([]Trigger, error) {
+	return nil, nil
+}
+
+func (s *failingTriggerStore) Save(triggers []Trigger) error {
+	if s.fail {
+		return fmt.Errorf("disk full")
+	}
+	return nil
+}
+
+func TestTriggerEngine_SaveFailed(t *testing.T) {
+	assert := assert.New(t)
+	store := &failingTriggerStore{}
+	var placed []PlaceOrderInput
+	placeOrder := func(input PlaceOrderInput) (string, error) {
+		placed = append(placed, input)
+		return "order-1", nil
+	}
+	engine, err := newTriggerEngine(placeOrder, nil, store)
+	assert.Nil(err)
+	var fired []Trigger
+	engine.OnFire = func(trigger Trigger) {
+		fired = append(fired, trigger)
+	}
+	stop := PlaceOrderInput{BuySell: Sell, OrderType: MarketOrder, ProductId: "1", Quantity: 10, TimeType: Day}
+	trigger, err := engine.Add(Trigger{IssueId: "1", Condition: CrossBelow, Level: decimal.New(100, 0), Order: stop})
+	assert.Nil(err)
+
+	// the fired state cannot be saved: the order is not placed
+	store.fail = true
+	engine.handleQuote(streaming.ProductQuote{IssueId: "1", LastPrice: decimal.New(95, 0)})
+	engine.placing.Wait()
+	assert.Equal(0, len(placed))
+	trigger, _ = engine.Trigger(trigger.Id)
+	assert.Equal(TriggerFailed, trigger.State)
+	if assert.Equal(1, len(fired)) {
+		assert.Equal(TriggerFailed, fired[0].State)
+	}
+	assert.NotNil(engine.Retry(trigger.Id))
+	trigger, _ = engine.Trigger(trigger.Id)
+	assert.Equal(TriggerFailed, trigger.State)
+
+	store.fail = false
+	assert.Nil(engine.Retry(trigger.Id))
+	engine.Close()
+	assert.Equal([]PlaceOrderInput{stop}, placed)
+	trigger, _ = engine.Trigger(trigger.Id)
+	assert.Equal("order-1", trigger.OrderId)
+	assert.NotNil(engine.Retry(trigger.Id))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/dghubble/sling"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,13 +21,15 @@ func (c *Client) update() error {
 		TotalPortfolio int `url:"totalPortfolio"`
 	}
 	response := &updateResponse{}
-	_, err := c.ReceiveSuccessReloginOn401(c.sling.New().
-		Get(fmt.Sprintf("trading/secure/v5/update/%d;jsessionid=%s", c.accountId, c.sessionId)).
-		QueryStruct(updateParams{
-			Orders:         c.ordersLastUpdate,
-			Portfolio:      c.portfolioLastUpdate,
-			TotalPortfolio: c.totalPortfolioLastUpdate,
-		}), response)
+	_, err := c.receiveSuccessReloginOn401(func() *sling.Sling {
+		return c.sling.New().
+			Get(fmt.Sprintf("trading/secure/v5/update/%d;jsessionid=%s", c.accountId, c.session())).
+			QueryStruct(updateParams{
+				Orders:         c.ordersLastUpdate,
+				Portfolio:      c.portfolioLastUpdate,
+				TotalPortfolio: c.totalPortfolioLastUpdate,
+			})
+	}, response)
 	if err != nil {
 		return fmt.Errorf("executing request: %v", err)
 	}
@@ -39,16 +42,47 @@ func (c *Client) update() error {
 		c.totalPortfolioLastUpdate = response.Balance.LastUpdated
 		if b, found := response.Balance.ConvertToBalance(); found {
 			c.balance.Set(b)
+			if c.RiskPolicy != nil {
+				c.RiskPolicy.ObserveBalance(b, time.Now())
+			}
 		}
 	}
 	return nil
 }
 
+// OrderUpdate are the changes of the pending orders received by an update.
+// Orders are removed when they are filled or cancelled.
+type OrderUpdate struct {
+	Added   []Order
+	Updated []Order
+	Removed []string
+}
+
 func (c *Client) updateOrderCacheFromResponse(response *updateResponse) {
 	added, updated, removed := response.Orders.ConvertToOrders()
 	c.orders.Add(added)
 	c.orders.Update(updated)
 	c.orders.Remove(removed)
+	if len(added) > 0 || len(updated) > 0 || len(removed) > 0 {
+		c.notifyOrderHandlers(OrderUpdate{Added: added, Updated: updated, Removed: removed})
+	}
+}
+
+// OnOrderUpdate registers a handler called from the update loop each time
+// the pending orders change, after the order cache is updated.
+func (c *Client) OnOrderUpdate(handler func(OrderUpdate)) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	c.orderHandlers = append(c.orderHandlers, handler)
+}
+
+func (c *Client) notifyOrderHandlers(update OrderUpdate) {
+	c.handlersMu.RLock()
+	handlers := c.orderHandlers
+	c.handlersMu.RUnlock()
+	for _, handler := range handlers {
+		handler(update)
+	}
 }
 
 func (c *Client) updatePositionCacheFromResponse(response *updateResponse) {